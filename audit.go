@@ -0,0 +1,165 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditAction is the kind of change that an `AuditEntry` records
+type AuditAction string
+
+const (
+	// AuditAdded is recorded when a job is added for the first time, or again after it was removed
+	AuditAdded = AuditAction("added")
+
+	// AuditModified is recorded when a job is added with a schedule that is different from the one it was last audited with,
+	// ignoring its `Starting` time which defaults to when it is added
+	AuditModified = AuditAction("modified")
+
+	// AuditPaused is recorded when a job is paused
+	AuditPaused = AuditAction("paused")
+
+	// AuditResumed is recorded when a job is resumed
+	AuditResumed = AuditAction("resumed")
+
+	// AuditDisabled is recorded when a job is disabled
+	AuditDisabled = AuditAction("disabled")
+
+	// AuditEnabled is recorded when a job is enabled again
+	AuditEnabled = AuditAction("enabled")
+
+	// AuditRemoved is recorded when a job is removed
+	AuditRemoved = AuditAction("removed")
+
+	// AuditDenied is recorded when the `Gatekeeper` denies an execution of a job, see `AuditEntry.Reason`.
+	// Each instance that would have performed the execution records it
+	AuditDenied = AuditAction("denied")
+)
+
+// AuditEntry records a change to the schedule of a job, see `Historian.History`
+type AuditEntry struct {
+	// Time is when the change was made
+	Time time.Time
+
+	// JobName is the name of the job that was changed
+	JobName string
+
+	// Action is the kind of change
+	Action AuditAction
+
+	// Actor is who or what made the change, see `Config.Actor`
+	Actor string
+
+	// Before is the JSON of the `JobSpec` of the job before the change. It is empty if the job was added
+	Before string
+
+	// After is the JSON of the `JobSpec` of the job after the change. It is empty if the job was removed
+	After string
+
+	// Reason is why the `Gatekeeper` denied an execution. It is only set for `AuditDenied`
+	Reason string
+}
+
+// History returns the audit log of the job named `name`, ie to show when its schedule was changed and by whom.
+// Note: the store must implement `Auditor`
+func (s *scheduler) History(name string) ([]AuditEntry, error) {
+	a, ok := s.store.(Auditor)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot return the history of %s, its store does not implement Auditor", s.name, name)
+	}
+	return a.History(s.table, name)
+}
+
+// audit appends a change to the job named `name` to the audit log, if the store keeps one
+func (s *scheduler) audit(name string, action AuditAction, before, after string) {
+	s.appendAudit(AuditEntry{Time: time.Now(), JobName: name, Action: action, Actor: s.actor, Before: before, After: after})
+}
+
+// appendAudit appends `e` to the audit log, if the store keeps one
+func (s *scheduler) appendAudit(e AuditEntry) {
+	a, ok := s.store.(Auditor)
+	if !ok {
+		return
+	}
+	if err := a.Audit(s.table, e); err != nil {
+		s.fail(fmt.Errorf("%s failed to audit that %s was %s: %s", s.name, e.JobName, e.Action, err))
+	}
+}
+
+// audited appends the addition of `j` to the audit log, or its modification if it was last audited with a different spec,
+// so that restarting a scheduler does not audit the jobs that did not change
+func (s *scheduler) audited(j *job) {
+	a, ok := s.store.(Auditor)
+	if !ok {
+		return
+	}
+	history, err := a.History(s.table, j.JobName)
+	if err == errNotConnected {
+		// the jobs are audited once the database is connected to
+		return
+	} else if err != nil {
+		s.fail(fmt.Errorf("%s failed to audit %s: %s", s.name, j.JobName, err))
+		return
+	}
+	after := j.specJSON()
+	if n := len(history); n == 0 || history[n-1].Action == AuditRemoved {
+		s.audit(j.JobName, AuditAdded, "", after)
+	} else if before := history[n-1].After; !sameSchedule(before, after) {
+		s.audit(j.JobName, AuditModified, before, after)
+	}
+}
+
+// sameSchedule reports whether the JSON of two specs describe the same schedule, ignoring their `Starting` times
+func sameSchedule(a, b string) bool {
+	var specs [2]JobSpec
+	for i, data := range []string{a, b} {
+		if err := json.Unmarshal([]byte(data), &specs[i]); err != nil {
+			return a == b
+		}
+		specs[i].Starting = time.Time{}
+	}
+	a1, _ := json.Marshal(specs[0])
+	b1, _ := json.Marshal(specs[1])
+	return string(a1) == string(b1)
+}
+
+// Definition returns the `JobSpec` that describes the schedule of the job, without its func
+func (j *job) Definition() JobSpec {
+	spec := JobSpec{
+		Name:        j.JobName,
+		Every:       j.IntervalAmount,
+		Interval:    j.IntervalType,
+		Month:       time.Month(j.Month),
+		Day:         j.Day,
+		Hour:        j.Hour,
+		Minute:      j.Minute,
+		Second:      j.Second,
+		Starting:    j.StartAt,
+		Timezone:    j.location(),
+		Offset:      j.OffsetDuration,
+		Until:       j.EndAt,
+		Times:       j.MaxRuns,
+		ExpireAfter: j.Expiry,
+		Tenant:      j.TenantName,
+		PinnedTo:    j.PinnedTo,
+		Payload:     json.RawMessage(j.Record.Payload),
+	}
+	if j.DayMask != 0 {
+		for _, d := range j.weekdays() {
+			if d != j.Day {
+				spec.Days = append(spec.Days, d)
+			}
+		}
+	}
+	return spec
+}
+
+// specJSON returns the JSON of the spec of the job, which is what the audit log records
+func (j *job) specJSON() string {
+	data, err := json.Marshal(j.Definition())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
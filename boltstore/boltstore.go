@@ -0,0 +1,93 @@
+// Package boltstore implements a `schedule.Store` with an embedded bbolt database,
+// for deployments that cannot depend on any external service
+package boltstore
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/marksalpeter/schedule"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store is a `schedule.Store` that keeps the records of the jobs in a bbolt database, in one bucket per scheduler.
+// A bbolt database can only be opened by one process, so it keeps track of the runs of a single instance across restarts
+type Store struct {
+	db *bolt.DB
+}
+
+// New creates a `Store` that uses `db`
+func New(db *bolt.DB) *Store {
+	return &Store{db: db}
+}
+
+// Add implements `schedule.Store`
+func (s *Store) Add(scheduler string, r *schedule.Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(scheduler))
+		if err != nil {
+			return err
+		}
+		if v := b.Get([]byte(r.JobName)); v != nil {
+			var stored schedule.Record
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			r.Merge(&stored)
+			if stored.Checksum == r.Checksum {
+				r.Resume(&stored)
+			}
+		}
+		return put(b, r)
+	})
+}
+
+// Claim implements `schedule.Store`
+func (s *Store) Claim(scheduler string, r *schedule.Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(scheduler))
+		if b == nil {
+			return errors.New(r.JobName + " has not been added to the store")
+		}
+		v := b.Get([]byte(r.JobName))
+		if v == nil {
+			return errors.New(r.JobName + " has not been added to the store")
+		}
+		var stored schedule.Record
+		if err := json.Unmarshal(v, &stored); err != nil {
+			return err
+		} else if err := r.Claim(&stored); err != nil {
+			return err
+		}
+		return put(b, r)
+	})
+}
+
+// List implements `schedule.Lister`
+func (s *Store) List(scheduler string) ([]schedule.Record, error) {
+	var records []schedule.Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(scheduler))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var r schedule.Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			records = append(records, r)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// put saves `r` in the bucket under the name of its job
+func put(b *bolt.Bucket, r *schedule.Record) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(r.JobName), v)
+}
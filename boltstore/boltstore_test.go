@@ -0,0 +1,41 @@
+package boltstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/boltstore"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "schedule.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := boltstore.New(db)
+	s := schedule.MustNew(&schedule.Config{Name: "bolt-test", Store: store})
+	s.Add("1-second").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {})
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s.Stop()
+
+	records, err := store.List("bolt-test")
+	assert := assert.New(t)
+	assert.NoError(err)
+	if assert.Len(records, 1) {
+		assert.Equal(2, records[0].RunCount, "the runs are stored")
+	}
+}
@@ -0,0 +1,23 @@
+package schedule
+
+import "time"
+
+// Calendar is consulted by jobs built with `Task.SkipHolidays` before every execution
+type Calendar interface {
+	// IsHoliday reports whether jobs should not run on the day of `t`
+	IsHoliday(t time.Time) bool
+}
+
+// Holidays is a `Calendar` made up of a fixed list of days
+type Holidays []time.Time
+
+// IsHoliday reports whether the day of `t` is in the list of holidays
+func (h Holidays) IsHoliday(t time.Time) bool {
+	for _, d := range h {
+		d = d.In(t.Location())
+		if d.Year() == t.Year() && d.YearDay() == t.YearDay() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package schedule
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// clockSync is how often a scheduler with `Config.DatabaseClock` measures how far its clock is from the clock of the database
+const clockSync = time.Minute
+
+// now returns the time of the authoritative clock, which is the local clock corrected by the offset of the database clock
+// when `Config.DatabaseClock` is set
+func (s *scheduler) now() time.Time {
+	return s.at(time.Now())
+}
+
+// at converts the local time `t` to the time of the authoritative clock
+func (s *scheduler) at(t time.Time) time.Time {
+	return t.Add(time.Duration(atomic.LoadInt64(&s.offset)))
+}
+
+// syncClock measures how far the local clock is from the clock of the database. The round trip of the query is halved,
+// so that the time of the database is compared to the local time when it was most likely read
+func (s *scheduler) syncClock() {
+	c, ok := s.store.(Clock)
+	if !ok {
+		log.Printf("schedule: %s cannot use the clock of its database, its store does not implement Clock", s.name)
+		return
+	}
+	before := time.Now()
+	now, err := c.Now()
+	if err != nil {
+		log.Printf("schedule: %s failed to read the clock of its database: %s", s.name, err)
+		return
+	}
+	local := before.Add(time.Since(before) / 2)
+	atomic.StoreInt64(&s.offset, int64(now.Sub(local)))
+}
@@ -0,0 +1,78 @@
+// Command schedulectl is a command line tool for administering schedule's database tables
+//
+// Usage:
+//
+//	schedulectl schema [--dialect=mysql] [--name=default] [--prefix=] [--schema=]
+//	schedulectl migrations [--dialect=mysql] [--name=default] [--prefix=] [--schema=]
+//	schedulectl migrate --dsn=user:password@tcp(host:3306)/db [--name=default] [--prefix=] [--schema=]
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql" // import the sql driver
+	"github.com/marksalpeter/schedule"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dialect := flags.String("dialect", "mysql", "the sql dialect of the DDL")
+	name := flags.String("name", "default", "the table name of the scheduler, which defaults to its name")
+	prefix := flags.String("prefix", "", "the table prefix of the scheduler")
+	qualifier := flags.String("schema", "", "the schema that the table is in")
+	dsn := flags.String("dsn", "", "the mysql data source name of the database to migrate")
+	flags.Parse(os.Args[2:])
+	table := *prefix + *name
+	if len(*qualifier) > 0 {
+		table = *qualifier + "." + table
+	}
+	switch os.Args[1] {
+	case "schema":
+		ddl, err := schedule.Schema(*dialect, table)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Print(ddl)
+	case "migrations":
+		migrations, err := schedule.Migrations(*dialect, table)
+		if err != nil {
+			fail(err)
+		}
+		for _, m := range migrations {
+			fmt.Printf("-- %d: %s\n%s\n", m.Version, m.Description, m.SQL)
+		}
+	case "migrate":
+		if len(*dsn) == 0 {
+			usage()
+		}
+		db, err := sql.Open("mysql", *dsn)
+		if err != nil {
+			fail(err)
+		}
+		defer db.Close()
+		if err := schedule.Migrate(db, "mysql", table); err != nil {
+			fail(err)
+		}
+	default:
+		usage()
+	}
+}
+
+// fail prints `err` and exits
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// usage prints the usage of schedulectl and exits
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: schedulectl schema|migrations [--dialect=mysql] [--name=default] [--prefix=] [--schema=]")
+	fmt.Fprintln(os.Stderr, "       schedulectl migrate --dsn=user:password@tcp(host:3306)/db [--name=default] [--prefix=] [--schema=]")
+	os.Exit(2)
+}
@@ -0,0 +1,143 @@
+// Package consulstore implements a `schedule.Store` with the Consul KV store, for services that already coordinate through Consul.
+// Every write to a record is made while holding a Consul lock on the job, so only one instance can claim an execution
+package consulstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/marksalpeter/schedule"
+)
+
+// Store is a `schedule.Store` that keeps the records of the jobs of every scheduler under a prefix of the Consul KV store
+type Store struct {
+	// LockWaitTime is the amount of time `Claim` waits for the lock of a job held by another instance. It defaults to `api.DefaultLockWaitTime`.
+	// `Add` waits until the lock is released, so that an instance that starts while another one is executing the job still adds it
+	LockWaitTime time.Duration
+
+	client *api.Client
+	prefix string
+}
+
+// New creates a `Store` that keeps its keys under `prefix`, ie "schedule"
+func New(client *api.Client, prefix string) *Store {
+	return &Store{client: client, prefix: strings.Trim(prefix, "/")}
+}
+
+// Add implements `schedule.Store`
+func (s *Store) Add(scheduler string, r *schedule.Record) error {
+	return s.locked(scheduler, r.JobName, true, func() error {
+		stored, index, err := s.get(scheduler, r.JobName)
+		if err != nil {
+			return err
+		} else if stored != nil {
+			r.Merge(stored)
+		}
+		return s.put(scheduler, r, index)
+	})
+}
+
+// Claim implements `schedule.Store`
+func (s *Store) Claim(scheduler string, r *schedule.Record) error {
+	err := s.locked(scheduler, r.JobName, false, func() error {
+		stored, index, err := s.get(scheduler, r.JobName)
+		if err != nil {
+			return err
+		} else if stored == nil {
+			return errors.New(r.JobName + " has not been added to the store")
+		} else if err := r.Claim(stored); err != nil {
+			return err
+		}
+		return s.put(scheduler, r, index)
+	})
+	if err == errNotLocked {
+		// another instance is claiming the execution
+		return schedule.ErrAlreadyExecuted
+	}
+	return err
+}
+
+// List implements `schedule.Lister`
+func (s *Store) List(scheduler string) ([]schedule.Record, error) {
+	pairs, _, err := s.client.KV().List(s.key(scheduler, "jobs")+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	var records []schedule.Record
+	for _, p := range pairs {
+		var r schedule.Record
+		if err := json.Unmarshal(p.Value, &r); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// errNotLocked is returned by `Store.locked` when the lock is held by another instance
+var errNotLocked = errors.New("consulstore: the lock is held by another instance")
+
+// locked calls `fn` while holding the lock of the job named `name`.
+// If `wait` is false it gives up with `errNotLocked` once the lock was held by another instance for `LockWaitTime`
+func (s *Store) locked(scheduler, name string, wait bool, fn func() error) error {
+	l, err := s.client.LockOpts(&api.LockOptions{
+		Key:          s.key(scheduler, "locks", name),
+		SessionName:  fmt.Sprintf("schedule %s %s", scheduler, name),
+		LockWaitTime: s.LockWaitTime,
+		LockTryOnce:  !wait,
+	})
+	if err != nil {
+		return err
+	}
+	held, err := l.Lock(nil)
+	if err != nil {
+		return err
+	} else if held == nil {
+		return errNotLocked
+	}
+	defer l.Unlock()
+	return fn()
+}
+
+// get returns the stored record of the job named `name` and its modify index, or nil if it is not stored
+func (s *Store) get(scheduler, name string) (*schedule.Record, uint64, error) {
+	p, _, err := s.client.KV().Get(s.key(scheduler, "jobs", name), nil)
+	if err != nil || p == nil {
+		return nil, 0, err
+	}
+	var r schedule.Record
+	if err := json.Unmarshal(p.Value, &r); err != nil {
+		return nil, 0, err
+	}
+	return &r, p.ModifyIndex, nil
+}
+
+// put writes `r` if its key was not modified since `index` was read.
+// A modify index of 0 only writes `r` if it was not stored yet
+func (s *Store) put(scheduler string, r *schedule.Record, index uint64) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ok, _, err := s.client.KV().CAS(&api.KVPair{
+		Key:         s.key(scheduler, "jobs", r.JobName),
+		Value:       value,
+		ModifyIndex: index,
+	}, nil)
+	if err != nil {
+		return err
+	} else if !ok {
+		return schedule.ErrAlreadyExecuted
+	}
+	return nil
+}
+
+// key joins `parts` under the prefix of the store
+func (s *Store) key(parts ...string) string {
+	return path.Join(append([]string{s.prefix}, parts...)...)
+}
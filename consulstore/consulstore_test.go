@@ -0,0 +1,222 @@
+package consulstore_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/consulstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddWaitsForLock(t *testing.T) {
+	client := newConsul(t)
+	store := consulstore.New(client, "schedule")
+	store.LockWaitTime = 50 * time.Millisecond
+
+	// another instance is executing the job
+	l, err := client.LockKey("schedule/consul-test/locks/job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Lock(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	added := make(chan error, 1)
+	go func() {
+		added <- store.Add("consul-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once, RunCount: 1})
+	}()
+	assert := assert.New(t)
+	select {
+	case err := <-added:
+		t.Fatalf("the job was added while the lock was held: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assert.NoError(l.Unlock())
+	select {
+	case err := <-added:
+		assert.NoError(err, "the job is added once the lock is released")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job was not added after the lock was released")
+	}
+	records, err := store.List("consul-test")
+	assert.NoError(err)
+	if assert.Len(records, 1) {
+		assert.Equal("job", records[0].JobName)
+	}
+}
+
+func TestClaimLocked(t *testing.T) {
+	client := newConsul(t)
+	store := consulstore.New(client, "schedule")
+	store.LockWaitTime = 50 * time.Millisecond
+	assert := assert.New(t)
+	assert.NoError(store.Add("consul-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+
+	l, err := client.LockKey("schedule/consul-test/locks/job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Lock(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	assert.Equal(schedule.ErrAlreadyExecuted, store.Claim("consul-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}),
+		"an execution is not claimed while another instance holds the lock")
+}
+
+func TestStore(t *testing.T) {
+	store := consulstore.New(newConsul(t), "schedule")
+	s := schedule.MustNew(&schedule.Config{Name: "consul-test", Store: store})
+	s.Add("1-second").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {})
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s.Stop()
+
+	records, err := store.List("consul-test")
+	assert := assert.New(t)
+	assert.NoError(err)
+	if assert.Len(records, 1) {
+		assert.Equal(2, records[0].RunCount, "the runs are stored")
+	}
+}
+
+// newConsul starts a fake of the Consul agent that implements the sessions and the KV store that are used by the store
+func newConsul(t *testing.T) *api.Client {
+	c := &consul{pairs: map[string]*api.KVPair{}, changed: make(chan struct{}), done: make(chan struct{})}
+	srv := httptest.NewServer(c)
+	t.Cleanup(func() {
+		close(c.done)
+		srv.Close()
+	})
+	client, err := api.NewClient(&api.Config{Address: strings.TrimPrefix(srv.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// consul is a fake of the http api of a Consul agent
+type consul struct {
+	mu       sync.Mutex
+	index    uint64
+	sessions int
+	pairs    map[string]*api.KVPair
+	changed  chan struct{}
+	done     chan struct{}
+}
+
+func (c *consul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Consul-LastContact", "0")
+	w.Header().Set("X-Consul-KnownLeader", "true")
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		c.mu.Lock()
+		c.sessions++
+		id := fmt.Sprintf("session-%d", c.sessions)
+		c.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+		json.NewEncoder(w).Encode([]map[string]string{{"ID": strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")}})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+		json.NewEncoder(w).Encode(true)
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/") && r.Method == http.MethodGet:
+		c.get(w, r, strings.TrimPrefix(r.URL.Path, "/v1/kv/"))
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/") && r.Method == http.MethodPut:
+		c.put(w, r, strings.TrimPrefix(r.URL.Path, "/v1/kv/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// get reads a key, or the keys under a prefix, and blocks until it changes if the index of the request is current
+func (c *consul) get(w http.ResponseWriter, r *http.Request, key string) {
+	q := r.URL.Query()
+	index, _ := strconv.ParseUint(q.Get("index"), 10, 64)
+	wait, err := time.ParseDuration(q.Get("wait"))
+	if err != nil || wait > time.Second {
+		wait = time.Second
+	}
+	c.mu.Lock()
+	if index > 0 && index >= c.index {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-c.done:
+		}
+		c.mu.Lock()
+	}
+	defer c.mu.Unlock()
+
+	var pairs api.KVPairs
+	for k, p := range c.pairs {
+		if k == key || (q.Has("recurse") && strings.HasPrefix(k, key)) {
+			pair := *p
+			pairs = append(pairs, &pair)
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(pairs)
+}
+
+// put writes a key with the semantics of the `acquire`, `release` and `cas` parameters
+func (c *consul) put(w http.ResponseWriter, r *http.Request, key string) {
+	q := r.URL.Query()
+	value, _ := ioutil.ReadAll(r.Body)
+	flags, _ := strconv.ParseUint(q.Get("flags"), 10, 64)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pairs[key]
+	if !ok {
+		p = &api.KVPair{Key: key}
+	}
+	switch {
+	case q.Has("acquire"):
+		if p.Session != "" && p.Session != q.Get("acquire") {
+			json.NewEncoder(w).Encode(false)
+			return
+		}
+		p.Session = q.Get("acquire")
+		p.LockIndex++
+	case q.Has("release"):
+		if p.Session != q.Get("release") {
+			json.NewEncoder(w).Encode(false)
+			return
+		}
+		p.Session = ""
+	case q.Has("cas"):
+		if cas, _ := strconv.ParseUint(q.Get("cas"), 10, 64); (cas == 0 && ok) || (cas != 0 && cas != p.ModifyIndex) {
+			json.NewEncoder(w).Encode(false)
+			return
+		}
+	}
+	c.index++
+	if !ok {
+		p.CreateIndex = c.index
+	}
+	p.ModifyIndex = c.index
+	p.Flags = flags
+	p.Value = value
+	c.pairs[key] = p
+	close(c.changed)
+	c.changed = make(chan struct{})
+	json.NewEncoder(w).Encode(true)
+}
@@ -0,0 +1,261 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TaskFunc is the func executed by a job
+type TaskFunc func(Job, time.Time)
+
+// LoadCrontab adds a job to the `DefaultScheduler` for every line of the crontab read from `r`
+func LoadCrontab(r io.Reader, registry map[string]TaskFunc) error {
+	return loadCrontab(DefaultScheduler, r, registry)
+}
+
+// crontabLine is a line of a crontab that schedules a command
+type crontabLine struct {
+	n        int
+	schedule []string
+	command  string
+}
+
+// loadCrontab adds a job to `s` for every line of the crontab read from `r`. Each job is named after the command of its line,
+// which must be a key of `registry`, or after its command and line number when several lines run the same command.
+// Comments, blank lines and environment variables are ignored. If a line is invalid, none of the jobs are added
+func loadCrontab(s Scheduler, r io.Reader, registry map[string]TaskFunc) error {
+	var lines []crontabLine
+	commands := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || isCrontabVariable(line) {
+			continue
+		}
+		l, err := splitCrontabLine(n, line)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+		lines = append(lines, l)
+		commands[l.command]++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// build every job before any of them is added
+	b := batch{Scheduler: s}
+	for _, l := range lines {
+		name := l.command
+		if commands[l.command] > 1 {
+			name = fmt.Sprintf("%s (line %d)", l.command, l.n)
+		}
+		if err := addCrontabLine(&b, name, l, registry); err != nil {
+			return fmt.Errorf("line %d: %s", l.n, err)
+		}
+	}
+	return b.commit()
+}
+
+// isCrontabVariable reports whether `line` sets an environment variable, ie `MAILTO=ops@example.com`
+func isCrontabVariable(line string) bool {
+	i := strings.IndexAny(line, " \t=")
+	return i > 0 && line[i] == '=' && !strings.HasPrefix(line, "@")
+}
+
+// splitCrontabLine splits the schedule of the line numbered `n` of a crontab from its command
+func splitCrontabLine(n int, line string) (crontabLine, error) {
+	fields := strings.Fields(line)
+	i := 5
+	if strings.HasPrefix(line, "@every") {
+		i = 2
+	} else if strings.HasPrefix(line, "@") {
+		i = 1
+	}
+	if len(fields) <= i {
+		return crontabLine{}, fmt.Errorf("%q does not have a command", line)
+	}
+	return crontabLine{n: n, schedule: fields[:i:i], command: strings.Join(fields[i:], " ")}, nil
+}
+
+// addCrontabLine adds the job named `name` described by a line of a crontab to `s`, returning any misuse of the builder as an error
+func addCrontabLine(s Scheduler, name string, l crontabLine, registry map[string]TaskFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	do, ok := registry[l.command]
+	if !ok || do == nil {
+		return fmt.Errorf("%q is not in the registry", l.command)
+	}
+
+	// build the job
+	a := s.Add(name)
+	if len(l.schedule) < 5 {
+		return a.Spec(strings.Join(l.schedule, " ")).Do(do)
+	}
+	t, err := cronSchedule(a, l.schedule[0], l.schedule[1], l.schedule[2], l.schedule[3], l.schedule[4])
+	if err != nil {
+		return err
+	}
+	return t.Do(do)
+}
+
+// cronSchedule translates the time fields of a crontab line to the builder methods of `a`.
+// Only the lines that have an equivalent schedule are supported
+func cronSchedule(a Amount, minute, hour, dom, month, dow string) (Task, error) {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	m, mErr := strconv.Atoi(minute)
+	h, hErr := strconv.Atoi(hour)
+	everyDay := dom == "*" && month == "*" && dow == "*"
+	switch {
+	case minute == "*" && hour == "*" && everyDay:
+		return a.Every(1).Minutes().Starting(midnight), nil
+	case strings.HasPrefix(minute, "*/") && hour == "*" && everyDay:
+		return a.Every(cronStep(minute)).Minutes().Starting(midnight), nil
+	case mErr == nil && hour == "*" && everyDay:
+		return a.Every(1).Hours().Starting(midnight.Add(time.Duration(m) * time.Minute)), nil
+	case mErr == nil && strings.HasPrefix(hour, "*/") && everyDay:
+		return a.Every(cronStep(hour)).Hours().Starting(midnight.Add(time.Duration(m) * time.Minute)), nil
+	case mErr != nil || hErr != nil:
+		break
+	case everyDay:
+		return a.Every(1).Days().At(h, m, 0).Starting(now), nil
+	case dom == "*" && month == "*" && dow == "1-5":
+		return a.Every().Weekdays().At(h, m, 0).Starting(now), nil
+	case dom == "*" && month == "*":
+		days, err := cronWeekdays(dow)
+		if err != nil {
+			return nil, err
+		}
+		return a.Every(1).Weeks().On(days[0], days[1:]...).At(h, m, 0).Starting(now), nil
+	case dow == "*" && month == "*":
+		d, err := strconv.Atoi(dom)
+		if err != nil {
+			break
+		}
+		return a.Every(1).Months().On(d).At(h, m, 0).Starting(now), nil
+	case dow == "*":
+		d, dErr := strconv.Atoi(dom)
+		mo, moErr := strconv.Atoi(month)
+		if dErr != nil || moErr != nil {
+			break
+		}
+		return a.Every(1).Years().In(time.Month(mo)).On(d).At(h, m, 0).Starting(now), nil
+	}
+	return nil, fmt.Errorf("%q is not supported", strings.Join([]string{minute, hour, dom, month, dow}, " "))
+}
+
+// cronStep returns the step of a `*/n` field
+func cronStep(field string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(field, "*/"))
+	if err != nil {
+		panic(fmt.Sprintf("%q is not a valid step", field))
+	}
+	return n
+}
+
+// cronWeekdays returns the weekdays of a day of the week field, ie `1,3,5`. Sunday can be 0 or 7
+func cronWeekdays(field string) ([]int, error) {
+	var days []int
+	for _, f := range strings.Split(field, ",") {
+		d, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a supported day of the week", field)
+		}
+		days = append(days, d%7)
+	}
+	return days, nil
+}
+
+// exportCrontab writes a crontab line for every job of `s` to `w`. The jobs that a crontab cannot express are written as comments
+func exportCrontab(s Scheduler, w io.Writer) error {
+	zone := time.Local
+	for _, a := range s.List() {
+		j, ok := a.(*job)
+		if !ok {
+			if _, err := fmt.Fprintf(w, "# %s: not created by this package\n", a.Name()); err != nil {
+				return err
+			}
+			continue
+		}
+		line, err := j.cronLine()
+		if err != nil {
+			line = fmt.Sprintf("# %s: %s", j.JobName, err)
+		} else if atomic.LoadInt32(&j.paused) == 1 {
+			line = "# paused: " + line
+		} else if loc := j.location(); loc.String() != zone.String() {
+			zone = loc
+			line = fmt.Sprintf("CRON_TZ=%s\n%s", zone, line)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cronLine returns the crontab line of the job, which runs a command named after the job,
+// or an error that describes why a crontab cannot express its schedule
+func (j *job) cronLine() (string, error) {
+	switch {
+	case j.timeOfDay != nil:
+		return "", fmt.Errorf("a time of day func can not be expressed in a crontab")
+	case j.WindowStart != j.WindowEnd:
+		return "", fmt.Errorf("a window can not be expressed in a crontab")
+	case j.calendar != nil:
+		return "", fmt.Errorf("a holiday calendar can not be expressed in a crontab")
+	case j.Occurrence != 0:
+		return "", fmt.Errorf("a weekday occurrence can not be expressed in a crontab")
+	case j.MissingDay == ClampMissingDay && j.Day > 28:
+		return "", fmt.Errorf("clamping to the last day of the month can not be expressed in a crontab")
+	case j.OffsetDuration != 0:
+		return "", fmt.Errorf("an offset can not be expressed in a crontab")
+	case len(j.rules) > 0:
+		return "", fmt.Errorf("more than one schedule can not be expressed in a crontab")
+	}
+	start := j.origin()
+	n := j.IntervalAmount
+	var fields string
+	switch {
+	case j.IntervalType == Minutes && 60%n == 0:
+		fields = fmt.Sprintf("%s * * * *", cronRange(start.Minute(), 0, 59, n))
+	case j.IntervalType == Hours && 24%n == 0:
+		fields = fmt.Sprintf("%d %s * * *", start.Minute(), cronRange(start.Hour(), 0, 23, n))
+	case j.IntervalType == Days && n == 1:
+		fields = fmt.Sprintf("%d %d * * *", j.Minute, j.Hour)
+	case j.IntervalType == Weekdays && n == 1:
+		fields = fmt.Sprintf("%d %d * * 1-5", j.Minute, j.Hour)
+	case j.IntervalType == Weeks && n == 1:
+		var days []string
+		for _, d := range j.weekdays() {
+			days = append(days, strconv.Itoa(d))
+		}
+		fields = fmt.Sprintf("%d %d * * %s", j.Minute, j.Hour, strings.Join(days, ","))
+	case j.IntervalType == Months && 12%n == 0:
+		fields = fmt.Sprintf("%d %d %d %s *", j.Minute, j.Hour, j.Day, cronRange(int(start.Month()), 1, 12, n))
+	case j.IntervalType == Years && n == 1:
+		fields = fmt.Sprintf("%d %d %d %d *", j.Minute, j.Hour, j.Day, j.Month)
+	default:
+		return "", fmt.Errorf("every %d %s can not be expressed in a crontab", n, j.IntervalType)
+	}
+	return fields + " " + j.JobName, nil
+}
+
+// cronRange returns the field of a crontab that matches every `step` values between `min` and `max` that are in step with `value`
+func cronRange(value, min, max, step int) string {
+	first := min + (value-min)%step
+	if step == 1 {
+		return "*"
+	} else if first == min {
+		return fmt.Sprintf("*/%d", step)
+	}
+	return fmt.Sprintf("%d-%d/%d", first, max, step)
+}
@@ -0,0 +1,263 @@
+package schedule
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"time"
+)
+
+// RetryPolicy determines how many times a failed task is attempted by a `Scheduler` returned from `WithRetries`.
+// A task fails when it panics
+type RetryPolicy struct {
+	// Attempts is the total number of times the task will be attempted. Values less than 1 are treated as 1
+	Attempts int
+
+	// Backoff is the amount of time to wait before the first retry
+	Backoff time.Duration
+
+	// Multiplier multiplies the `Backoff` after every retry. Values less than 1 are treated as 1
+	Multiplier float64
+}
+
+// delay returns the amount of time to wait before the next attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := float64(p.Backoff)
+	for i := 1; i < attempt && p.Multiplier > 1; i++ {
+		d *= p.Multiplier
+	}
+	return time.Duration(d)
+}
+
+// MetricsRegistry receives the outcome of every execution of a `Scheduler` returned from `WithMetrics`
+type MetricsRegistry interface {
+	// Observe is called after each execution with the time it took and the error it failed with, if any
+	Observe(j Job, d time.Duration, err error)
+}
+
+// RateLimiter limits the executions of a `Scheduler` returned from `WithRateLimit`.
+// `*rate.Limiter` from golang.org/x/time/rate satisfies this interface
+type RateLimiter interface {
+	// Allow reports whether an execution may happen now
+	Allow() bool
+}
+
+// WithRetries wraps `s` so that every job added through it is retried according to `policy`
+func WithRetries(s Scheduler, policy RetryPolicy) Scheduler {
+	return decorate(s, func(do func(Job, time.Time)) func(Job, time.Time) {
+		return func(j Job, t time.Time) {
+			quit := quitting(j)
+			for attempt := 1; ; attempt++ {
+				err := try(do, j, t)
+				if err == nil {
+					return
+				} else if attempt >= policy.Attempts {
+					log.Printf("schedule: %s failed after %d attempts: %s", j.Name(), attempt, err)
+					return
+				}
+
+				// give up the worker as soon as the scheduler stops instead of holding it for the rest of the backoff
+				timer := time.NewTimer(policy.delay(attempt))
+				select {
+				case <-timer.C:
+				case <-quit:
+					timer.Stop()
+					log.Printf("schedule: %s stopped retrying after %d attempts since the scheduler is stopping: %s", j.Name(), attempt, err)
+					return
+				}
+			}
+		}
+	})
+}
+
+// WithMetrics wraps `s` so that the outcome of every execution of the jobs added through it are reported to `reg`
+func WithMetrics(s Scheduler, reg MetricsRegistry) Scheduler {
+	return decorate(s, observe(reg))
+}
+
+// observe wraps the func of a job so that the outcome of its executions are reported to `reg`
+func observe(reg MetricsRegistry) func(func(Job, time.Time)) func(Job, time.Time) {
+	return func(do func(Job, time.Time)) func(Job, time.Time) {
+		return func(j Job, t time.Time) {
+			start := time.Now()
+			defer func() {
+				r := recover()
+				var err error
+				if r != nil {
+					err = fmt.Errorf("%v", r)
+				}
+				reg.Observe(j, time.Since(start), err)
+				if r != nil {
+					panic(r)
+				}
+			}()
+			do(j, t)
+		}
+	}
+}
+
+// WithRateLimit wraps `s` so that the jobs added through it are skipped when `rl` does not allow an execution
+func WithRateLimit(s Scheduler, rl RateLimiter) Scheduler {
+	return decorate(s, func(do func(Job, time.Time)) func(Job, time.Time) {
+		return func(j Job, t time.Time) {
+			if !rl.Allow() {
+				log.Printf("schedule: %s skipped by the rate limiter", j.Name())
+				return
+			}
+			do(j, t)
+		}
+	})
+}
+
+// Unwrapper is implemented by the decorators of a `Scheduler`, ie the ones returned from `WithRetries`. A decorator only implements
+// the optional interfaces of `Scheduler` that it can provide whatever it wraps, the others are found on the schedulers it wraps with `As`
+type Unwrapper interface {
+	// Unwrap returns the `Scheduler` that is decorated
+	Unwrap() Scheduler
+}
+
+// As sets `target` to the first scheduler of the chain of decorators that starts with `s` which implements the interface that `target` points to,
+// like `errors.As`, ie `var r schedule.Replayer; schedule.As(s, &r)`. It reports whether one was found,
+// and panics if `target` is not a non-nil pointer to an interface
+func As(s Scheduler, target interface{}) bool {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Interface {
+		panic("schedule: As expects a non-nil pointer to an interface")
+	}
+	for s != nil {
+		if reflect.TypeOf(s).Implements(v.Elem().Type()) {
+			v.Elem().Set(reflect.ValueOf(s))
+			return true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			return false
+		}
+		s = u.Unwrap()
+	}
+	return false
+}
+
+// innermost returns the scheduler created by `New` that `s` decorates, or nil if the chain of decorators does not end with one
+func innermost(s Scheduler) *scheduler {
+	for {
+		switch t := s.(type) {
+		case *scheduler:
+			return t
+		case Unwrapper:
+			s = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// stopping returns the channel that is closed when the scheduler created by `New` that `s` decorates is stopped.
+// The channel is nil, ie it never fires, when the scheduler isn't running or wasn't created by `New`
+func stopping(s Scheduler) <-chan struct{} {
+	inner := innermost(s)
+	if inner == nil {
+		return nil
+	}
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	return inner.quit
+}
+
+// quitting returns the channel that is closed when the scheduler executing `j` is stopped, see `stopping`
+func quitting(j Job) <-chan struct{} {
+	if r, ok := j.(replay); ok {
+		j = r.Job
+	}
+	jj, ok := j.(*job)
+	if !ok {
+		return nil
+	}
+	return stopping(jj.scheduler)
+}
+
+// decorator wraps the func of every job built through the `Scheduler` it embeds.
+// Any `Scheduler` can be wrapped, including third party decorators, but funcs can only be wrapped
+// when the innermost `Scheduler` was created by `New`
+type decorator struct {
+	Scheduler
+	wrap func(func(Job, time.Time)) func(Job, time.Time)
+
+	// self is the outermost value of the decorator, which the jobs refer to
+	self Scheduler
+}
+
+// replacingDecorator is a `decorator` of a `Replacer`, which is a `Replacer` as well
+type replacingDecorator struct {
+	*decorator
+}
+
+// decorate returns a decorator of `s` that wraps the funcs of its jobs with `wrap`. It is a `Replacer` if `s` is one
+func decorate(s Scheduler, wrap func(func(Job, time.Time)) func(Job, time.Time)) Scheduler {
+	d := &decorator{Scheduler: s, wrap: wrap}
+	d.self = d
+	if _, ok := s.(Replacer); ok {
+		r := &replacingDecorator{d}
+		d.self = r
+		return r
+	}
+	return d
+}
+
+// Add create a new job ascociated with the scheduler and returns its first builder method
+// Note: the job will refer to the outermost decorator and its func is wrapped when `Do` is called
+func (d *decorator) Add(name string) Amount {
+	return d.adopt(d.Scheduler.Add(name))
+}
+
+// AddOrReplace create a new job that replaces the job named `name` if it was already added
+// Note: like `Add`, the job will refer to the outermost decorator and its func is wrapped when `Do` is called
+func (d *replacingDecorator) AddOrReplace(name string) Amount {
+	return d.adopt(d.Scheduler.(Replacer).AddOrReplace(name))
+}
+
+// adopt makes the job built by `a` refer to the decorator and wraps its func
+func (d *decorator) adopt(a Amount) Amount {
+	if j, ok := a.(*job); ok {
+		j.scheduler = d.self
+		j.wraps = append(j.wraps, d.wrap)
+	}
+	return a
+}
+
+// Unwrap implements `Unwrapper`
+func (d *decorator) Unwrap() Scheduler {
+	return d.Scheduler
+}
+
+// Schedule adds the job described by `spec` to the scheduler through the decorator
+func (d *decorator) Schedule(spec JobSpec) error {
+	return schedule(d.self, spec)
+}
+
+// ScheduleAll adds the jobs described by `specs` to the scheduler through the decorator
+func (d *decorator) ScheduleAll(specs []JobSpec) error {
+	return scheduleAll(d.self, specs)
+}
+
+// LoadCrontab adds a job for every line of the crontab read from `r` through the decorator
+func (d *decorator) LoadCrontab(r io.Reader, registry map[string]TaskFunc) error {
+	return loadCrontab(d.self, r, registry)
+}
+
+// ExportCrontab writes an equivalent crontab line for every job of the decorated scheduler to `w`
+func (d *decorator) ExportCrontab(w io.Writer) error {
+	return exportCrontab(d, w)
+}
+
+// try calls `do` and returns any panic as an error
+func try(do func(Job, time.Time), j Job, t time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	do(j, t)
+	return nil
+}
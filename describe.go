@@ -0,0 +1,172 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Catalog is the phrasing of the descriptions of the jobs in a language, see `RegisterCatalog`.
+// The placeholders of its sentences are replaced by the parts of the schedule of a job, so that a language can put them in any order
+type Catalog struct {
+	// Sentences describe the jobs of each interval. Their placeholders are {every}, {date}, {time}, {day}, {month} and {weekdays}
+	Sentences map[IntervalType]string
+
+	// Units are the singular and plural names of the intervals, ie {"day", "days"}. {every} is the singular name when the amount is 1
+	Units map[IntervalType][2]string
+
+	// Every formats {every} with the amount and the plural name of the interval when the amount is more than 1, ie "%d %s"
+	Every string
+
+	// Day formats the day of the month as {day}, ie "day %d"
+	Day string
+
+	// Occurrence formats {day} with the ordinal and the name of the weekday for the jobs that run on the nth weekday of the month,
+	// ie "the %s %s"
+	Occurrence string
+
+	// Ordinals name the occurrences of a weekday in a month, from 1 to 5 and from -1 to -5, ie "second" or "last"
+	Ordinals map[int]string
+
+	// Months are the names of the months for {month}, starting with January
+	Months [12]string
+
+	// Weekdays are the names of the weekdays for {weekdays}, starting with Sunday
+	Weekdays [7]string
+
+	// And joins the last two {weekdays}, the others are joined with a comma
+	And string
+
+	// DateLayout and TimeLayout format {date} and {time} like `time.Time.Format`
+	DateLayout, TimeLayout string
+
+	// VariableTime is the {time} of the jobs whose time of day is set by `Time.AtTimeOf`
+	VariableTime string
+}
+
+// English is the `Catalog` of the descriptions that `JobInfo.Description` returns
+var English = Catalog{
+	Sentences: map[IntervalType]string{
+		Once:         "once on {date} at {time}",
+		Years:        "every {every} on {day} of {month} at {time}",
+		Months:       "every {every} on {day} at {time}",
+		Weeks:        "every {every} on {weekdays} at {time}",
+		Days:         "every {every} at {time}",
+		Weekdays:     "every {every} at {time}",
+		Hours:        "every {every}",
+		Minutes:      "every {every}",
+		Seconds:      "every {every}",
+		Milliseconds: "every {every}",
+	},
+	Units: map[IntervalType][2]string{
+		Years:        {"year", "years"},
+		Months:       {"month", "months"},
+		Weeks:        {"week", "weeks"},
+		Days:         {"day", "days"},
+		Weekdays:     {"weekday", "weekdays"},
+		Hours:        {"hour", "hours"},
+		Minutes:      {"minute", "minutes"},
+		Seconds:      {"second", "seconds"},
+		Milliseconds: {"millisecond", "milliseconds"},
+	},
+	Every:      "%d %s",
+	Day:        "day %d",
+	Occurrence: "the %s %s",
+	Ordinals: map[int]string{
+		1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth",
+		-1: "last", -2: "second to last", -3: "third to last", -4: "fourth to last", -5: "fifth to last",
+	},
+	Months: [12]string{
+		"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December",
+	},
+	Weekdays:     [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	And:          " and ",
+	DateLayout:   "2006-01-02",
+	TimeLayout:   "15:04:05",
+	VariableTime: "a time of day that changes",
+}
+
+// catalogs holds the catalogs passed to `RegisterCatalog` by locale
+var catalogs = struct {
+	sync.Mutex
+	locales map[string]Catalog
+}{locales: map[string]Catalog{"en": English}}
+
+// RegisterCatalog makes the descriptions of the jobs available in `locale`, ie "de" or "pt-BR", so that `JobInfo.Describe` can
+// render them in the language of a user
+func RegisterCatalog(locale string, c Catalog) {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	catalogs.locales[strings.ToLower(locale)] = c
+}
+
+// catalog returns the catalog of `locale`, falling back on the catalog of its language ("pt" for "pt-BR") and then on `English`
+func catalog(locale string) Catalog {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	if c, ok := catalogs.locales[locale]; ok {
+		return c
+	} else if c, ok := catalogs.locales[strings.Split(locale, "-")[0]]; ok {
+		return c
+	}
+	return English
+}
+
+// Description is a plain english sentence that describes when this job is executed
+func (j *job) Description() string {
+	return j.describe(English)
+}
+
+// Describe is a sentence that describes when this job is executed in the language of `locale`
+func (j *job) Describe(locale string) string {
+	return j.describe(catalog(locale))
+}
+
+// describe replaces the placeholders of the sentence of the interval of the job in `c`
+func (j *job) describe(c Catalog) string {
+	loc := j.location()
+	every := fmt.Sprintf(c.Every, j.IntervalAmount, c.Units[j.IntervalType][1])
+	if j.IntervalAmount == 1 {
+		every = c.Units[j.IntervalType][0]
+	}
+	at := time.Date(2000, time.January, 1, j.Hour, j.Minute, j.Second, 0, loc).Format(c.TimeLayout)
+	if j.timeOfDay != nil {
+		at = c.VariableTime
+	}
+	date := ""
+	if j.IntervalType == Once {
+		date, at = j.StartAt.In(loc).Format(c.DateLayout), j.StartAt.In(loc).Format(c.TimeLayout)
+	}
+	day := fmt.Sprintf(c.Day, j.Day)
+	if j.Occurrence != 0 {
+		day = fmt.Sprintf(c.Occurrence, c.Ordinals[j.Occurrence], c.Weekdays[j.Weekday%7])
+	}
+	month := ""
+	if j.Month >= 1 && j.Month <= 12 {
+		month = c.Months[j.Month-1]
+	}
+	var weekdays []string
+	for _, d := range j.weekdays() {
+		weekdays = append(weekdays, c.Weekdays[d%7])
+	}
+	names := strings.Join(weekdays, c.And)
+	if len(weekdays) > 2 {
+		names = strings.Join(weekdays[:len(weekdays)-1], ", ") + c.And + weekdays[len(weekdays)-1]
+	}
+	sentence := strings.NewReplacer(
+		"{every}", every,
+		"{date}", date,
+		"{time}", at,
+		"{day}", day,
+		"{month}", month,
+		"{weekdays}", names,
+	).Replace(c.Sentences[j.IntervalType])
+
+	// the schedules added by `Task.Also` are described after the schedule of the job
+	for _, r := range j.rules {
+		sentence += c.And + r.describe(c)
+	}
+	return sentence
+}
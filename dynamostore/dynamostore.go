@@ -0,0 +1,174 @@
+// Package dynamostore implements a `schedule.Store` with a DynamoDB table, so that fleets on AWS
+// can synchronize their schedulers without managing a relational database
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/marksalpeter/schedule"
+)
+
+// maxAttempts is the number of times `Store.Add` is attempted when another instance writes the same job concurrently
+const maxAttempts = 3
+
+// Client is the part of the DynamoDB api used by `Store`. `*dynamodb.Client` satisfies this interface
+type Client interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+// Store is a `schedule.Store` that keeps the records of the jobs in a DynamoDB table.
+// The table must have the string partition key "scheduler" and the string sort key "job_name".
+// Executions are claimed with conditional writes on the version of the record, so no locks are held
+type Store struct {
+	client Client
+	table  string
+}
+
+// New creates a `Store` that uses the table named `table`
+func New(client Client, table string) *Store {
+	return &Store{
+		client: client,
+		table:  table,
+	}
+}
+
+// Add implements `schedule.Store`
+func (s *Store) Add(scheduler string, r *schedule.Record) error {
+	for attempt := 1; ; attempt++ {
+		stored, err := s.get(scheduler, r.JobName)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			r.Merge(stored)
+		}
+		err = s.put(scheduler, r, stored)
+		if !conditionFailed(err) || attempt >= maxAttempts {
+			return err
+		}
+	}
+}
+
+// Claim implements `schedule.Store`
+func (s *Store) Claim(scheduler string, r *schedule.Record) error {
+	stored, err := s.get(scheduler, r.JobName)
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return errors.New(r.JobName + " has not been added to the store")
+	} else if err := r.Claim(stored); err != nil {
+		return err
+	}
+
+	// another instance saved the record first
+	if err := s.put(scheduler, r, stored); conditionFailed(err) {
+		return schedule.ErrAlreadyExecuted
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// List implements `schedule.Lister`
+func (s *Store) List(scheduler string) ([]schedule.Record, error) {
+	var records []schedule.Record
+	var start map[string]types.AttributeValue
+	for {
+		out, err := s.client.Query(context.Background(), &dynamodb.QueryInput{
+			TableName:                 aws.String(s.table),
+			KeyConditionExpression:    aws.String("scheduler = :scheduler"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":scheduler": &types.AttributeValueMemberS{Value: scheduler}},
+			ExclusiveStartKey:         start,
+			ConsistentRead:            aws.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			r, err := unmarshal(item)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, *r)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return records, nil
+		}
+		start = out.LastEvaluatedKey
+	}
+}
+
+// get returns the stored record of the job named `name`, or nil if it is not stored
+func (s *Store) get(scheduler, name string) (*schedule.Record, error) {
+	out, err := s.client.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            key(scheduler, name),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	} else if out.Item == nil {
+		return nil, nil
+	}
+	return unmarshal(out.Item)
+}
+
+// put saves `r` on the condition that the stored record is still `stored`, or that there is no stored record if it is nil
+func (s *Store) put(scheduler string, r, stored *schedule.Record) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	item := key(scheduler, r.JobName)
+	item["record"] = &types.AttributeValueMemberS{Value: string(v)}
+	item["version"] = &types.AttributeValueMemberN{Value: strconv.Itoa(r.Version)}
+	input := dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+	}
+	if stored == nil {
+		input.ConditionExpression = aws.String("attribute_not_exists(job_name)")
+	} else {
+		input.ConditionExpression = aws.String("version = :version")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(stored.Version)},
+		}
+	}
+	_, err = s.client.PutItem(context.Background(), &input)
+	return err
+}
+
+// key returns the primary key of the job named `name`
+func key(scheduler, name string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"scheduler": &types.AttributeValueMemberS{Value: scheduler},
+		"job_name":  &types.AttributeValueMemberS{Value: name},
+	}
+}
+
+// unmarshal returns the record stored in `item`
+func unmarshal(item map[string]types.AttributeValue) (*schedule.Record, error) {
+	v, ok := item["record"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("the item does not have a record")
+	}
+	var r schedule.Record
+	if err := json.Unmarshal([]byte(v.Value), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// conditionFailed reports whether `err` is the failure of the condition of a write
+func conditionFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
@@ -0,0 +1,186 @@
+package dynamostore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/dynamostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd(t *testing.T) {
+	c := newClient()
+	store := dynamostore.New(c, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+	if assert.Len(c.items, 1) {
+		assert.Equal("attribute_not_exists(job_name)", c.conditions[0], "a new record is only put if there is none")
+	}
+}
+
+func TestAddMerge(t *testing.T) {
+	c := newClient()
+	store := dynamostore.New(c, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once, RunCount: 1}))
+	assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+	if assert.Len(c.conditions, 2) {
+		assert.Equal("version = :version", c.conditions[1], "an existing record is only put if it did not change")
+	}
+	records, err := store.List("dynamo-test")
+	assert.NoError(err)
+	if assert.Len(records, 1) {
+		assert.Equal(1, records[0].RunCount, "the runs of the stored record are kept")
+	}
+}
+
+func TestAddRetry(t *testing.T) {
+	c := newClient()
+	store := dynamostore.New(c, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+
+	// another instance writes the job between the read and the write of the first attempt
+	c.conflicts = 1
+	assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), "the add is retried")
+
+	// another instance keeps writing the job
+	c.conflicts = 10
+	var ccf *types.ConditionalCheckFailedException
+	assert.True(errors.As(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), &ccf),
+		"the add gives up after the last attempt")
+	assert.Equal(7, c.conflicts, "the add is attempted 3 times")
+}
+
+func TestClaimConflict(t *testing.T) {
+	c := newClient()
+	store := dynamostore.New(c, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+
+	// another instance claimed the execution first
+	c.conflicts = 1
+	assert.Equal(schedule.ErrAlreadyExecuted, store.Claim("dynamo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+}
+
+func TestListPages(t *testing.T) {
+	c := newClient()
+	c.pageSize = 2
+	store := dynamostore.New(c, "schedule")
+	assert := assert.New(t)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(store.Add("dynamo-test", &schedule.Record{JobName: name, IntervalType: schedule.Once}))
+	}
+	assert.NoError(store.Add("other", &schedule.Record{JobName: "f", IntervalType: schedule.Once}))
+
+	records, err := store.List("dynamo-test")
+	assert.NoError(err)
+	var names []string
+	for _, r := range records {
+		names = append(names, r.JobName)
+	}
+	assert.Equal([]string{"a", "b", "c", "d", "e"}, names, "all of the pages are read")
+	assert.Equal(3, c.queries)
+}
+
+// client is a fake of the DynamoDB api that keeps the items of a single table in memory
+type client struct {
+	mu         sync.Mutex
+	items      map[string]map[string]types.AttributeValue
+	conditions []string
+	conflicts  int
+	pageSize   int
+	queries    int
+}
+
+func newClient() *client {
+	return &client{items: map[string]map[string]types.AttributeValue{}, pageSize: 100}
+}
+
+func (c *client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: c.items[key(params.Key)]}, nil
+}
+
+func (c *client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	condition := aws.ToString(params.ConditionExpression)
+	c.conditions = append(c.conditions, condition)
+	stored, ok := c.items[key(params.Item)]
+	failed := &types.ConditionalCheckFailedException{Message: aws.String("the conditional request failed")}
+	switch condition {
+	case "attribute_not_exists(job_name)":
+		if ok {
+			return nil, failed
+		}
+	case "version = :version":
+		if !ok || number(stored["version"]) != number(params.ExpressionAttributeValues[":version"]) {
+			return nil, failed
+		}
+	}
+	if c.conflicts > 0 {
+		// another instance writes the item first
+		c.conflicts--
+		return nil, failed
+	}
+	c.items[key(params.Item)] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c *client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries++
+	scheduler := params.ExpressionAttributeValues[":scheduler"].(*types.AttributeValueMemberS).Value
+	var keys []string
+	for k, item := range c.items {
+		if item["scheduler"].(*types.AttributeValueMemberS).Value == scheduler {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if params.ExclusiveStartKey != nil {
+		start := key(params.ExclusiveStartKey)
+		i := sort.SearchStrings(keys, start)
+		if i < len(keys) && keys[i] == start {
+			i++
+		}
+		keys = keys[i:]
+	}
+	var out dynamodb.QueryOutput
+	for i, k := range keys {
+		if i == c.pageSize {
+			last := out.Items[len(out.Items)-1]
+			out.LastEvaluatedKey = map[string]types.AttributeValue{"scheduler": last["scheduler"], "job_name": last["job_name"]}
+			break
+		}
+		out.Items = append(out.Items, c.items[k])
+	}
+	return &out, nil
+}
+
+// key returns the primary key of `item`
+func key(item map[string]types.AttributeValue) string {
+	k, _ := json.Marshal([]string{
+		item["scheduler"].(*types.AttributeValueMemberS).Value,
+		item["job_name"].(*types.AttributeValueMemberS).Value,
+	})
+	return string(k)
+}
+
+// number returns the value of a number attribute
+func number(v types.AttributeValue) int {
+	n, _ := strconv.Atoi(v.(*types.AttributeValueMemberN).Value)
+	return n
+}
@@ -0,0 +1,69 @@
+package schedule
+
+import "time"
+
+// eventBuffer is the number of events that are buffered for a slow consumer before new events are dropped
+const eventBuffer = 256
+
+// EventType is the kind of an `Event`
+type EventType string
+
+const (
+	// JobScheduled is emitted when a job is added to the scheduler
+	JobScheduled = EventType("scheduled")
+
+	// JobStarted is emitted when an execution starts
+	JobStarted = EventType("started")
+
+	// JobFinished is emitted when an execution finishes without panicking
+	JobFinished = EventType("finished")
+
+	// JobFailed is emitted when an execution panics
+	JobFailed = EventType("failed")
+
+	// JobSkipped is emitted when an execution is skipped because the job is paused, outside of its `Task.Between` window,
+	// on a holiday or denied by the `Gatekeeper`
+	JobSkipped = EventType("skipped")
+
+	// JobDeferred is emitted when an execution is deferred because the `Task.RequiresHealthy` check of its job failed
+	JobDeferred = EventType("deferred")
+
+	// JobClaimedElsewhere is emitted when an execution is skipped because another instance already performed it
+	JobClaimedElsewhere = EventType("claimed")
+
+	// JobDropped is emitted when an execution is dropped because the queue of the workers is full, see `QueuePolicy`
+	JobDropped = EventType("dropped")
+
+	// JobExpired is emitted when a job is removed because it was inactive for longer than its `Task.ExpireAfter` duration,
+	// or because it completed and `Config.RemoveCompleted` is set
+	JobExpired = EventType("expired")
+
+	// StoreDegraded is emitted when an execution is skipped because the connection to the database dropped
+	// and could not be reestablished in time
+	StoreDegraded = EventType("degraded")
+
+	// StoreRecovered is emitted when an execution is claimed again after the store was degraded
+	StoreRecovered = EventType("recovered")
+)
+
+// Event is a structured record of something that happened to a job, ie for custom monitoring
+type Event struct {
+	// Type is the kind of event
+	Type EventType
+
+	// Job is the job the event is about
+	Job Job
+
+	// Time is when the event happened
+	Time time.Time
+
+	// ScheduledAt is the time the execution the event is about was due
+	ScheduledAt time.Time
+
+	// Duration is the amount of time the execution took. It is only set for `JobFinished` and `JobFailed` events
+	Duration time.Duration
+
+	// Err is the error the execution failed with. It is only set for `JobFailed` and `StoreDegraded` events,
+	// for the `JobSkipped` events of the executions that the `Gatekeeper` denied and for `JobDeferred` events
+	Err error
+}
@@ -0,0 +1,93 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// FileStore is a `Store` that saves the records of the jobs to a json file, so that a single instance
+// without a database keeps track of the runs of its jobs across restarts. It does not synchronize several instances
+type FileStore struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]map[string]Record
+}
+
+// NewFileStore creates a `FileStore` that saves to the file at `path`, loading the records in it if it exists
+func NewFileStore(path string) (*FileStore, error) {
+	fs := FileStore{
+		path:    path,
+		records: map[string]map[string]Record{},
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &fs, nil
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(b, &fs.records); err != nil {
+		return nil, err
+	}
+	return &fs, nil
+}
+
+// Add implements `Store`
+func (fs *FileStore) Add(scheduler string, r *Record) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.records[scheduler] == nil {
+		fs.records[scheduler] = map[string]Record{}
+	}
+	if stored, ok := fs.records[scheduler][r.JobName]; ok {
+		r.Merge(&stored)
+		if stored.Checksum == r.Checksum || stored.Checksum == r.legacyChecksum() {
+			r.Resume(&stored)
+		}
+	}
+	fs.records[scheduler][r.JobName] = *r
+	return fs.save()
+}
+
+// Claim implements `Store`
+func (fs *FileStore) Claim(scheduler string, r *Record) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	stored, ok := fs.records[scheduler][r.JobName]
+	if !ok {
+		return errors.New(r.JobName + " has not been added to the store")
+	} else if err := r.Claim(&stored); err != nil {
+		return err
+	}
+	fs.records[scheduler][r.JobName] = *r
+	return fs.save()
+}
+
+// List implements `Lister`
+func (fs *FileStore) List(scheduler string) ([]Record, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var records []Record
+	for _, r := range fs.records[scheduler] {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].JobName < records[j].JobName
+	})
+	return records, nil
+}
+
+// save writes every record to the file, replacing it atomically so that a crash cannot leave it half written
+func (fs *FileStore) save() error {
+	b, err := json.MarshalIndent(fs.records, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := fs.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.path)
+}
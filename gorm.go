@@ -0,0 +1,388 @@
+//go:build !nogorm
+
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql" // import the sql driver
+)
+
+func init() {
+	connectionErrors = append(connectionErrors, mysql.ErrInvalidConn)
+}
+
+// gormBuilt reports whether gorm is part of the build, see the `nogorm` build tag
+const gormBuilt = true
+
+// gormStore implements `Store` with a mysql database
+type gormStore struct {
+	db          *gorm.DB
+	missing     []string
+	strategy    ClaimStrategy
+	lockTimeout time.Duration
+	skipLocked  bool
+}
+
+// newGormStore opens the mysql database in `cfg` and migrates the table named `name`
+func newGormStore(name string, cfg *Config) (*gormStore, error) {
+	if err := validTableName(name); err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open("mysql", dsn(cfg))
+	if err != nil {
+		return nil, err
+	}
+	db.SingularTable(true)
+	db.LogMode(cfg.LogDB)
+	if cfg.MaxOpenConns > 0 {
+		db.DB().SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.DB().SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.DB().SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	var gs gormStore
+	gs.db = db
+	gs.strategy = cfg.ClaimStrategy
+	gs.lockTimeout = cfg.LockTimeout
+	gs.skipLocked = cfg.SkipLocked
+	if !cfg.AutoMigrate {
+		// the table is created by `Migrate`, so it is only checked
+		missing, err := missingColumns(db, name)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s, see `Migrate` or `Config.AutoMigrate`", err)
+		}
+		gs.compatible(name, missing)
+		if imprecise, _ := sqlDialects["mysql"].imprecise(db.DB(), name); imprecise {
+			log.Printf("schedule: %s truncates its times to seconds, see `Migrate`", name)
+		}
+	} else if err := sqlDialects["mysql"].migrateAudit(db.DB(), name); err != nil {
+		return nil, err
+	} else if err := db.Exec(sqlDialects["mysql"].createMembers(name)).Error; err != nil {
+		return nil, err
+	} else if err := db.Table(name).AutoMigrate(&Record{}).Error; err != nil {
+		// fall back to the columns of an older version of the table if it exists
+		missing, cErr := missingColumns(db, name)
+		if cErr != nil {
+			return nil, err
+		}
+		log.Printf("schedule: %s could not be migrated, running in compatibility mode: %s", name, err)
+		gs.compatible(name, missing)
+	} else if err := sqlDialects["mysql"].migrateTimes(db.DB(), name); err != nil {
+		return nil, err
+	}
+	return &gs, nil
+}
+
+// compatible leaves out the columns that an older version of the table named `name` is missing
+func (gs *gormStore) compatible(name string, missing []string) {
+	gs.missing = missing
+	for _, c := range missing {
+		if c == "version" && gs.strategy == OptimisticLocking {
+			log.Printf("schedule: %s is missing the version column, falling back to pessimistic locking", name)
+			gs.strategy = PessimisticLocking
+		}
+	}
+}
+
+// dsn returns `Config.DSN` or builds the mysql data source name from the rest of the config
+func dsn(cfg *Config) string {
+	if len(cfg.DSN) > 0 {
+		return cfg.DSN
+	}
+	params := url.Values{}
+	params.Set("charset", "utf8")
+	params.Set("parseTime", "True")
+	params.Set("loc", "UTC")
+	for k, v := range cfg.Params {
+		params.Set(k, v)
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s", cfg.Username, cfg.Password, cfg.Instance, cfg.Database, params.Encode())
+}
+
+// Add implements `Store`
+func (gs *gormStore) Add(scheduler string, r *Record) error {
+	// select the job from the database
+	tx := gs.db.Begin()
+	var dbR Record
+	if err := tx.Raw(selectForUpdate(scheduler), r.JobName).Scan(&dbR).Error; err == gorm.ErrRecordNotFound {
+		// create a new job in the database
+		if err := tx.Table(scheduler).Omit(gs.missing...).Create(r).Error; err != nil {
+			if err := tx.Rollback().Error; err != nil {
+				log.Println(err)
+				return nil
+			}
+			log.Println(err)
+			return nil
+		}
+
+	} else if err != nil {
+		// catasriphic server error
+		if err := tx.Rollback().Error; err != nil {
+			return err
+		}
+		return err
+	} else {
+		// the state shared by every instance must survive restarts
+		r.Merge(&dbR)
+		if err := tx.Table(scheduler).Omit(gs.missing...).Save(r).Error; err != nil {
+			if err := tx.Rollback().Error; err != nil {
+				return err
+			}
+			return err
+		}
+	}
+	// commit the change to the db
+	if err := tx.Commit().Error; err != nil {
+		if err := tx.Rollback().Error; err != nil {
+			return err
+		}
+		log.Println(err)
+	}
+	return nil
+}
+
+// AddAll implements `BulkAdder`. It selects and locks every stored record of the scheduler in one query
+// and saves the records in a single transaction
+func (gs *gormStore) AddAll(scheduler string, rs []*Record) error {
+	tx := gs.db.Begin()
+	var records []Record
+	if err := tx.Raw(fmt.Sprintf("select * from %s for update", sqlDialects["mysql"].quoteTable(scheduler))).Scan(&records).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	stored := map[string]Record{}
+	for _, r := range records {
+		stored[r.JobName] = r
+	}
+	for _, r := range rs {
+		var err error
+		if s, ok := stored[r.JobName]; ok {
+			// the state shared by every instance must survive restarts
+			r.Merge(&s)
+			err = tx.Table(scheduler).Omit(gs.missing...).Save(r).Error
+		} else {
+			err = tx.Table(scheduler).Omit(gs.missing...).Create(r).Error
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// Claim implements `Store`
+func (gs *gormStore) Claim(scheduler string, r *Record) error {
+	if gs.strategy == OptimisticLocking {
+		return gs.claimOptimistic(scheduler, r)
+	} else if gs.strategy == AdvisoryLocking {
+		return gs.claimAdvisory(scheduler, r)
+	}
+	var dbR Record
+	tx := gs.db.Begin()
+
+	// the transaction holds its connection until it ends, so the lock timeout of the claim is restored before the connection is released
+	restore, err := sqlDialects["mysql"].waitLocks(tx.CommonDB(), gs.lockTimeout)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	query := selectForUpdate(scheduler)
+	if gs.skipLocked {
+		query += " skip locked"
+	}
+	err = tx.Raw(query, r.JobName).Scan(&dbR).Error
+	if rErr := restore(); rErr != nil {
+		tx.Rollback()
+		return rErr
+	}
+	if err != nil {
+		if err := tx.Rollback().Error; err != nil {
+			return err
+		}
+		if (err == gorm.ErrRecordNotFound && gs.skipLocked) || isLockTimeout(err) {
+			// another instance is claiming the execution
+			return ErrAlreadyExecuted
+		}
+		return err
+	}
+	// check to see if another instance using the same database already performed this execution
+	if err := r.Claim(&dbR); err != nil {
+		if err := tx.Rollback().Error; err != nil {
+			return err
+		}
+		return err
+	}
+	// save our new run info
+	if err := tx.Table(scheduler).Omit(gs.missing...).Save(r).Error; err != nil {
+		if err := tx.Rollback().Error; err != nil {
+			return err
+		}
+		return err
+	}
+	// commit the change to the db
+	if err := tx.Commit().Error; err != nil {
+		if err := tx.Rollback().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List implements `Lister`
+func (gs *gormStore) List(scheduler string) ([]Record, error) {
+	var records []Record
+	if err := gs.db.Table(scheduler).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Pause implements `Editor`
+func (gs *gormStore) Pause(scheduler, name string, paused bool) error {
+	return gs.db.Table(scheduler).Where("job_name = ?", name).UpdateColumns(map[string]interface{}{
+		"paused":  paused,
+		"version": gorm.Expr("version + 1"),
+	}).Error
+}
+
+// Disable implements `Disabler`
+func (gs *gormStore) Disable(scheduler, name string, disabled bool) error {
+	return gs.db.Table(scheduler).Where("job_name = ?", name).UpdateColumns(map[string]interface{}{
+		"disabled": disabled,
+		"version":  gorm.Expr("version + 1"),
+	}).Error
+}
+
+// Finish implements `Finisher`
+func (gs *gormStore) Finish(scheduler string, r *Record, duration time.Duration, err error) error {
+	for _, c := range gs.missing {
+		if c == "finish_count" {
+			return nil
+		}
+	}
+	updates := map[string]interface{}{
+		"finish_count":   gorm.Expr("finish_count + 1"),
+		"total_duration": gorm.Expr("total_duration + ?", int64(duration)),
+		"job_duration":   int64(duration),
+		"version":        gorm.Expr("version + 1"),
+	}
+	if err != nil {
+		updates["failure_count"] = gorm.Expr("failure_count + 1")
+		updates["last_error"] = err.Error()
+	} else {
+		updates["pending_until"] = time.Time{}
+	}
+	for _, c := range gs.missing {
+		delete(updates, c)
+	}
+	return gs.db.Table(scheduler).Where("job_name = ?", r.JobName).UpdateColumns(updates).Error
+}
+
+// Remove implements `Editor`
+func (gs *gormStore) Remove(scheduler, name string) error {
+	return gs.db.Table(scheduler).Where("job_name = ?", name).Delete(&Record{}).Error
+}
+
+// Audit implements `Auditor`. The audit log is kept in a table named after the table of the scheduler with an "_audit" suffix
+func (gs *gormStore) Audit(scheduler string, e AuditEntry) error {
+	return sqlDialects["mysql"].audit(gs.db.DB(), scheduler, e)
+}
+
+// History implements `Auditor`
+func (gs *gormStore) History(scheduler, name string) ([]AuditEntry, error) {
+	return sqlDialects["mysql"].history(gs.db.DB(), scheduler, name)
+}
+
+// Now implements `Clock`
+func (gs *gormStore) Now() (time.Time, error) {
+	return sqlDialects["mysql"].clock(gs.db.DB())
+}
+
+// Ping implements `Pinger`
+func (gs *gormStore) Ping(ctx context.Context) error {
+	return gs.db.DB().PingContext(ctx)
+}
+
+// selectForUpdate returns the query that selects and locks the row of a job in the table of `scheduler`.
+// The name of the job is bound as its only parameter
+func selectForUpdate(scheduler string) string {
+	return fmt.Sprintf("select * from %s where `job_name` = ? for update", sqlDialects["mysql"].quoteTable(scheduler))
+}
+
+// claimOptimistic claims the execution without holding a lock by only saving `r` if the stored record
+// is still the version that it was claimed over
+func (gs *gormStore) claimOptimistic(scheduler string, r *Record) error {
+	var dbR Record
+	if err := gs.db.Table(scheduler).Where("job_name = ?", r.JobName).First(&dbR).Error; err != nil {
+		return err
+	} else if err := r.Claim(&dbR); err != nil {
+		return err
+	}
+
+	// save our new run info unless another instance saved first
+	columns, fields := recordColumns(r)
+	updates := map[string]interface{}{}
+	for i, v := range values(fields) {
+		updates[columns[i]] = v
+	}
+	for _, c := range gs.missing {
+		delete(updates, c)
+	}
+	result := gs.db.Table(scheduler).Where("job_name = ? AND version = ?", r.JobName, dbR.Version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected == 0 {
+		return ErrAlreadyExecuted
+	}
+	return nil
+}
+
+// claimAdvisory claims the execution while holding the advisory lock of the job instead of locking its row
+func (gs *gormStore) claimAdvisory(scheduler string, r *Record) error {
+	unlock, err := sqlDialects["mysql"].advisoryLock(gs.db.DB(), scheduler, r.JobName, gs.lockTimeout, gs.skipLocked)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var dbR Record
+	if err := gs.db.Table(scheduler).Where("job_name = ?", r.JobName).First(&dbR).Error; err != nil {
+		return err
+	} else if err := r.Claim(&dbR); err != nil {
+		return err
+	}
+	return gs.db.Table(scheduler).Omit(gs.missing...).Save(r).Error
+}
+
+// missingColumns returns the columns that the table is missing when it was created by an older version of this package.
+// The features that depend on the missing columns will only work in memory.
+// It returns an error if the table does not exist or is missing the columns needed to synchronize jobs
+func missingColumns(db *gorm.DB, table string) ([]string, error) {
+	if !db.Dialect().HasTable(table) {
+		return nil, fmt.Errorf("%s does not exist", table)
+	}
+	var missing []string
+	for _, f := range db.NewScope(&Record{}).Fields() {
+		if !f.IsNormal || db.Dialect().HasColumn(table, f.DBName) {
+			continue
+		}
+		switch f.DBName {
+		case "job_name", "last_run_at", "next_run_at":
+			return nil, fmt.Errorf("%s is missing the %s column", table, f.DBName)
+		}
+		log.Printf("schedule: %s is missing the %s column, the features that depend on it will not be synchronized", table, f.DBName)
+		missing = append(missing, f.DBName)
+	}
+	return missing, nil
+}
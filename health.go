@@ -0,0 +1,37 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// stalledTicks is the number of ticks that the ticker can miss before `Monitor.Healthy` reports it as stalled
+const stalledTicks = 5
+
+// Healthy returns an error if the scheduler is not running, its ticker is stalled, its database cannot be reached before `ctx` is done
+// or a job is overdue by more than `Config.OverdueThreshold`
+func (s *scheduler) Healthy(ctx context.Context) error {
+	s.mu.Lock()
+	running, draining, lastTick, overdue, late := s.quit != nil, s.draining, s.lastTick, s.overdue, s.late
+	s.mu.Unlock()
+	if !running {
+		return fmt.Errorf("%s is not running", s.name)
+	} else if since := time.Since(lastTick); since > stalledTicks*s.tick() {
+		return fmt.Errorf("%s has not ticked for %s", s.name, since.Truncate(time.Millisecond))
+	}
+
+	// the database is reachable
+	if p, ok := s.store.(Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("%s cannot reach its database: %s", s.name, err)
+		}
+	}
+
+	// a drained scheduler does not claim the executions that are due. The jobs are checked on every tick,
+	// since their times can only be read while they are not executing
+	if late += time.Since(lastTick); !draining && len(overdue) > 0 && late > s.overdueThreshold {
+		return fmt.Errorf("%s is overdue by %s", overdue, late.Truncate(time.Millisecond))
+	}
+	return nil
+}
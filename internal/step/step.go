@@ -0,0 +1,9 @@
+// Package step steps a scheduler through time for the simulate and scheduletest packages, without making it part of the api of schedule
+package step
+
+import "time"
+
+// Step performs every execution of the jobs of the `schedule.Scheduler` `s` that comes due up to `to` at the time it is due, in order,
+// and calls `executed` with the name of the job and the time of each of them. `s` must not be running.
+// It is set by the schedule package, which implements it with the unexported fields of its jobs
+var Step func(s interface{}, to time.Time, executed func(name string, t time.Time)) error
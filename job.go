@@ -1,16 +1,60 @@
 package schedule
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Job represents a task that is queued on the system at a certain time
-type Job interface {
+// JobInfo is the read-only part of a `Job`, so that APIs and dashboards can be handed the data of the jobs
+// without a way to change or execute them
+type JobInfo interface {
 	// Name is the name of the job. It is unique to the scheduler that it is added to
 	Name() string
 
+	// Description is a plain english sentence that describes when this job is executed
+	Description() string
+
+	// Describe is like `Description` in the language of `locale`, ie "de" or "pt-BR", whose `Catalog` was passed to `RegisterCatalog`.
+	// It falls back on the catalog of the language of the locale and then on English
+	Describe(locale string) string
+
+	// Tenant is the tenant that the job belongs to, set by `Task.ForTenant`
+	Tenant() string
+
+	// Definition returns the definition of the job as a `JobSpec`, without its func
+	Definition() JobSpec
+
+	// NextRun is the time the job is due next
+	NextRun() time.Time
+
+	// LastRun is the time the last execution of the job was scheduled at, or the zero time if it never ran
+	LastRun() time.Time
+
+	// Completed reports whether the job will never run on its schedule again, ie a `Once` job that already ran
+	// or a job that ran `Task.Times` times. Completed jobs stay in the scheduler unless `Config.RemoveCompleted` is set
+	Completed() bool
+
+	// Stats returns the counters of the job, which are shared by every instance when the store implements `Finisher`
+	Stats() JobStats
+
+	// Location is the timezone that the job is evaluated in. Times are always stored in UTC,
+	// so use `t.In(j.Location())` to display a time in the timezone of the job
+	Location() *time.Location
+}
+
+// Job represents a task that is queued on the system at a certain time
+type Job interface {
+	JobInfo
+
 	// Amount is the amount of some interval of time that will elapse between executions.
 	// If there is only 1 execution of this task, it will be set to zero
 	Amount() int
@@ -18,31 +62,64 @@ type Job interface {
 	// Interval is the interval of time that will elapse between executions
 	Interval() IntervalType
 
-	// Description is a plain english sentence that describes when this job is executed
-	Description() string
-
 	// Scheduler is the `Scheduler` that this job belongs to
 	Scheduler() Scheduler
 
-	// execute executes the job if it needs an execution
-	execute(time.Time) bool
+	// Clone starts building a job named `name` in the same scheduler with the same schedule and modifiers as this one,
+	// ie to stamp out a job per customer. Only the func and the `Task.TriggeredBy` source are not copied, so call `Do` to add it
+	Clone(name string) Task
+
+	// ExecutionKey is the key of the execution that is being performed, which is built from the name of the job and the time
+	// the execution was scheduled at. Every instance, retry and replay of an execution has the same key,
+	// so that the systems the job calls can deduplicate the executions that are performed more than once
+	ExecutionKey() string
+
+	// Payload unmarshals the payload that was attached with `Task.WithPayload` into `v`, like `json.Unmarshal`.
+	// It leaves `v` as is if the job does not have a payload
+	Payload(v interface{}) error
+
+	// Context is done once the scheduler is stopped with `Drainer.StopContext` and stops waiting for the job to finish,
+	// so that a long running func can return early
+	Context() context.Context
+
+	// IsReplay reports whether the execution is performed again by `Replayer.Replay` or `Replayer.Backfill` instead of on its schedule.
+	// A replay has the payload of the job and the scheduled time, see `LastRun`, and the key of the execution that it performs again
+	IsReplay() bool
 }
 
 // Amount determines the amount of some interval of time that will elapse between executions
 type Amount interface {
 	Every(i ...int) Interval
+
+	// EveryDuration runs the job every `d`, ie `EveryDuration(90 * time.Minute)`.
+	// It is stored as the largest of hours, minutes, seconds or milliseconds that `d` is a whole number of, so `d` must be a whole number of milliseconds of at least one millisecond
+	EveryDuration(d time.Duration) Starting
+
 	Once() Starting
+
+	// Spec declares the schedule with a cron style descriptor, starting now: `@yearly` (or `@annually`), `@monthly`, `@weekly`,
+	// `@daily` (or `@midnight`), `@hourly` or `@every <duration>`, ie `Spec("@every 15m")`
+	Spec(descriptor string) Task
 }
 
-// Interval determines the interval of time that will elapse between executions
+// Interval determines the interval of time that will elapse between executions.
+// Years, months, weeks, days and weekdays are calendar intervals that run at the same wall clock time in the location
+// of the `Starting` time, even when the clocks change for daylight saving time. If the time does not exist on a day because
+// the clocks sprang forward, the job runs at the equivalent time after the change and returns to the usual time the next run.
+// Hours, minutes and seconds are elapsed time intervals, as are days and weeks when `Task.Elapsed` is called
 type Interval interface {
 	Years() Month
 	Months() Day
 	Weeks() Day
 	Days() Time
+	Weekdays() Time
 	Hours() Starting
 	Minutes() Starting
 	Seconds() Starting
+
+	// Milliseconds runs the job at sub-second intervals, ie for high frequency polling.
+	// The `Config.Granularity` of the scheduler must not be coarser than the interval, which also makes the scheduler tick at it
+	Milliseconds() Starting
 }
 
 // Month adds the month to the job
@@ -52,24 +129,145 @@ type Month interface {
 
 // Day adds the day to the job
 type Day interface {
-	On(day int) Time
+	// On sets the day of the month, or the weekday when scheduling a weekly task.
+	// Weekly tasks can run on more than one weekday, ie `On(1, 3, 5)` runs on monday, wednesday and friday.
+	// Prefer `OnWeekday` for weekly tasks
+	On(day int, days ...int) Time
+
+	// OnWeekday sets the weekdays of a weekly task, ie `OnWeekday(time.Monday, time.Friday)`
+	OnWeekday(day time.Weekday, days ...time.Weekday) Time
+
+	// OnWeekdayOccurrence runs the job on the nth `weekday` of the month (ie the second tuesday).
+	// Negative values of n count back from the end of the month, so -1 is the last `weekday` of the month.
+	// Months that do not have an nth `weekday` are skipped
+	OnWeekdayOccurrence(weekday time.Weekday, n int) Time
 }
 
 // Time sets the time that the job will execute
 type Time interface {
 	At(hours, minutes, seconds int) Starting
+
+	// AtClock parses the time of day from a string like "15:04:05" or "15:04", ie from a config
+	AtClock(clock string) Starting
+
+	// AtTimeOf runs the job at the time of day `p` returns for each day the job runs on
+	AtTimeOf(p TimeOfDay) Starting
 }
 
-// Starting set the time we start counting
+// TimeOfDay maps a day to the time that a job runs on that day, ie the opening time of an exchange
+type TimeOfDay interface {
+	TimeOfDay(day time.Time) (hours, minutes, seconds int)
+}
+
+// TimeOfDayFunc is an adapter to allow the use of ordinary functions as a `TimeOfDay`
+type TimeOfDayFunc func(day time.Time) (hours, minutes, seconds int)
+
+// TimeOfDay calls f(day)
+func (f TimeOfDayFunc) TimeOfDay(day time.Time) (hours, minutes, seconds int) {
+	return f(day)
+}
+
+// Starting set the time we start counting. It is optional, so the `Task` methods can be called right away
+// and the job starts counting when `Do` is called
 type Starting interface {
 	Starting(time.Time) Task
+
+	// StartingNow starts counting now, which is the same as not calling `Starting`. A `Once` job runs on the next tick.
+	// A recurring job that was already stored keeps counting from when it was first added, so that restarts do not move its schedule
+	StartingNow() Task
+
+	Task
 }
 
 // Task adds the func that will be executed by the `Scheduler`. It is the final step in the `Job` builder methods.
 type Task interface {
+	// RequiresHealthy defers the execution of the job while `check` returns an error, emitting `JobDeferred`.
+	// Deferred executions are retried with an exponential backoff. The check runs in the background for up to 5 seconds
+	RequiresHealthy(check func(context.Context) error) Task
+
+	// Times stops a recurring job after it has run `n` times
+	Times(n int) Task
+
+	// Until stops a recurring job from running after `t`
+	Until(t time.Time) Task
+
+	// Elapsed makes a daily or weekly job run every 24 hours of elapsed time instead of at the same wall clock time,
+	// so that heartbeat like jobs do not stretch or shrink when the clocks change for daylight saving time
+	Elapsed() Task
+
+	// Aligned counts the intervals of an hourly, minutely, secondly or millisecond job from midnight of its `Starting` day instead of the `Starting` time,
+	// so that `Every(5).Minutes()` runs at :00, :05, :10 and so on, which makes the logs of several services easier to correlate
+	Aligned() Task
+
+	// OnMissingDay decides when a monthly or yearly job runs in the months that do not have its day, ie the 31st or February 29th.
+	// It defaults to `RollOverMissingDay`
+	OnMissingDay(policy MissingDayPolicy) Task
+
+	// Timezone evaluates the job in `loc` instead of the location of the `Starting` time
+	Timezone(loc *time.Location) Task
+
+	// Between skips every execution that is not between `startHour` and `endHour`, ie to only run during business hours.
+	// The window wraps around midnight when `startHour` is greater than `endHour`
+	Between(startHour, endHour int) Task
+
+	// Offset delays every execution by `d` within its interval, ie an hourly job that runs at 10 past the hour
+	// or a weekly job that runs a few hours into its day
+	Offset(d time.Duration) Task
+
+	// SkipHolidays skips every execution that falls on a holiday in `cal`
+	SkipHolidays(cal Calendar) Task
+
+	// ForTenant adds the job to `tenant`, so that it can be listed, paused and removed together with the other jobs of the tenant
+	ForTenant(tenant string) Task
+
+	// PinTo only executes the job on the instance whose `Config.InstanceID` or one of whose `Config.Tags` is `instance`,
+	// ie the box that mounts a volume the job needs. Every instance still lists the job
+	PinTo(instance string) Task
+
+	// ExpireAfter removes the job from the scheduler and the store once it has been inactive for `d`, ie temporary jobs.
+	// A job is inactive once it completed its `Times` or `Until`, or while it is paused
+	ExpireAfter(d time.Duration) Task
+
+	// AtLeastOnce only completes an execution once the job returns without panicking, instead of as soon as it is claimed.
+	// An execution that fails, or whose instance crashes, is performed again by any instance once `lease` has passed.
+	// The executions that come due while one is pending are skipped, but one that comes due after its lease expired
+	// is performed once the pending execution was performed again. Note: the store must implement `Finisher`
+	AtLeastOnce(lease time.Duration) Task
+
+	// WithPayload attaches `payload` to the job, which is stored as JSON with the job and handed to its func by `Job.Payload`,
+	// ie so that the jobs that only differ by a customer ID can share a func instead of a closure that cannot be discovered
+	WithPayload(payload interface{}) Task
+
+	// TriggeredBy also runs the job every time `src` fires, in addition to its schedule.
+	// Triggered executions are claimed, counted and reported like scheduled ones
+	TriggeredBy(src TriggerSource) Task
+
+	// Also runs the job on another schedule as well, which `rule` builds from the same builder methods,
+	// ie `Every(1).Days().At(9, 0, 0).Also(func(a Amount) Starting { return a.Every(1).Months().On(1).At(0, 0, 0) })`.
+	// The job is due at the earliest time that any of its schedules is, and its `Task` modifiers apply to all of them.
+	// The other schedules are not stored, like `Time.AtTimeOf`, so they are not part of its `JobInfo.Definition`
+	Also(rule func(a Amount) Starting) Task
+
+	// Do adds the job to the scheduler with the func that will be executed.
+	// It returns an error that lists every misuse of the builder methods, ie `Every(0)` or an invalid weekday
 	Do(func(Job, time.Time)) error
+
+	// MustDo is like `Do` but panics if the job can not be added
+	MustDo(func(Job, time.Time))
 }
 
+const (
+	// healthCheckTimeout is the amount of time a `Task.RequiresHealthy` check is allowed to take
+	healthCheckTimeout = 5 * time.Second
+
+	// maxHealthBackoff is the longest amount of time an execution is deferred by a failing `Task.RequiresHealthy` check
+	maxHealthBackoff = time.Minute
+
+	// calendarCycle is the number of months after which the gregorian calendar repeats its days and weekdays (400 years),
+	// so a monthly or yearly job that has not found its day in as many consecutive months never runs
+	calendarCycle = 400 * 12
+)
+
 // IntervalType is a string representation of the interval chosen by the `Interval` interface
 type IntervalType string
 
@@ -89,6 +287,9 @@ const (
 	// Days is set if `Interval.Days` is called
 	Days = IntervalType("days")
 
+	// Weekdays is set if `Interval.Weekdays` is called. The job runs monday through friday only
+	Weekdays = IntervalType("weekdays")
+
 	// Hours is set if `Interval.Hours` is called
 	Hours = IntervalType("hours")
 
@@ -97,11 +298,23 @@ const (
 
 	// Seconds is set if `Interval.Seconds` is called
 	Seconds = IntervalType("seconds")
+
+	// Milliseconds is set if `Interval.Milliseconds` is called
+	Milliseconds = IntervalType("milliseconds")
 )
 
 // Scan implements `sql.Scanner`
 func (it *IntervalType) Scan(value interface{}) error {
-	*it = IntervalType(value.([]byte))
+	switch v := value.(type) {
+	case []byte:
+		*it = IntervalType(v)
+	case string:
+		*it = IntervalType(v)
+	case nil:
+		*it = ""
+	default:
+		return fmt.Errorf("cannot scan %T into an IntervalType", value)
+	}
 	return nil
 }
 
@@ -110,26 +323,358 @@ func (it IntervalType) Value() (driver.Value, error) {
 	return string(it), nil
 }
 
+// MissingDayPolicy decides when a monthly or yearly job runs in the months that do not have its day
+type MissingDayPolicy int
+
+const (
+	// RollOverMissingDay runs the job on the days after the end of the month that the missing day overflows into, ie March 3rd instead
+	// of February 31st. Because the next month is counted from the day it ran on, the job keeps running on that day afterwards
+	RollOverMissingDay MissingDayPolicy = iota
+
+	// ClampMissingDay runs the job on the last day of the months that do not have its day
+	ClampMissingDay
+
+	// SkipMissingDay does not run the job in the months that do not have its day, like cron
+	SkipMissingDay
+)
+
+// JobStats are the counters of the executions of a `Job`
+type JobStats struct {
+	// RunCount is the number of executions that were claimed
+	RunCount int `json:"run_count"`
+
+	// FailureCount is the number of executions that panicked
+	FailureCount int `json:"failure_count"`
+
+	// LastError is the error of the last execution that panicked
+	LastError string `json:"last_error,omitempty"`
+
+	// AverageDuration is the average amount of time an execution took
+	AverageDuration time.Duration `json:"average_duration"`
+
+	// LastDuration is the amount of time the last execution took, ie to spot jobs that are trending slower
+	LastDuration time.Duration `json:"last_duration"`
+}
+
+// ExecutionKey returns the key of the execution of the job named `name` that is scheduled at `t`, see `Job.ExecutionKey`
+func ExecutionKey(name string, t time.Time) string {
+	return name + "@" + t.UTC().Format(time.RFC3339Nano)
+}
+
+// IsReplay reports whether `j` is being re-executed by `Replayer.Replay` or `Replayer.Backfill`, see `Job.IsReplay`
+func IsReplay(j Job) bool {
+	return j.IsReplay()
+}
+
+// replay wraps a `Job` that is re-executed by `Replayer.Replay` or `Replayer.Backfill` as if it was scheduled at `scheduled`
+type replay struct {
+	Job
+	scheduled time.Time
+}
+
+// ExecutionKey implements `Job`
+func (r replay) ExecutionKey() string {
+	return ExecutionKey(r.Name(), r.scheduled)
+}
+
+// LastRun implements `Job`
+func (r replay) LastRun() time.Time {
+	return r.scheduled
+}
+
+// IsReplay implements `Job`
+func (r replay) IsReplay() bool {
+	return true
+}
+
+// Record is the state of a job that is persisted by a `Store` and shared by every instance of a scheduler
+// `Paused` is set by `TenantManager.PauseTenant` and stops every instance from claiming executions.
+// `Disabled` is set by `Toggler.Disable`, or by hand in the database, and stops every instance from claiming executions until it is cleared.
+// `Version` is incremented every time the record is saved, which is what `OptimisticLocking` claims executions with.
+// `LastExecutionKey` is the `Job.ExecutionKey` of the execution at `LastRunAt`.
+// `PendingUntil` is set while the execution at `LastRunAt` of a `Task.AtLeastOnce` job has not completed,
+// and is when the other instances may perform it again
+type Record struct {
+	JobName          string `gorm:"primary_key"`
+	TenantName       string
+	PinnedTo         string
+	IntervalAmount   int
+	IntervalType     IntervalType
+	Month            int
+	Day              int
+	DayMask          int
+	Weekday          int
+	Occurrence       int
+	Hour             int
+	Minute           int
+	Second           int
+	WindowStart      int
+	WindowEnd        int
+	OffsetDuration   time.Duration
+	StartAt          time.Time `gorm:"precision:6"`
+	EndAt            time.Time `gorm:"precision:6"`
+	LastRunAt        time.Time `gorm:"precision:6"`
+	NextRunAt        time.Time `gorm:"precision:6"`
+	ElapsedTime      bool
+	AlignedTime      bool
+	MissingDay       MissingDayPolicy
+	MaxRuns          int
+	Expiry           time.Duration
+	RunCount         int
+	FailureCount     int
+	LastError        string
+	FinishCount      int
+	TotalDuration    time.Duration
+	JobDuration      time.Duration
+	Paused           bool
+	Zone             string
+	Checksum         string
+	Version          int
+	PendingUntil     time.Time `gorm:"precision:6"`
+	LastExecutionKey string
+	Payload          []byte
+	Disabled         bool
+	drift            DriftPolicy
+	granularity      time.Duration
+	skew             time.Duration
+	lease            time.Duration
+	redelivery       bool
+	implicitStart    bool
+	clock            func() time.Time
+}
+
+// now returns the time of the clock that the instances of the scheduler share, see `Config.DatabaseClock`
+func (r *Record) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock()
+}
+
+// Merge copies the state that is shared by every instance from the `stored` record into `r`
+// when a job is added to a scheduler, so that it survives restarts.
+// If the stored definition of the job was edited outside of the scheduler, the `DriftPolicy` of the scheduler decides which definition is kept
+func (r *Record) Merge(stored *Record) {
+	// a recurring job that starts counting when it is added keeps counting from when it was first added,
+	// so that restarting the same code does not change its definition or move its schedule
+	if r.implicitStart && r.IntervalType != Once && !stored.StartAt.IsZero() {
+		r.StartAt = stored.StartAt.In(r.StartAt.Location())
+		r.Checksum = r.checksum()
+	}
+	r.RunCount = stored.RunCount
+	r.Version = stored.Version + 1
+	r.Paused = stored.Paused
+	r.Disabled = stored.Disabled
+	r.shareStats(stored)
+
+	// an execution that has not completed is kept, so that it is performed again once its lease expires
+	if !stored.PendingUntil.IsZero() {
+		r.LastRunAt = stored.LastRunAt
+		r.PendingUntil = stored.PendingUntil
+	}
+	if stored.Checksum != "" && stored.Checksum != stored.checksum() && stored.Checksum != stored.legacyChecksum() {
+		log.Printf("schedule: the stored definition of %s was changed outside of the scheduler", r.JobName)
+		if r.drift == AdoptDrift {
+			r.adopt(stored)
+			return
+		}
+	}
+
+	// the stored record is saved over with the schedule of the new definition
+	if !r.sameDefinition(stored) {
+		log.Printf("schedule: the definition of %s changed, its schedule was recomputed", r.JobName)
+	}
+}
+
+// adopt replaces the definition of the job with the definition of the `stored` record
+func (r *Record) adopt(stored *Record) {
+	r.IntervalAmount = stored.IntervalAmount
+	r.IntervalType = stored.IntervalType
+	r.Month = stored.Month
+	r.Day = stored.Day
+	r.DayMask = stored.DayMask
+	r.Weekday = stored.Weekday
+	r.Occurrence = stored.Occurrence
+	r.Hour = stored.Hour
+	r.Minute = stored.Minute
+	r.Second = stored.Second
+	r.WindowStart = stored.WindowStart
+	r.WindowEnd = stored.WindowEnd
+	r.OffsetDuration = stored.OffsetDuration
+	r.StartAt = stored.StartAt
+	r.EndAt = stored.EndAt
+	r.ElapsedTime = stored.ElapsedTime
+	r.AlignedTime = stored.AlignedTime
+	r.MissingDay = stored.MissingDay
+	r.MaxRuns = stored.MaxRuns
+	r.Zone = stored.Zone
+	r.Checksum = r.checksum()
+}
+
+// sameDefinition reports whether the `stored` record was saved with the definition of the job, or has no checksum
+func (r *Record) sameDefinition(stored *Record) bool {
+	return stored.Checksum == "" || stored.Checksum == r.Checksum || stored.Checksum == r.legacyChecksum()
+}
+
+// Resume restores the runs of the `stored` record into `r` when a job is added to a scheduler,
+// so that a restarted scheduler does not run a `Once` job twice and catches up on a run it missed while it was stopped.
+// It should only be called if the stored definition of the job is the same as the definition of `r`
+func (r *Record) Resume(stored *Record) {
+	r.LastRunAt = stored.LastRunAt
+	if !stored.NextRunAt.IsZero() && stored.NextRunAt.Before(r.NextRunAt) && stored.NextRunAt.After(stored.LastRunAt) {
+		r.NextRunAt = stored.NextRunAt
+	}
+}
+
+// checksum is a hash of the definition of the job, which is used to detect changes to the definition and changes made outside of the scheduler
+func (r *Record) checksum() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %d %t %d %s", r.definition(), r.OffsetDuration, r.AlignedTime, r.MissingDay, r.Zone)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// legacyChecksum is the checksum that older versions of this package saved, which only hashed part of the definition
+func (r *Record) legacyChecksum() string {
+	sum := sha256.Sum256([]byte(r.definition()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// definition formats the part of the definition of the job that every version of the checksum hashes
+func (r *Record) definition() string {
+	return fmt.Sprintf("%d %s %d %d %d %d %d %d %d %d %d %d %d %d %t %d",
+		r.IntervalAmount, r.IntervalType, r.Month, r.Day, r.DayMask, r.Weekday, r.Occurrence, r.Hour, r.Minute, r.Second,
+		r.WindowStart, r.WindowEnd, r.StartAt.Unix(), r.EndAt.Unix(), r.ElapsedTime, r.MaxRuns)
+}
+
+// Claim checks if the execution that `r` is about to perform can be claimed over the `stored` record.
+// It returns `ErrAlreadyExecuted` if another instance already performed the execution.
+// On success `r` is updated with the state shared by every instance and should replace the `stored` record
+func (r *Record) Claim(stored *Record) error {
+	r.shareStats(stored)
+	if !r.sameDefinition(stored) {
+		// another instance added the job with a new definition, which replaces the one of this instance
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		r.adopt(stored)
+		return ErrDefinitionChanged
+	} else if stored.Paused {
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		return fmt.Errorf("%s is paused", r.JobName)
+	} else if stored.Disabled {
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		return fmt.Errorf("%s is disabled", r.JobName)
+	} else if !stored.PendingUntil.IsZero() && r.now().Before(stored.PendingUntil) {
+		// another instance is performing the execution at the stored `LastRunAt`, which is performed again if it does not complete
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		r.LastRunAt = stored.LastRunAt
+		r.PendingUntil = stored.PendingUntil
+		return ErrPending
+	} else if !stored.PendingUntil.IsZero() && !r.redelivery {
+		// the instance that claimed the execution at the stored `LastRunAt` did not complete it before its lease expired,
+		// so it is performed again before the execution that is claimed
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		r.PendingUntil = stored.PendingUntil
+		return ErrNotCompleted
+	} else if !stored.PendingUntil.IsZero() {
+		// the execution at the stored `LastRunAt` is performed again
+		log.Printf("schedule: %s performs the execution of %s again, it was not completed", r.JobName, stored.LastRunAt)
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version + 1
+		r.LastRunAt = stored.LastRunAt
+		r.LastExecutionKey = ExecutionKey(r.JobName, r.LastRunAt)
+		r.PendingUntil = r.pendingUntil()
+		r.Paused = false
+		r.Disabled = false
+		return nil
+	} else if r.redelivery {
+		// the execution was completed while it was waiting to be performed again
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		return ErrAlreadyExecuted
+	} else if r.executed(stored) {
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		return ErrAlreadyExecuted
+	} else if r.MaxRuns > 0 && stored.RunCount >= r.MaxRuns {
+		r.RunCount = stored.RunCount
+		r.Version = stored.Version
+		return fmt.Errorf("%s already ran %d times", r.JobName, stored.RunCount)
+	}
+	r.RunCount = stored.RunCount + 1
+	r.Version = stored.Version + 1
+	r.LastExecutionKey = ExecutionKey(r.JobName, r.LastRunAt)
+	r.PendingUntil = r.pendingUntil()
+	r.Paused = false
+	r.Disabled = false
+	return nil
+}
+
+// pendingUntil returns when the lease of an execution that is claimed now expires, or the zero time if the job is not `Task.AtLeastOnce`
+func (r *Record) pendingUntil() time.Time {
+	if r.lease <= 0 {
+		return time.Time{}
+	}
+	return r.now().Add(r.lease)
+}
+
+// executed reports whether the execution that `r` claims was already claimed by the `stored` record.
+// The stored times that are within the clock skew tolerance of the claimed times are treated as the same times
+func (r *Record) executed(stored *Record) bool {
+	return !stored.NextRunAt.Add(r.skew).Truncate(r.granularity).Before(r.NextRunAt.Truncate(r.granularity)) &&
+		!stored.LastRunAt.Add(r.skew).Truncate(r.granularity).Before(r.LastRunAt.Truncate(r.granularity))
+}
+
+// shareStats copies the counters of the executions of every instance from the `stored` record into `r`
+func (r *Record) shareStats(stored *Record) {
+	r.FailureCount = stored.FailureCount
+	r.LastError = stored.LastError
+	r.FinishCount = stored.FinishCount
+	r.TotalDuration = stored.TotalDuration
+	r.JobDuration = stored.JobDuration
+}
+
+// stats returns the counters of the record
+func (r *Record) stats() JobStats {
+	stats := JobStats{
+		RunCount:     r.RunCount,
+		FailureCount: r.FailureCount,
+		LastError:    r.LastError,
+		LastDuration: r.JobDuration,
+	}
+	if r.FinishCount > 0 {
+		stats.AverageDuration = r.TotalDuration / time.Duration(r.FinishCount)
+	}
+	return stats
+}
+
 // job implements `Job`, `Interval`, `Increment`, `Month`, `Day`, `Time`, `Starting`, and `Task` interfaces
 type job struct {
-	JobName        string `gorm:"primary_key"`
-	IntervalAmount int
-	IntervalType   IntervalType
-	Month          int
-	Day            int
-	Hour           int
-	Minute         int
-	Second         int
-	StartAt        time.Time
-	LastRunAt      time.Time
-	NextRunAt      time.Time
-	do             func(Job, time.Time)
-	scheduler      Scheduler
-}
-
-// TableName makes sure that we add this job to the right scheduler in the db
-func (j *job) TableName() string {
-	return j.scheduler.Name()
+	Record
+	do            func(Job, time.Time)
+	wraps         []func(func(Job, time.Time)) func(Job, time.Time)
+	healthCheck   func(context.Context) error
+	calendar      Calendar
+	source        TriggerSource
+	errs          []string
+	replace       bool
+	executing     sync.Mutex
+	removed       chan struct{}
+	timeOfDay     TimeOfDay
+	rules         []*job
+	healthBackoff time.Duration
+	health        chan error
+	deferredUntil time.Time
+	redeliverAt   time.Time
+	paused        int32
+	disabled      int32
+	queued        int64
+	stats         atomic.Value
+	loc           *time.Location
+	scheduler     Scheduler
+	registrar     registrar
 }
 
 // Name is the name of the job. It is unique to the scheduler that it is added to
@@ -148,26 +693,164 @@ func (j *job) Interval() IntervalType {
 	return j.IntervalType
 }
 
-// Description is a plain english sentence that describes when this job is executed
-func (j *job) Description() string {
-	// TODO: write something better than this
-	return fmt.Sprintf("%+v", j)
-}
-
+// Scheduler is the `Scheduler` that this job belongs to
 func (j *job) Scheduler() Scheduler {
 	return j.scheduler
 }
 
+// Location is the timezone that the job is evaluated in
+func (j *job) Location() *time.Location {
+	return j.location()
+}
+
+// Tenant is the tenant that the job belongs to, set by `Task.ForTenant`
+func (j *job) Tenant() string {
+	return j.TenantName
+}
+
+// Clone starts building a job named `name` with the same schedule and modifiers as this one
+func (j *job) Clone(name string) Task {
+	c := j.copySchedule(name)
+	c.TenantName = j.TenantName
+	c.PinnedTo = j.PinnedTo
+	c.Expiry = j.Expiry
+	c.Record.Payload = j.Record.Payload
+	c.lease = j.lease
+	c.wraps = append([]func(func(Job, time.Time)) func(Job, time.Time){}, j.wraps...)
+	c.healthCheck = j.healthCheck
+	c.scheduler = j.scheduler
+	c.registrar = j.registrar
+	c.caclulateNextRunAt(c.now())
+	return c
+}
+
+// copySchedule returns a job named `name` with the schedule of this one, including the schedules added by `Task.Also`
+// and the modifiers that decide when it runs, but without its state, so that its runs can be calculated without touching the job
+func (j *job) copySchedule(name string) *job {
+	return &job{
+		Record: Record{
+			JobName:        name,
+			IntervalAmount: j.IntervalAmount,
+			IntervalType:   j.IntervalType,
+			Month:          j.Month,
+			Day:            j.Day,
+			DayMask:        j.DayMask,
+			Weekday:        j.Weekday,
+			Occurrence:     j.Occurrence,
+			Hour:           j.Hour,
+			Minute:         j.Minute,
+			Second:         j.Second,
+			WindowStart:    j.WindowStart,
+			WindowEnd:      j.WindowEnd,
+			OffsetDuration: j.OffsetDuration,
+			StartAt:        j.StartAt,
+			EndAt:          j.EndAt,
+			ElapsedTime:    j.ElapsedTime,
+			AlignedTime:    j.AlignedTime,
+			MissingDay:     j.MissingDay,
+			MaxRuns:        j.MaxRuns,
+			Zone:           j.Zone,
+			granularity:    j.granularity,
+			skew:           j.skew,
+			clock:          j.clock,
+			implicitStart:  j.implicitStart,
+		},
+		calendar:  j.calendar,
+		timeOfDay: j.timeOfDay,
+		rules:     j.cloneRules(),
+		loc:       j.loc,
+	}
+}
+
+// ExecutionKey returns the key of the execution at the last time the job ran
+func (j *job) ExecutionKey() string {
+	return ExecutionKey(j.JobName, j.LastRunAt)
+}
+
+// Payload unmarshals the payload of the job into `v`
+func (j *job) Payload(v interface{}) error {
+	if len(j.Record.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Record.Payload, v)
+}
+
+// Context is cancelled when the scheduler stops waiting for the job to finish
+func (j *job) Context() context.Context {
+	if s := innermost(j.scheduler); s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.ctx != nil {
+			return s.ctx
+		}
+	}
+	return context.Background()
+}
+
+// IsReplay reports whether the execution is performed again by `Replayer.Replay` or `Replayer.Backfill`
+func (j *job) IsReplay() bool {
+	return false
+}
+
+// NextRun is the time the job is due next
+func (j *job) NextRun() time.Time {
+	return j.NextRunAt
+}
+
+// LastRun is the time the last execution of the job was scheduled at
+func (j *job) LastRun() time.Time {
+	return j.LastRunAt
+}
+
+// Completed reports whether the job will never run on its schedule again
+func (j *job) Completed() bool {
+	return j.completed()
+}
+
+// Stats returns the counters of the job
+func (j *job) Stats() JobStats {
+	if stats, ok := j.stats.Load().(JobStats); ok {
+		return stats
+	}
+	return JobStats{}
+}
+
 func (j *job) Every(i ...int) Interval {
-	if i == nil {
+	if len(i) == 0 {
 		j.IntervalAmount = 1
 		return j
-	} else if i[0] == 0 {
-		panic("call `Interval.Once` instead")
-	} else if i[0] < 0 {
-		panic("Every expects a number greater than 0")
 	}
-	j.IntervalAmount = i[0]
+	amount := i[0]
+	if amount == 0 {
+		j.invalid("call `Interval.Once` instead of `Every(0)`")
+		amount = 1
+	} else if amount < 0 {
+		j.invalid("Every expects a number greater than 0")
+		amount = 1
+	}
+	j.IntervalAmount = amount
+	return j
+}
+
+func (j *job) EveryDuration(d time.Duration) Starting {
+	if d < time.Millisecond || d%time.Millisecond != 0 {
+		j.invalid("EveryDuration expects a whole number of milliseconds greater than 0")
+		d = time.Second
+	}
+	switch {
+	case d%time.Hour == 0:
+		j.IntervalType = Hours
+		j.IntervalAmount = int(d / time.Hour)
+	case d%time.Minute == 0:
+		j.IntervalType = Minutes
+		j.IntervalAmount = int(d / time.Minute)
+	case d%time.Second == 0:
+		j.IntervalType = Seconds
+		j.IntervalAmount = int(d / time.Second)
+	default:
+		j.IntervalAmount = int(d / time.Millisecond)
+		j.Milliseconds()
+	}
 	return j
 }
 
@@ -177,6 +860,32 @@ func (j *job) Once() Starting {
 	return j
 }
 
+func (j *job) Spec(descriptor string) Task {
+	now := j.now()
+	switch descriptor {
+	case "@yearly", "@annually":
+		return j.Every(1).Years().In(time.January).On(1).At(0, 0, 0).Starting(now)
+	case "@monthly":
+		return j.Every(1).Months().On(1).At(0, 0, 0).Starting(now)
+	case "@weekly":
+		return j.Every(1).Weeks().OnWeekday(time.Sunday).At(0, 0, 0).Starting(now)
+	case "@daily", "@midnight":
+		return j.Every(1).Days().At(0, 0, 0).Starting(now)
+	case "@hourly":
+		return j.Every(1).Hours().Starting(time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()))
+	}
+	if every := strings.TrimPrefix(descriptor, "@every "); every != descriptor {
+		d, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil {
+			j.invalid(fmt.Sprintf("%q is not a valid descriptor: %s", descriptor, err))
+			return j
+		}
+		return j.EveryDuration(d).Starting(now)
+	}
+	j.invalid(fmt.Sprintf("%q is not a valid descriptor", descriptor))
+	return j
+}
+
 func (j *job) Years() Month {
 	j.IntervalType = Years
 	return j
@@ -197,6 +906,11 @@ func (j *job) Days() Time {
 	return j
 }
 
+func (j *job) Weekdays() Time {
+	j.IntervalType = Weekdays
+	return j
+}
+
 func (j *job) Hours() Starting {
 	j.IntervalType = Hours
 	return j
@@ -209,6 +923,19 @@ func (j *job) Minutes() Starting {
 
 func (j *job) Seconds() Starting {
 	j.IntervalType = Seconds
+	if time.Duration(j.IntervalAmount)*time.Second <= j.skew {
+		j.invalid(fmt.Sprintf("Seconds expects an interval that is longer than the clock skew of the scheduler (%s)", j.skew))
+	}
+	return j
+}
+
+func (j *job) Milliseconds() Starting {
+	j.IntervalType = Milliseconds
+	if time.Duration(j.IntervalAmount)*time.Millisecond < j.granularity {
+		j.invalid(fmt.Sprintf("Milliseconds expects an interval that is not shorter than the granularity of the scheduler (%s)", j.granularity))
+	} else if time.Duration(j.IntervalAmount)*time.Millisecond <= j.skew {
+		j.invalid(fmt.Sprintf("Milliseconds expects an interval that is longer than the clock skew of the scheduler (%s)", j.skew))
+	}
 	return j
 }
 
@@ -217,97 +944,774 @@ func (j *job) In(month time.Month) Day {
 	return j
 }
 
-func (j *job) On(day int) Time {
-	if j.IntervalType == Weeks && (day < 0 || day > 6) {
-		panic("day must be a valid time.Weekday when scheduling a weekly task")
+func (j *job) On(day int, days ...int) Time {
+	if len(days) > 0 && j.IntervalType != Weeks {
+		j.invalid("multiple days can only be used when scheduling a weekly task")
+		days = nil
+	}
+	for _, d := range append([]int{day}, days...) {
+		if j.IntervalType == Weeks && (d < 0 || d > 6) {
+			j.invalid("day must be a valid time.Weekday when scheduling a weekly task")
+			return j
+		} else if j.IntervalType != Weeks && (d < 1 || d > 31) {
+			j.invalid("day must be between 1 and 31")
+			return j
+		}
+		if len(days) > 0 {
+			j.DayMask |= 1 << uint(d)
+		}
 	}
 	j.Day = day
 	return j
 }
 
+func (j *job) OnWeekday(day time.Weekday, days ...time.Weekday) Time {
+	if j.IntervalType != Weeks {
+		j.invalid("OnWeekday can only be used when scheduling a weekly task, call `Day.On` with the day of the month")
+		return j
+	}
+	others := make([]int, len(days))
+	for i, d := range days {
+		others[i] = int(d)
+	}
+	return j.On(int(day), others...)
+}
+
+func (j *job) OnWeekdayOccurrence(weekday time.Weekday, n int) Time {
+	if j.IntervalType == Weeks {
+		j.invalid("call `Day.OnWeekday` when scheduling a weekly task")
+		return j
+	} else if weekday < time.Sunday || weekday > time.Saturday {
+		j.invalid("weekday must be a valid time.Weekday")
+		return j
+	} else if n == 0 || n < -5 || n > 5 {
+		j.invalid("OnWeekdayOccurrence expects n to be between 1 and 5 or -1 and -5")
+		return j
+	}
+	j.Weekday = int(weekday)
+	j.Occurrence = n
+	return j
+}
+
 func (j *job) At(hours int, minutes int, seconds int) Starting {
+	if hours < 0 || hours > 23 || minutes < 0 || minutes > 59 || seconds < 0 || seconds > 59 {
+		j.invalid(fmt.Sprintf("%02d:%02d:%02d is not a valid time of day", hours, minutes, seconds))
+		return j
+	}
 	j.Hour = hours
 	j.Minute = minutes
 	j.Second = seconds
 	return j
 }
 
+func (j *job) AtClock(clock string) Starting {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return j.At(t.Hour(), t.Minute(), t.Second())
+		}
+	}
+	j.invalid(fmt.Sprintf("%q is not a time of day like 15:04:05 or 15:04", clock))
+	return j
+}
+
+func (j *job) AtTimeOf(p TimeOfDay) Starting {
+	j.Hour = 0
+	j.Minute = 0
+	j.Second = 0
+	j.timeOfDay = p
+	return j
+}
+
 func (j *job) Starting(t time.Time) Task {
-	j.StartAt = t
+	j.StartAt = t.Truncate(j.granularity)
+	j.implicitStart = false
+	j.Zone = j.location().String()
 	j.caclulateNextRunAt(t)
 	return j
 }
 
+func (j *job) StartingNow() Task {
+	t := j.now()
+	if j.IntervalType == Once {
+		// a once job that is late by more than its window is skipped, so it starts at the next multiple of the granularity
+		t = t.Add(j.granularity)
+	}
+	j.Starting(t)
+	j.implicitStart = true
+	return j
+}
+
+func (j *job) RequiresHealthy(check func(context.Context) error) Task {
+	j.healthCheck = check
+	return j
+}
+
+func (j *job) Times(n int) Task {
+	if n < 1 {
+		j.invalid("Times expects a number greater than 0")
+		return j
+	}
+	j.MaxRuns = n
+	return j
+}
+
+func (j *job) Until(t time.Time) Task {
+	j.EndAt = t
+	return j
+}
+
+func (j *job) Elapsed() Task {
+	if j.IntervalType != Days && j.IntervalType != Weekdays && j.IntervalType != Weeks {
+		j.invalid("Elapsed can only be used when scheduling a daily or weekly task")
+		return j
+	}
+	j.ElapsedTime = true
+	return j
+}
+
+func (j *job) Aligned() Task {
+	if j.IntervalType != Hours && j.IntervalType != Minutes && j.IntervalType != Seconds && j.IntervalType != Milliseconds {
+		j.invalid("Aligned can only be used when scheduling a task every some hours, minutes, seconds or milliseconds")
+		return j
+	}
+	j.AlignedTime = true
+	j.caclulateNextRunAt(j.StartAt)
+	return j
+}
+
+func (j *job) OnMissingDay(policy MissingDayPolicy) Task {
+	if j.IntervalType != Months && j.IntervalType != Years {
+		j.invalid("OnMissingDay can only be used when scheduling a monthly or yearly task")
+		return j
+	}
+	j.MissingDay = policy
+	j.caclulateNextRunAt(j.StartAt)
+	return j
+}
+
+func (j *job) Timezone(loc *time.Location) Task {
+	j.loc = loc
+	j.Zone = loc.String()
+	j.caclulateNextRunAt(j.StartAt)
+	return j
+}
+
+func (j *job) Between(startHour, endHour int) Task {
+	if startHour < 0 || startHour > 23 || endHour < 0 || endHour > 24 || startHour == endHour {
+		j.invalid("Between expects two different hours between 0 and 24")
+		return j
+	}
+	j.WindowStart = startHour
+	j.WindowEnd = endHour
+	return j
+}
+
+func (j *job) Offset(d time.Duration) Task {
+	if d < 0 {
+		j.invalid("Offset expects a duration that is not negative")
+		return j
+	}
+	j.OffsetDuration = d
+	j.caclulateNextRunAt(j.StartAt)
+	return j
+}
+
+func (j *job) SkipHolidays(cal Calendar) Task {
+	j.calendar = cal
+	return j
+}
+
+func (j *job) ForTenant(tenant string) Task {
+	j.TenantName = tenant
+	return j
+}
+
+func (j *job) PinTo(instance string) Task {
+	j.PinnedTo = instance
+	return j
+}
+
+func (j *job) ExpireAfter(d time.Duration) Task {
+	if d <= 0 {
+		j.invalid("ExpireAfter expects a duration greater than 0")
+		return j
+	}
+	j.Expiry = d
+	return j
+}
+
+func (j *job) AtLeastOnce(lease time.Duration) Task {
+	if lease <= 0 {
+		j.invalid("AtLeastOnce expects a lease greater than 0")
+		return j
+	}
+	j.lease = lease
+	return j
+}
+
+func (j *job) WithPayload(payload interface{}) Task {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		j.invalid(fmt.Sprintf("WithPayload expects a payload that can be marshaled to JSON: %s", err))
+		return j
+	}
+	j.Record.Payload = data
+	return j
+}
+
+func (j *job) TriggeredBy(src TriggerSource) Task {
+	j.source = src
+	return j
+}
+
+func (j *job) Also(rule func(a Amount) Starting) Task {
+	if j.IntervalType == Once {
+		j.invalid("Also can not be used with a once job")
+		return j
+	}
+	r := &job{Record: Record{JobName: j.JobName, granularity: j.granularity, skew: j.skew, clock: j.clock}, scheduler: j.scheduler}
+	if rule(r) == nil || r.IntervalType == "" || r.IntervalType == Once {
+		r.invalid("the rule must return a recurring schedule")
+	}
+	for _, problem := range r.errs {
+		j.invalid("Also: " + problem)
+	}
+	j.rules = append(j.rules, r)
+	j.caclulateNextRunAt(j.StartAt)
+	return j
+}
+
+// cloneRules copies the schedules added by `Task.Also`, which are updated every time the next run of the job is calculated
+func (j *job) cloneRules() []*job {
+	var rules []*job
+	for _, r := range j.rules {
+		rules = append(rules, &job{Record: r.Record, timeOfDay: r.timeOfDay})
+	}
+	return rules
+}
+
 func (j *job) Do(do func(Job, time.Time)) error {
+	if len(j.errs) > 0 {
+		return fmt.Errorf("%s is invalid: %s", j.JobName, strings.Join(j.errs, "; "))
+	} else if do == nil {
+		return fmt.Errorf("%s does not have a func", j.JobName)
+	} else if j.StartAt.IsZero() {
+		j.StartingNow()
+	}
+	for _, wrap := range j.wraps {
+		do = wrap(do)
+	}
 	j.do = do
-	return j.scheduler.add(j)
+	return j.registrar.add(j)
+}
+
+func (j *job) MustDo(do func(Job, time.Time)) {
+	if err := j.Do(do); err != nil {
+		panic(err)
+	}
+}
+
+// invalid records a misuse of the builder methods, which is returned by `Task.Do`
+func (j *job) invalid(problem string) {
+	j.errs = append(j.errs, problem)
 }
 
 // execute handles all job and scheduling based logic
 func (j *job) execute(now time.Time) bool {
-	if j.NextRunAt.After(now) {
+	if j.redelivers(now) {
+		j.registrar.tracef(j, "performs its execution at %s again, it was not completed", j.LastRunAt)
+		return j.redeliver(now)
+	} else if !j.due(now) {
+		return false
+	} else if j.IntervalType == Once && j.deferredUntil.IsZero() && (now.Sub(j.NextRunAt) > j.onceWindow() || now.Sub(j.NextRunAt) < 0) {
+		j.registrar.tracef(j, "did not run at %s, it is more than %s late", j.NextRunAt, j.onceWindow())
+		return false
+	} else if reason := j.excluded(); len(reason) > 0 {
+		j.registrar.tracef(j, "did not run at %s, %s", j.NextRunAt, reason)
+		j.registrar.emit(Event{Type: JobSkipped, Job: j, Time: now, ScheduledAt: j.NextRunAt})
+		j.caclulateNextRunAt(now)
 		return false
-	} else if j.IntervalType == Once && (now.Sub(j.NextRunAt) > time.Second || now.Sub(j.NextRunAt) < 0) {
+	} else if !j.healthy(now) {
+		j.registrar.tracef(j, "did not run at %s, its health check failed or has not returned yet", j.NextRunAt)
+		return false
+	} else if !j.registrar.owns(j) {
+		j.registrar.tracef(j, "did not run at %s, another instance owns it", j.NextRunAt)
+		j.caclulateNextRunAt(now)
+		return false
+	} else if reason := j.registrar.denied(j, j.NextRunAt); len(reason) > 0 {
+		j.registrar.tracef(j, "did not run at %s, the gatekeeper denied it: %s", j.NextRunAt, reason)
+		j.caclulateNextRunAt(now)
 		return false
 	}
 	j.LastRunAt = j.NextRunAt
+	j.RunCount++
 	j.caclulateNextRunAt(now)
-	if err := j.scheduler.update(j); err != nil {
+	return j.run(now)
+}
+
+// excluded returns why the execution at `j.NextRunAt` is skipped, or an empty string if it is not
+func (j *job) excluded() string {
+	switch {
+	case atomic.LoadInt32(&j.paused) == 1:
+		return "it is paused"
+	case atomic.LoadInt32(&j.disabled) == 1:
+		return "it is disabled"
+	case !j.inWindow(j.NextRunAt):
+		return "it is outside of the hours set by Between"
+	case j.calendar != nil && j.calendar.IsHoliday(j.NextRunAt):
+		return "it is a holiday"
+	}
+	return ""
+}
+
+// suspended reports whether the job is paused or disabled on this instance
+func (j *job) suspended() bool {
+	return atomic.LoadInt32(&j.paused) == 1 || atomic.LoadInt32(&j.disabled) == 1
+}
+
+// redelivers reports whether the pending execution at `j.LastRunAt` should be performed again at `now`, because it was not completed
+func (j *job) redelivers(now time.Time) bool {
+	return !j.redeliverAt.IsZero() && !j.redeliverAt.After(now)
+}
+
+// redeliver performs the pending execution at `j.LastRunAt` again, unless another instance completed or performed it in the meantime
+func (j *job) redeliver(now time.Time) bool {
+	j.redeliverAt = time.Time{}
+	j.redelivery = true
+	defer func() {
+		j.redelivery = false
+	}()
+	return j.run(now)
+}
+
+// due reports whether the job may need an execution at `now`
+func (j *job) due(now time.Time) bool {
+	return !j.NextRunAt.After(now) && !j.deferredUntil.After(now) && !j.completed()
+}
+
+// overdue returns how late the execution that is due at `now` is, or zero if the job is not due or will not run it,
+// ie a `Once` job that missed its window. The `executing` lock of the job must be held
+func (j *job) overdue(now time.Time) time.Duration {
+	if !j.due(now) || (j.IntervalType == Once && j.deferredUntil.IsZero() && now.Sub(j.NextRunAt) > j.onceWindow()) {
+		return 0
+	}
+	return now.Sub(j.NextRunAt)
+}
+
+// trigger executes the job because its `TriggerSource` fired at `t`
+func (j *job) trigger(t time.Time) bool {
+	if (j.MaxRuns > 0 && j.RunCount >= j.MaxRuns) || (!j.EndAt.IsZero() && t.After(j.EndAt)) {
+		return false
+	} else if j.suspended() {
+		j.registrar.tracef(j, "did not run when it was triggered at %s, it is paused or disabled", t)
+		j.registrar.emit(Event{Type: JobSkipped, Job: j, Time: time.Now(), ScheduledAt: t})
+		return false
+	} else if !j.registrar.owns(j) {
+		return false
+	} else if reason := j.registrar.denied(j, t); len(reason) > 0 {
+		j.registrar.tracef(j, "did not run when it was triggered at %s, the gatekeeper denied it: %s", t, reason)
+		return false
+	}
+	j.LastRunAt = t
+	j.RunCount++
+	return j.run(t)
+}
+
+// run claims and performs the execution of the job that is due at `j.LastRunAt`
+func (j *job) run(now time.Time) bool {
+	err := j.registrar.update(j)
+	if err == ErrNotCompleted {
+		// perform the execution that was not completed first, then claim the execution that is due again
+		due := j.LastRunAt
+		j.redeliver(now)
+		j.LastRunAt = due
+		err = j.registrar.update(j)
+	}
+	j.stats.Store(j.Record.stats())
+	if err != nil {
+		return false
+	}
+
+	// a task fails when it panics. Its latency is measured with the clock that the instances share, like the time it was due
+	latency := j.now().Sub(j.LastRunAt)
+	start := time.Now()
+	j.registrar.emit(Event{Type: JobStarted, Job: j, Time: start, ScheduledAt: j.LastRunAt})
+	err = try(j.do, j, now)
+	duration := time.Since(start)
+	j.FinishCount++
+	j.TotalDuration += duration
+	j.JobDuration = duration
+	if err != nil {
+		log.Printf("schedule: %s failed: %s", j.JobName, err)
+		j.FailureCount++
+		j.LastError = err.Error()
+	}
+	j.stats.Store(j.Record.stats())
+	j.registrar.finish(j, latency, duration, err)
+
+	// the failed execution stays pending, so it is performed again once its lease expires
+	if err != nil && !j.PendingUntil.IsZero() {
+		j.redeliverAt = j.PendingUntil
+	} else {
+		j.PendingUntil = time.Time{}
+	}
+	return true
+}
+
+// inWindow reports whether `t` is within the hours set by `Task.Between`
+func (j *job) inWindow(t time.Time) bool {
+	if j.WindowStart == j.WindowEnd {
+		return true
+	} else if j.WindowStart < j.WindowEnd {
+		return t.Hour() >= j.WindowStart && t.Hour() < j.WindowEnd
+	}
+	return t.Hour() >= j.WindowStart || t.Hour() < j.WindowEnd
+}
+
+// onceWindow is how late a job scheduled to run `Amount.Once` may start
+func (j *job) onceWindow() time.Duration {
+	if j.granularity > time.Second {
+		return j.granularity
+	}
+	return time.Second
+}
+
+// completed reports whether the job will never run again because it ran `Task.Times` times
+// or its next run is after its `Task.Until` time, or because it is a `Once` job that already ran
+func (j *job) completed() bool {
+	return (j.MaxRuns > 0 && j.RunCount >= j.MaxRuns) || (!j.EndAt.IsZero() && j.NextRunAt.After(j.EndAt)) ||
+		(j.IntervalType == Once && !j.LastRunAt.IsZero())
+}
+
+// expired reports whether the job has been inactive for longer than its `Task.ExpireAfter` duration at `now`.
+// It is inactive since its last run, or since its `Task.Until` time if it is later
+func (j *job) expired(now time.Time) bool {
+	if j.Expiry == 0 || (!j.completed() && atomic.LoadInt32(&j.paused) == 0) {
+		return false
+	}
+	since := j.StartAt
+	if j.LastRunAt.After(since) {
+		since = j.LastRunAt
+	}
+	if j.completed() && j.EndAt.After(since) && j.EndAt.Before(now) {
+		since = j.EndAt
+	}
+	return now.Sub(since) >= j.Expiry
+}
+
+// occurrences returns every time the job would have run between `from` and `to` according to its definition,
+// which includes the schedules added by `Task.Also` and leaves out the runs that are outside of its `Task.Between` hours or on a holiday.
+// It does not depend on the runs the job already had, so it can be called while the job is executing
+func (j *job) occurrences(from, to time.Time) []time.Time {
+	c := j.copySchedule(j.JobName)
+
+	// only the first `Task.Times` runs since the start are backfilled, so they are counted from the start
+	t := from
+	if c.MaxRuns > 0 {
+		t = c.StartAt
+	}
+	var ts []time.Time
+	var last time.Time
+	for ; ; t = c.NextRunAt.Add(time.Nanosecond) {
+		c.caclulateNextRunAt(t)
+		if c.NextRunAt.After(to) || c.completed() || (!last.IsZero() && !c.NextRunAt.After(last)) {
+			return ts
+		}
+		last = c.NextRunAt
+		if len(c.excluded()) > 0 {
+			continue
+		}
+		c.RunCount++
+		if !c.NextRunAt.Before(from) {
+			ts = append(ts, c.NextRunAt)
+		}
+	}
+}
+
+// healthy runs the `Task.RequiresHealthy` check and defers the execution with an exponential backoff while it fails
+func (j *job) healthy(now time.Time) bool {
+	if j.healthCheck == nil {
+		return true
+	}
+
+	// the check runs in the background so that it does not hold up a worker, and the execution waits for its result
+	if j.health == nil {
+		j.health = make(chan error, 1)
+		go func(check func(context.Context) error, health chan<- error) {
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			defer cancel()
+			health <- check(ctx)
+		}(j.healthCheck, j.health)
+	}
+	var err error
+	select {
+	case err = <-j.health:
+		j.health = nil
+	default:
+		// wait for the result until the next tick
+		j.deferredUntil = now.Add(j.granularity / 2)
+		return false
+	}
+	if err != nil {
+		if j.healthBackoff *= 2; j.healthBackoff == 0 {
+			j.healthBackoff = time.Second
+		} else if j.healthBackoff > maxHealthBackoff {
+			j.healthBackoff = maxHealthBackoff
+		}
+		j.deferredUntil = now.Add(j.healthBackoff).Truncate(j.granularity)
+		log.Printf("schedule: %s was deferred until %s, its health check failed: %s", j.JobName, j.deferredUntil, err)
+		j.registrar.emit(Event{Type: JobDeferred, Job: j, Time: now, ScheduledAt: j.NextRunAt, Err: err})
 		return false
 	}
-	j.do(j, now)
+	j.healthBackoff = 0
+	j.deferredUntil = time.Time{}
 	return true
 }
 
 // caclulateNextRunAt determines `job.NextRunAt`
 func (j *job) caclulateNextRunAt(now time.Time) {
+	if len(j.errs) > 0 {
+		return
+	}
+
+	// schedule the job as if it did not have an offset, then delay the run by it
+	j.calculateRun(now.Add(-j.OffsetDuration))
+	j.NextRunAt = j.NextRunAt.Add(j.OffsetDuration)
+
+	// the schedules added by `Task.Also` share the start and the modifiers of the job, which runs at the earliest of them
+	for _, r := range j.rules {
+		r.StartAt, r.Zone, r.loc = j.StartAt, j.Zone, j.loc
+		r.OffsetDuration, r.ElapsedTime, r.AlignedTime, r.MissingDay = j.OffsetDuration, j.ElapsedTime, j.AlignedTime, j.MissingDay
+		r.caclulateNextRunAt(now)
+		if r.NextRunAt.Before(j.NextRunAt) {
+			j.NextRunAt = r.NextRunAt
+		}
+	}
+}
+
+// calculateRun determines `job.NextRunAt` without the `Task.Offset` of the job
+func (j *job) calculateRun(now time.Time) {
+	if j.timeOfDay == nil {
+		j.calculateInterval(now)
+		return
+	}
+
+	// find the first day that the time of day provided for it is not before now
+	now = now.In(j.location())
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for {
+		j.calculateInterval(day)
+		hours, minutes, seconds := j.timeOfDay.TimeOfDay(j.NextRunAt)
+		t := time.Date(j.NextRunAt.Year(), j.NextRunAt.Month(), j.NextRunAt.Day(), hours, minutes, seconds, 0, j.NextRunAt.Location())
+		if !t.Before(now) {
+			j.NextRunAt = t
+			return
+		}
+		day = j.NextRunAt.AddDate(0, 0, 1)
+	}
+}
+
+// calculateInterval determines `job.NextRunAt` from the interval of the job
+func (j *job) calculateInterval(now time.Time) {
+	start := j.origin()
 	switch j.IntervalType {
 	case Years:
-		j.NextRunAt = time.Date(j.StartAt.Year(), time.Month(j.Month), j.Day, j.Hour, j.Minute, j.Second, j.StartAt.Nanosecond(), j.StartAt.Location())
-		j.NextRunAt = j.NextRunAt.AddDate(j.IntervalAmount-1, 0, 0)
+		if j.Occurrence != 0 {
+			month := time.Date(start.Year(), time.Month(j.Month), 1, j.Hour, j.Minute, j.Second, start.Nanosecond(), start.Location())
+			j.NextRunAt = j.nextWeekdayOccurrence(month.AddDate(j.IntervalAmount-1, 0, 0), now, j.IntervalAmount, 0)
+			return
+		} else if j.MissingDay != RollOverMissingDay {
+			month := time.Date(start.Year(), time.Month(j.Month), 1, j.Hour, j.Minute, j.Second, start.Nanosecond(), start.Location())
+			j.NextRunAt = j.nextMonthDay(month.AddDate(j.IntervalAmount-1, 0, 0), now, j.IntervalAmount, 0)
+			return
+		}
+		j.NextRunAt = time.Date(start.Year(), time.Month(j.Month), j.Day, j.Hour, j.Minute, j.Second, start.Nanosecond(), start.Location())
+		j.NextRunAt = j.wallClock(j.NextRunAt.AddDate(j.IntervalAmount-1, 0, 0))
 		for j.NextRunAt.Before(now) {
-			j.NextRunAt = j.NextRunAt.AddDate(j.IntervalAmount, 0, 0)
+			j.NextRunAt = j.wallClock(j.NextRunAt.AddDate(j.IntervalAmount, 0, 0))
 		}
 	case Months:
-		j.NextRunAt = time.Date(j.StartAt.Year(), j.StartAt.Month(), j.Day, j.Hour, j.Minute, j.Second, j.StartAt.Nanosecond(), j.StartAt.Location())
-		j.NextRunAt = j.NextRunAt.AddDate(0, j.IntervalAmount-1, 0)
+		if j.Occurrence != 0 {
+			month := time.Date(start.Year(), start.Month(), 1, j.Hour, j.Minute, j.Second, start.Nanosecond(), start.Location())
+			j.NextRunAt = j.nextWeekdayOccurrence(month.AddDate(0, j.IntervalAmount-1, 0), now, 0, j.IntervalAmount)
+			return
+		} else if j.MissingDay != RollOverMissingDay {
+			month := time.Date(start.Year(), start.Month(), 1, j.Hour, j.Minute, j.Second, start.Nanosecond(), start.Location())
+			j.NextRunAt = j.nextMonthDay(month.AddDate(0, j.IntervalAmount-1, 0), now, 0, j.IntervalAmount)
+			return
+		}
+		j.NextRunAt = time.Date(start.Year(), start.Month(), j.Day, j.Hour, j.Minute, j.Second, start.Nanosecond(), start.Location())
+		j.NextRunAt = j.wallClock(j.NextRunAt.AddDate(0, j.IntervalAmount-1, 0))
 		for j.NextRunAt.Before(now) {
-			j.NextRunAt = j.NextRunAt.AddDate(0, j.IntervalAmount, 0)
+			j.NextRunAt = j.wallClock(j.NextRunAt.AddDate(0, j.IntervalAmount, 0))
 		}
 	case Weeks:
-		j.NextRunAt = time.Date(j.StartAt.Year(), j.StartAt.Month(), j.StartAt.Day(), j.Hour, j.Minute, j.Second, j.StartAt.Nanosecond(), j.StartAt.Location())
-		j.NextRunAt = j.NextRunAt.AddDate(0, 0, j.Day-int(j.StartAt.Weekday()))
-		for j.NextRunAt.Before(now) {
-			j.NextRunAt = j.NextRunAt.AddDate(0, 0, j.IntervalAmount*7)
+		// run on the earliest of the weekdays
+		for i, day := range j.weekdays() {
+			t := j.wallClock(start)
+			t = j.addDays(t, day-int(start.Weekday()))
+			for t.Before(now) {
+				t = j.addDays(t, j.IntervalAmount*7)
+			}
+			if i == 0 || t.Before(j.NextRunAt) {
+				j.NextRunAt = t
+			}
 		}
 	case Days:
-		j.NextRunAt = time.Date(j.StartAt.Year(), j.StartAt.Month(), j.StartAt.Day(), j.Hour, j.Minute, j.Second, j.StartAt.Nanosecond(), j.StartAt.Location())
+		j.NextRunAt = j.wallClock(start)
 		for j.NextRunAt.Before(now) {
-			j.NextRunAt = j.NextRunAt.AddDate(0, 0, 1)
+			j.NextRunAt = j.addDays(j.NextRunAt, 1)
+		}
+	case Weekdays:
+		j.NextRunAt = j.wallClock(start)
+		for j.NextRunAt.Before(now) || j.NextRunAt.Weekday() == time.Saturday || j.NextRunAt.Weekday() == time.Sunday {
+			j.NextRunAt = j.addDays(j.NextRunAt, 1)
 		}
 	case Hours:
-		j.NextRunAt = time.Date(j.StartAt.Year(), j.StartAt.Month(), j.StartAt.Day(), j.StartAt.Hour(), j.StartAt.Minute(), j.StartAt.Second(), j.StartAt.Nanosecond(), j.StartAt.Location())
+		j.NextRunAt = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
 		j.NextRunAt = j.NextRunAt.Add(time.Hour * time.Duration(j.IntervalAmount))
 		for j.NextRunAt.Before(now) {
 			j.NextRunAt = j.NextRunAt.Add(time.Hour * time.Duration(j.IntervalAmount))
 		}
 	case Minutes:
-		j.NextRunAt = time.Date(j.StartAt.Year(), j.StartAt.Month(), j.StartAt.Day(), j.StartAt.Hour(), j.StartAt.Minute(), j.StartAt.Second(), j.StartAt.Nanosecond(), j.StartAt.Location())
+		j.NextRunAt = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
 		j.NextRunAt = j.NextRunAt.Add(time.Minute * time.Duration(j.IntervalAmount))
 		for j.NextRunAt.Before(now) {
 			j.NextRunAt = j.NextRunAt.Add(time.Minute * time.Duration(j.IntervalAmount))
 		}
 	case Seconds:
-		j.NextRunAt = time.Date(j.StartAt.Year(), j.StartAt.Month(), j.StartAt.Day(), j.StartAt.Hour(), j.StartAt.Minute(), j.StartAt.Second(), j.StartAt.Nanosecond(), j.StartAt.Location())
+		j.NextRunAt = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
 		j.NextRunAt = j.NextRunAt.Add(time.Second * time.Duration(j.IntervalAmount))
 		for j.NextRunAt.Before(now) {
 			j.NextRunAt = j.NextRunAt.Add(time.Second * time.Duration(j.IntervalAmount))
 		}
+	case Milliseconds:
+		j.NextRunAt = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		j.NextRunAt = j.NextRunAt.Add(time.Millisecond * time.Duration(j.IntervalAmount))
+		for j.NextRunAt.Before(now) {
+			j.NextRunAt = j.NextRunAt.Add(time.Millisecond * time.Duration(j.IntervalAmount))
+		}
 	case Once:
 		j.NextRunAt = j.StartAt
 	default:
-		panic(fmt.Errorf("increment type %s not implemented", j.IntervalType))
+		j.invalid(fmt.Sprintf("increment type %s not implemented", j.IntervalType))
+	}
+}
+
+// origin is the time that the intervals of the job are counted from in its location,
+// which is midnight of the `Starting` day if the job is `Task.Aligned`
+func (j *job) origin() time.Time {
+	start := j.StartAt.In(j.location())
+	if j.AlignedTime {
+		return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	}
+	return start
+}
+
+// location returns the timezone set by `Task.Timezone` or the location of the `Starting` time
+func (j *job) location() *time.Location {
+	if j.loc != nil {
+		return j.loc
+	}
+	return j.StartAt.Location()
+}
+
+// addDays adds `n` days to `t`, either as wall clock days or as 24 hours of elapsed time if `Task.Elapsed` was called
+func (j *job) addDays(t time.Time, n int) time.Time {
+	if j.ElapsedTime {
+		return t.Add(time.Duration(n) * 24 * time.Hour)
+	}
+	return j.wallClock(t.AddDate(0, 0, n))
+}
+
+// wallClock returns the time of day set by `Time.At` on the day of `t`.
+// Stepping a calendar interval from a time that was normalized because it fell in a daylight saving time gap
+// would otherwise carry the shifted time of day into every following run
+func (j *job) wallClock(t time.Time) time.Time {
+	w := time.Date(t.Year(), t.Month(), t.Day(), j.Hour, j.Minute, j.Second, j.StartAt.Nanosecond(), t.Location())
+
+	// `time.Date` may normalize a time in a gap to before the clocks sprang forward, so move it past the gap instead
+	gap := time.Duration((j.Hour-w.Hour())*3600+(j.Minute-w.Minute())*60+(j.Second-w.Second())) * time.Second
+	if gap > 0 && w.Day() == t.Day() {
+		w = w.Add(gap)
+	}
+	return w
+}
+
+// weekdays returns the weekdays that a weekly job runs on
+func (j *job) weekdays() []int {
+	if j.DayMask == 0 {
+		return []int{j.Day}
+	}
+	var days []int
+	for d := 0; d < 7; d++ {
+		if j.DayMask&(1<<uint(d)) != 0 {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// nextWeekdayOccurrence steps `month` by `years` and `months` until it finds an occurrence that is not before `now`
+// If none of the months of a whole calendar cycle has the occurrence, the job never runs and is invalid
+func (j *job) nextWeekdayOccurrence(month, now time.Time, years, months int) time.Time {
+	for misses := 0; misses < calendarCycle; month = month.AddDate(years, months, 0) {
+		t, ok := weekdayOccurrence(month, time.Weekday(j.Weekday), j.Occurrence)
+		if ok && !j.wallClock(t).Before(now) {
+			return j.wallClock(t)
+		} else if ok {
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+	j.invalid(fmt.Sprintf("the job never runs, none of its months has occurrence %d of %s", j.Occurrence, time.Weekday(j.Weekday)))
+	return time.Time{}
+}
+
+// nextMonthDay returns the first run of the job on its day that is not before `now`, starting with the month of `month`,
+// which must be the first of a month, and advancing by `years` and `months`. The months that do not have the day are
+// clamped or skipped according to the `MissingDayPolicy` of the job. If none of the months of a whole calendar cycle has the day,
+// the job never runs and is invalid
+func (j *job) nextMonthDay(month, now time.Time, years, months int) time.Time {
+	for misses := 0; misses < calendarCycle; month = month.AddDate(years, months, 0) {
+		t, ok := monthDay(month, j.Day, j.MissingDay == ClampMissingDay)
+		if ok && !j.wallClock(t).Before(now) {
+			return j.wallClock(t)
+		} else if ok {
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+	j.invalid(fmt.Sprintf("the job never runs, none of its months has day %d", j.Day))
+	return time.Time{}
+}
+
+// monthDay returns the `day` of the month of `t` at the clock time of `t`. If the month does not have the day,
+// it returns the last day of the month when `clamp` is true, or false otherwise
+func monthDay(t time.Time, day int, clamp bool) (time.Time, bool) {
+	if last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day(); day > last {
+		if !clamp {
+			return t, false
+		}
+		day = last
+	}
+	return time.Date(t.Year(), t.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()), true
+}
+
+// weekdayOccurrence returns the nth `weekday` in the month of `t` at the clock time of `t`.
+// Negative values of n count back from the end of the month. It returns false if the month has no nth `weekday`
+func weekdayOccurrence(t time.Time, weekday time.Weekday, n int) (time.Time, bool) {
+	var d time.Time
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if n > 0 {
+		d = first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+(n-1)*7)
+	} else {
+		last := first.AddDate(0, 1, -1)
+		d = last.AddDate(0, 0, -(int(last.Weekday())-int(weekday)+7)%7+(n+1)*7)
 	}
+	return d, d.Month() == t.Month()
 }
 
 // formatDay formats the day in `Job.Description`
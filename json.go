@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// jobJSON is the JSON representation of a job, which is shared by `JobSpec`, so that
+// a marshaled job can be unmarshaled into a `JobSpec` and scheduled again
+type jobJSON struct {
+	Name      string          `json:"name"`
+	Tenant    string          `json:"tenant,omitempty"`
+	PinnedTo  string          `json:"pinned_to,omitempty"`
+	Every     int             `json:"every,omitempty"`
+	Interval  IntervalType    `json:"interval"`
+	Month     time.Month      `json:"month,omitempty"`
+	Day       int             `json:"day,omitempty"`
+	Days      []int           `json:"days,omitempty"`
+	Hour      int             `json:"hour,omitempty"`
+	Minute    int             `json:"minute,omitempty"`
+	Second    int             `json:"second,omitempty"`
+	Offset    string          `json:"offset,omitempty"`
+	Starting  *time.Time      `json:"starting,omitempty"`
+	Until     *time.Time      `json:"until,omitempty"`
+	Times     int             `json:"times,omitempty"`
+	Expire    string          `json:"expire_after,omitempty"`
+	Timezone  string          `json:"timezone,omitempty"`
+	NextRunAt *time.Time      `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time      `json:"last_run_at,omitempty"`
+	Paused    bool            `json:"paused,omitempty"`
+	Completed bool            `json:"completed,omitempty"`
+	Stats     *JobStats       `json:"stats,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// MarshalJSON marshals the schedule of the job together with its state
+func (j *job) MarshalJSON() ([]byte, error) {
+	stats := j.Stats()
+	v := jobJSON{
+		Name:      j.JobName,
+		Tenant:    j.TenantName,
+		PinnedTo:  j.PinnedTo,
+		Every:     j.IntervalAmount,
+		Interval:  j.IntervalType,
+		Month:     time.Month(j.Month),
+		Day:       j.Day,
+		Hour:      j.Hour,
+		Minute:    j.Minute,
+		Second:    j.Second,
+		Offset:    durationJSON(j.OffsetDuration),
+		Starting:  timeJSON(j.StartAt),
+		Until:     timeJSON(j.EndAt),
+		Times:     j.MaxRuns,
+		Expire:    durationJSON(j.Expiry),
+		Timezone:  j.location().String(),
+		NextRunAt: timeJSON(j.NextRunAt),
+		LastRunAt: timeJSON(j.LastRunAt),
+		Paused:    atomic.LoadInt32(&j.paused) == 1,
+		Completed: j.completed(),
+		Stats:     &stats,
+		Payload:   json.RawMessage(j.Record.Payload),
+	}
+	if j.DayMask != 0 {
+		for _, d := range j.weekdays() {
+			if d != j.Day {
+				v.Days = append(v.Days, d)
+			}
+		}
+	}
+	return json.Marshal(v)
+}
+
+// MarshalJSON marshals the spec in the same format as a `Job`
+func (spec JobSpec) MarshalJSON() ([]byte, error) {
+	v := jobJSON{
+		Name:     spec.Name,
+		Tenant:   spec.Tenant,
+		PinnedTo: spec.PinnedTo,
+		Every:    spec.Every,
+		Interval: spec.Interval,
+		Month:    spec.Month,
+		Day:      spec.Day,
+		Days:     spec.Days,
+		Hour:     spec.Hour,
+		Minute:   spec.Minute,
+		Second:   spec.Second,
+		Offset:   durationJSON(spec.Offset),
+		Starting: timeJSON(spec.Starting),
+		Until:    timeJSON(spec.Until),
+		Times:    spec.Times,
+		Expire:   durationJSON(spec.ExpireAfter),
+		Payload:  spec.Payload,
+	}
+	if spec.Timezone != nil {
+		v.Timezone = spec.Timezone.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON unmarshals a spec, or the schedule of a marshaled `Job`. The state of the job is ignored and `Do` is not set
+func (spec *JobSpec) UnmarshalJSON(data []byte) error {
+	var v jobJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*spec = JobSpec{
+		Name:     v.Name,
+		Tenant:   v.Tenant,
+		PinnedTo: v.PinnedTo,
+		Every:    v.Every,
+		Interval: v.Interval,
+		Month:    v.Month,
+		Day:      v.Day,
+		Days:     v.Days,
+		Hour:     v.Hour,
+		Minute:   v.Minute,
+		Second:   v.Second,
+		Times:    v.Times,
+		Payload:  v.Payload,
+	}
+	if v.Starting != nil {
+		spec.Starting = *v.Starting
+	}
+	if v.Until != nil {
+		spec.Until = *v.Until
+	}
+	if len(v.Offset) > 0 {
+		d, err := time.ParseDuration(v.Offset)
+		if err != nil {
+			return err
+		}
+		spec.Offset = d
+	}
+	if len(v.Expire) > 0 {
+		d, err := time.ParseDuration(v.Expire)
+		if err != nil {
+			return err
+		}
+		spec.ExpireAfter = d
+	}
+	if len(v.Timezone) > 0 {
+		loc, err := time.LoadLocation(v.Timezone)
+		if err != nil {
+			return err
+		}
+		spec.Timezone = loc
+	}
+	return nil
+}
+
+// timeJSON returns a pointer to `t`, or nil if it is zero so that it is omitted
+func timeJSON(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// durationJSON formats `d`, or returns an empty string if it is zero so that it is omitted
+func durationJSON(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,262 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// errNotConnected is returned by a `lazyStore` until the database is connected to
+var errNotConnected = errors.New("the database is not connected yet")
+
+// maxConnectBackoff is the longest amount of time between two attempts to connect to the database
+const maxConnectBackoff = time.Minute
+
+// claimAttempts is the number of times a claim is attempted when the connection to the database fails,
+// waiting `claimBackoff` after the first failure and twice as long after every other one
+const (
+	claimAttempts = 3
+	claimBackoff  = 100 * time.Millisecond
+)
+
+// connectionErrors are the errors of a connection to the database that dropped, see `isConnectionError`
+var connectionErrors = []error{driver.ErrBadConn, sql.ErrConnDone, io.EOF, io.ErrUnexpectedEOF}
+
+// isConnectionError reports whether `err` means that the connection to the database dropped, so that the operation
+// might succeed once it is retried
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, target := range connectionErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// lazyStore is the `Store` of a scheduler that connects to its mysql database when it is started instead of when it is created,
+// so that a service can boot while the database is briefly unavailable. Until it is connected, the jobs are only added
+// to the scheduler and no execution can be claimed
+type lazyStore struct {
+	mu    sync.RWMutex
+	open  func() (Store, error)
+	store Store
+}
+
+// connected returns the store once the database is connected to, or nil
+func (ls *lazyStore) connected() Store {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return ls.store
+}
+
+// Add implements `Store`. The jobs that are added before the database is connected to are added to it by `scheduler.connect`
+func (ls *lazyStore) Add(scheduler string, r *Record) error {
+	if store := ls.connected(); store != nil {
+		return store.Add(scheduler, r)
+	}
+	return nil
+}
+
+// AddAll implements `BulkAdder`
+func (ls *lazyStore) AddAll(scheduler string, rs []*Record) error {
+	if store := ls.connected(); store != nil {
+		return addAll(store, scheduler, rs)
+	}
+	return nil
+}
+
+// Claim implements `Store`
+func (ls *lazyStore) Claim(scheduler string, r *Record) error {
+	if store := ls.connected(); store != nil {
+		return store.Claim(scheduler, r)
+	}
+	return errNotConnected
+}
+
+// List implements `Lister`
+func (ls *lazyStore) List(scheduler string) ([]Record, error) {
+	if l, ok := ls.connected().(Lister); ok {
+		return l.List(scheduler)
+	}
+	return nil, errNotConnected
+}
+
+// Pause implements `Editor`
+func (ls *lazyStore) Pause(scheduler, name string, paused bool) error {
+	if e, ok := ls.connected().(Editor); ok {
+		return e.Pause(scheduler, name, paused)
+	}
+	return errNotConnected
+}
+
+// Disable implements `Disabler`
+func (ls *lazyStore) Disable(scheduler, name string, disabled bool) error {
+	if d, ok := ls.connected().(Disabler); ok {
+		return d.Disable(scheduler, name, disabled)
+	}
+	return errNotConnected
+}
+
+// Remove implements `Editor`
+func (ls *lazyStore) Remove(scheduler, name string) error {
+	if e, ok := ls.connected().(Editor); ok {
+		return e.Remove(scheduler, name)
+	}
+	return errNotConnected
+}
+
+// Finish implements `Finisher`
+func (ls *lazyStore) Finish(scheduler string, r *Record, duration time.Duration, err error) error {
+	if f, ok := ls.connected().(Finisher); ok {
+		return f.Finish(scheduler, r, duration, err)
+	}
+	return errNotConnected
+}
+
+// Audit implements `Auditor`
+func (ls *lazyStore) Audit(scheduler string, e AuditEntry) error {
+	if a, ok := ls.connected().(Auditor); ok {
+		return a.Audit(scheduler, e)
+	}
+	return errNotConnected
+}
+
+// History implements `Auditor`
+func (ls *lazyStore) History(scheduler, name string) ([]AuditEntry, error) {
+	if a, ok := ls.connected().(Auditor); ok {
+		return a.History(scheduler, name)
+	}
+	return nil, errNotConnected
+}
+
+// Heartbeat implements `Membership`
+func (ls *lazyStore) Heartbeat(scheduler, instance string, expires time.Time) error {
+	if m, ok := ls.connected().(Membership); ok {
+		return m.Heartbeat(scheduler, instance, expires)
+	}
+	return errNotConnected
+}
+
+// Members implements `Membership`
+func (ls *lazyStore) Members(scheduler string, now time.Time) ([]string, error) {
+	if m, ok := ls.connected().(Membership); ok {
+		return m.Members(scheduler, now)
+	}
+	return nil, errNotConnected
+}
+
+// Now implements `Clock`
+func (ls *lazyStore) Now() (time.Time, error) {
+	store := ls.connected()
+	if store == nil {
+		return time.Time{}, errNotConnected
+	} else if c, ok := store.(Clock); ok {
+		return c.Now()
+	}
+	return time.Now(), nil
+}
+
+// Ping implements `Pinger`
+func (ls *lazyStore) Ping(ctx context.Context) error {
+	store := ls.connected()
+	if store == nil {
+		return errNotConnected
+	} else if p, ok := store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// addAll saves `rs` to `store` at once if it implements `BulkAdder`, or one by one otherwise
+func addAll(store Store, scheduler string, rs []*Record) error {
+	if b, ok := store.(BulkAdder); ok && len(rs) > 1 {
+		return b.AddAll(scheduler, rs)
+	}
+	for _, r := range rs {
+		if err := store.Add(scheduler, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connected reports whether the store of the scheduler can claim executions, which is false until a lazily connected database is connected to
+func (s *scheduler) connected() bool {
+	ls, ok := s.store.(*lazyStore)
+	return !ok || ls.connected() != nil
+}
+
+// connect connects to the database of a lazily connected store, retrying with the `Config.ConnectRetry` policy until `quit` is closed,
+// then adds the jobs that were added to the scheduler in the meantime to it
+func (s *scheduler) connect(ls *lazyStore, quit chan struct{}) {
+	var store Store
+	for attempt := 1; store == nil; attempt++ {
+		var err error
+		if store, err = ls.open(); err == nil {
+			break
+		}
+		s.fail(fmt.Errorf("%s failed to connect to the database (attempt %d): %s", s.name, attempt, err))
+		if s.connectRetry.Attempts > 0 && attempt >= s.connectRetry.Attempts {
+			return
+		}
+		delay := s.connectRetry.delay(attempt)
+		if delay <= 0 {
+			delay = time.Second
+		} else if delay > maxConnectBackoff {
+			delay = maxConnectBackoff
+		}
+		select {
+		case <-time.After(delay):
+		case <-quit:
+			return
+		}
+	}
+
+	// add the jobs to the database before any other job is added
+	s.mu.Lock()
+	var jobs []*job
+	var records []*Record
+	checksums := map[*job]string{}
+	for _, a := range s.jobs {
+		j := a.(*job)
+		jobs = append(jobs, j)
+		records = append(records, &j.Record)
+		checksums[j] = j.Checksum
+	}
+	var err error
+	if !s.observer {
+		err = addAll(store, s.table, records)
+	}
+
+	// apply the stored state before the store is published, since the ticker claims and executes the jobs as soon as it is
+	for _, j := range jobs {
+		j.executing.Lock()
+		s.registered(j, checksums[j])
+		j.executing.Unlock()
+	}
+	ls.mu.Lock()
+	ls.store = store
+	ls.mu.Unlock()
+	s.mu.Unlock()
+	if err != nil {
+		s.fail(fmt.Errorf("%s failed to add its jobs to the database: %s", s.name, err))
+	}
+	if !s.observer {
+		for _, j := range jobs {
+			s.audited(j)
+		}
+	}
+	if s.binds() {
+		s.discover()
+	}
+}
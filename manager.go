@@ -0,0 +1,87 @@
+package schedule
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Manager owns several named schedulers, ie one per domain, so that they can be started and stopped together.
+// The schedulers created by the manager share its `Store`, and so its database connection pool
+type Manager struct {
+	store      Store
+	mu         sync.Mutex
+	schedulers []Scheduler
+}
+
+// NewManager creates a `Manager` whose schedulers share `store`, ie a `SQLStore` opened with one `*sql.DB`
+func NewManager(store Store) *Manager {
+	return &Manager{store: store}
+}
+
+// New creates a scheduler from `cfg` that is owned by the manager. Unless `cfg.Store` is set, it uses the store of the manager
+func (m *Manager) New(cfg *Config) (Scheduler, error) {
+	c := *cfg
+	if c.Store == nil {
+		c.Store = m.store
+	}
+	s, err := New(&c)
+	if err != nil {
+		return nil, err
+	} else if err := m.Manage(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Manage adds a scheduler created elsewhere to the manager, ie a decorated one
+func (m *Manager) Manage(s Scheduler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.schedulers {
+		if a.Name() == s.Name() {
+			return fmt.Errorf("%s is already managed", s.Name())
+		}
+	}
+	m.schedulers = append(m.schedulers, s)
+	return nil
+}
+
+// Scheduler returns the scheduler named `name`, or nil if the manager does not own it
+func (m *Manager) Scheduler(name string) Scheduler {
+	for _, s := range m.Schedulers() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Schedulers returns the schedulers owned by the manager in the order they were added
+func (m *Manager) Schedulers() []Scheduler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Scheduler(nil), m.schedulers...)
+}
+
+// List returns the jobs of every scheduler owned by the manager
+func (m *Manager) List() []Job {
+	var jobs []Job
+	for _, s := range m.Schedulers() {
+		jobs = append(jobs, s.List()...)
+	}
+	return jobs
+}
+
+// Start starts every scheduler owned by the manager
+func (m *Manager) Start() {
+	for _, s := range m.Schedulers() {
+		s.Start()
+	}
+}
+
+// Stop stops every scheduler owned by the manager
+func (m *Manager) Stop() {
+	for _, s := range m.Schedulers() {
+		s.Stop()
+	}
+}
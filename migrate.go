@@ -0,0 +1,172 @@
+package schedule
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Migration is a versioned change to the tables of a scheduler, so that the schema can be managed like the rest of an application's,
+// ie with its migrations tooling, instead of being migrated automatically when the scheduler is started
+type Migration struct {
+	// Version is the number of the migration. Migrations are applied in order of their versions
+	Version int
+
+	// Description describes the change
+	Description string
+
+	// SQL is the statement that applies the change. It is empty when the dialect does not need the change
+	SQL string
+
+	// missing returns the statement that applies the part of the change that `db` is missing, since `Config.AutoMigrate`
+	// may have applied it already, ie added some of the columns. It is nil if the change is applied as a whole
+	missing func(db *sql.DB) (string, error)
+}
+
+// Migrations returns the migrations of the tables of the scheduler whose table is named `table` with the given `dialect`
+// (ie "mysql" or "postgres"), in order. The first migration creates the table that the first version of this package created
+// and the others add the columns that were added since, so that a table created by `Config.AutoMigrate` with any version is adopted
+func Migrations(dialect, table string) ([]Migration, error) {
+	d, ok := sqlDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a supported dialect", dialect)
+	}
+	var migrations []Migration
+	for _, t := range tables {
+		migrations = append(migrations, Migration{Version: t.version, Description: t.description, SQL: t.create(d, table, t.version)})
+	}
+	migrations = append(migrations, []Migration{
+		{
+			Version:     12,
+			Description: "keep the fractions of a second of the times of the jobs",
+			SQL:         d.preciseTimes(table),
+		},
+		{
+			Version:     auditReasonVersion,
+			Description: "add the reasons of the audit log",
+			SQL:         d.addAuditReason(table),
+			missing: func(db *sql.DB) (string, error) {
+				existing, err := d.columns(db, table+"_audit")
+				if err != nil || existing["reason"] {
+					return "", err
+				}
+				return d.addAuditReason(table), nil
+			},
+		},
+	}...)
+	for _, a := range additions {
+		fields := a.fields
+		migrations = append(migrations, Migration{Version: a.version, Description: a.description, SQL: d.addColumns(table, fields...),
+			missing: func(db *sql.DB) (string, error) {
+				existing, err := d.columns(db, table)
+				if err != nil {
+					return "", err
+				}
+				var missing []string
+				for _, field := range fields {
+					if !existing[columnName(field)] {
+						missing = append(missing, field)
+					}
+				}
+				if len(missing) == 0 {
+					return "", nil
+				}
+				return d.addColumns(table, missing...), nil
+			}})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// tables are the tables of a scheduler and the migrations that create them. `create` returns the DDL of the table for the scheduler
+// whose table is named `name` as the migration `version` created it, or of its latest version if `version` is zero, which is what `Schema` returns
+var tables = []struct {
+	version     int
+	description string
+	create      func(d sqlDialect, name string, version int) string
+}{
+	{1, "create the table of the jobs", func(d sqlDialect, name string, version int) string {
+		return d.createTable(name, version > 0, version)
+	}},
+	{6, "create the table of the instances", func(d sqlDialect, name string, version int) string {
+		return d.createMembers(name)
+	}},
+	{7, "create the audit log", func(d sqlDialect, name string, version int) string {
+		return d.createAudit(name, version)
+	}},
+}
+
+// additions are the migrations that add the columns of the fields of `Record` that were added to the table of the jobs
+// after the first version of this package created it
+var additions = []struct {
+	version     int
+	description string
+	fields      []string
+}{
+	{2, "add the columns of the schedules", []string{"DayMask", "Weekday", "Occurrence", "WindowStart", "WindowEnd", "OffsetDuration",
+		"EndAt", "ElapsedTime", "AlignedTime", "MissingDay", "MaxRuns", "Expiry", "Zone"}},
+	{3, "add the tenants and the pins of the jobs", []string{"TenantName", "PinnedTo"}},
+	{4, "add the state shared by the instances", []string{"RunCount", "Paused", "Checksum", "Version"}},
+	{5, "add the statistics of the jobs", []string{"FailureCount", "LastError", "FinishCount", "TotalDuration", "JobDuration"}},
+	{8, "add the lease of the pending executions", []string{"PendingUntil"}},
+	{9, "add the keys of the executions", []string{"LastExecutionKey"}},
+	{10, "add the payloads of the jobs", []string{"Payload"}},
+	{11, "add the flag that disables the jobs", []string{"Disabled"}},
+}
+
+// addedFields are the fields of `Record` whose columns were added to the table of the jobs after it was created,
+// by the version of the migration that added them
+var addedFields = map[string]int{}
+
+func init() {
+	for _, a := range additions {
+		for _, field := range a.fields {
+			addedFields[field] = a.version
+		}
+	}
+}
+
+// Migrate applies the migrations of the tables of the scheduler whose table is named `table` that were not applied to `db` yet,
+// keeping track of them in a table with a "_migrations" suffix. It should be run by one process, ie as a step of a deploy
+func Migrate(db *sql.DB, dialect, table string) error {
+	migrations, err := Migrations(dialect, table)
+	if err != nil {
+		return err
+	}
+	d := sqlDialects[dialect]
+	versions := d.quoteTable(table + "_migrations")
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s, PRIMARY KEY (%s))",
+		versions, d.quote("version"), d.intType, d.quote("version"))); err != nil {
+		return err
+	}
+	var current int
+	if err := db.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(%s), 0) FROM %s", d.quote("version"), versions)).Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		// the columns that a table created by `Config.AutoMigrate` already has are not added again
+		stmt := m.SQL
+		if m.missing != nil {
+			if stmt, err = m.missing(db); err != nil {
+				return err
+			}
+		}
+		if stmt != "" {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("migration %d failed: %s", m.Version, err)
+			}
+		}
+		if _, err := db.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", versions, d.quote("version"), d.placeholder(1)), m.Version); err != nil {
+			return err
+		}
+		log.Printf("schedule: %s was migrated to version %d: %s", table, m.Version, m.Description)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package mongostore implements a `schedule.Store` with a MongoDB collection, for services that already persist everything in MongoDB
+package mongostore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/marksalpeter/schedule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAttempts is the number of times `Store.Add` is attempted when another instance writes the same job concurrently
+const maxAttempts = 3
+
+// Collection is the part of the MongoDB api used by `Store`. `*mongo.Collection` satisfies this interface
+type Collection interface {
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
+	FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.FindOneAndReplaceOptions) *mongo.SingleResult
+}
+
+// Store is a `schedule.Store` that keeps the records of the jobs of every scheduler in a MongoDB collection.
+// Executions are claimed with a findAndModify on the version of the record, so no locks are held
+type Store struct {
+	collection Collection
+}
+
+// document is how a record is stored in the collection
+type document struct {
+	ID        bson.D          `bson:"_id"`
+	Scheduler string          `bson:"scheduler"`
+	Record    schedule.Record `bson:"record"`
+}
+
+// New creates a `Store` that uses `collection`
+func New(collection Collection) *Store {
+	return &Store{collection: collection}
+}
+
+// Add implements `schedule.Store`
+func (s *Store) Add(scheduler string, r *schedule.Record) error {
+	for attempt := 1; ; attempt++ {
+		stored, err := s.get(scheduler, r.JobName)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			_, err = s.collection.InsertOne(context.Background(), document{
+				ID:        id(scheduler, r.JobName),
+				Scheduler: scheduler,
+				Record:    *r,
+			})
+			if !mongo.IsDuplicateKeyError(err) || attempt >= maxAttempts {
+				return err
+			}
+			continue
+		}
+		r.Merge(stored)
+		err = s.replace(scheduler, r, stored)
+		if err != schedule.ErrAlreadyExecuted || attempt >= maxAttempts {
+			return err
+		}
+	}
+}
+
+// Claim implements `schedule.Store`
+func (s *Store) Claim(scheduler string, r *schedule.Record) error {
+	stored, err := s.get(scheduler, r.JobName)
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return errors.New(r.JobName + " has not been added to the store")
+	} else if err := r.Claim(stored); err != nil {
+		return err
+	}
+	return s.replace(scheduler, r, stored)
+}
+
+// List implements `schedule.Lister`
+func (s *Store) List(scheduler string) ([]schedule.Record, error) {
+	cur, err := s.collection.Find(context.Background(), bson.M{"scheduler": scheduler})
+	if err != nil {
+		return nil, err
+	}
+	var docs []document
+	if err := cur.All(context.Background(), &docs); err != nil {
+		return nil, err
+	}
+	var records []schedule.Record
+	for _, d := range docs {
+		records = append(records, d.Record)
+	}
+	return records, nil
+}
+
+// get returns the stored record of the job named `name`, or nil if it is not stored
+func (s *Store) get(scheduler, name string) (*schedule.Record, error) {
+	var d document
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": id(scheduler, name)}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &d.Record, nil
+}
+
+// replace atomically replaces the `stored` record with `r`. It returns `schedule.ErrAlreadyExecuted` if another instance replaced it first
+func (s *Store) replace(scheduler string, r, stored *schedule.Record) error {
+	err := s.collection.FindOneAndReplace(context.Background(), bson.M{
+		"_id":            id(scheduler, r.JobName),
+		"record.version": stored.Version,
+	}, document{
+		ID:        id(scheduler, r.JobName),
+		Scheduler: scheduler,
+		Record:    *r,
+	}).Err()
+	if err == mongo.ErrNoDocuments {
+		return schedule.ErrAlreadyExecuted
+	}
+	return err
+}
+
+// id returns the id of the document of the job named `name`. It is a compound of both names, so that they can contain any character
+func id(scheduler, name string) bson.D {
+	return bson.D{{Key: "scheduler", Value: scheduler}, {Key: "job", Value: name}}
+}
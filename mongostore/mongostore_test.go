@@ -0,0 +1,154 @@
+package mongostore_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/mongostore"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestIDs(t *testing.T) {
+	store := mongostore.New(newCollection())
+	assert := assert.New(t)
+	assert.NoError(store.Add("a/b", &schedule.Record{JobName: "c", IntervalType: schedule.Once}))
+	assert.NoError(store.Add("a", &schedule.Record{JobName: "b/c", IntervalType: schedule.Once, RunCount: 1}))
+
+	records, err := store.List("a/b")
+	assert.NoError(err)
+	if assert.Len(records, 1, "the jobs of the schedulers do not collide") {
+		assert.Equal("c", records[0].JobName)
+		assert.Equal(0, records[0].RunCount)
+	}
+	records, err = store.List("a")
+	assert.NoError(err)
+	if assert.Len(records, 1) {
+		assert.Equal("b/c", records[0].JobName)
+		assert.Equal(1, records[0].RunCount)
+	}
+}
+
+func TestAddConflict(t *testing.T) {
+	c := newCollection()
+	store := mongostore.New(c)
+	assert := assert.New(t)
+
+	// another instance adds the job between the read and the insert of the first attempt
+	c.inserted = &schedule.Record{JobName: "job", IntervalType: schedule.Once, RunCount: 1}
+	assert.NoError(store.Add("mongo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), "the stored record is merged instead")
+	records, err := store.List("mongo-test")
+	assert.NoError(err)
+	if assert.Len(records, 1) {
+		assert.Equal(1, records[0].RunCount, "the runs of the stored record are kept")
+	}
+
+	// another instance writes the job between the read and the write of the first attempt
+	c.conflicts = 1
+	assert.NoError(store.Add("mongo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), "the add is retried")
+
+	// another instance keeps writing the job
+	c.conflicts = 10
+	assert.Error(store.Add("mongo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), "the add gives up after the last attempt")
+}
+
+func TestClaimConflict(t *testing.T) {
+	c := newCollection()
+	store := mongostore.New(c)
+	assert := assert.New(t)
+	assert.NoError(store.Add("mongo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+
+	// another instance claimed the execution first
+	c.conflicts = 1
+	assert.Equal(schedule.ErrAlreadyExecuted, store.Claim("mongo-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+}
+
+// collection is a fake of a MongoDB collection that keeps the documents in memory
+type collection struct {
+	mu        sync.Mutex
+	docs      map[string]bson.Raw
+	conflicts int
+	inserted  *schedule.Record
+}
+
+func newCollection() *collection {
+	return &collection{docs: map[string]bson.Raw{}}
+}
+
+func (c *collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	id := bson.Raw(doc).Lookup("_id")
+	if c.inserted != nil {
+		// another instance inserts the document first
+		other, err := bson.Marshal(bson.M{"_id": id, "scheduler": bson.Raw(doc).Lookup("scheduler"), "record": c.inserted})
+		if err != nil {
+			return nil, err
+		}
+		c.docs[id.String()] = other
+		c.inserted = nil
+	}
+	if _, ok := c.docs[id.String()]; ok {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "duplicate key"}}}
+	}
+	c.docs[id.String()] = doc
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+func (c *collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var docs []interface{}
+	for _, doc := range c.docs {
+		if doc.Lookup("scheduler").StringValue() == filter.(bson.M)["scheduler"] {
+			docs = append(docs, doc)
+		}
+	}
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+func (c *collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[key(filter)]
+	if !ok {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+func (c *collection) FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.FindOneAndReplaceOptions) *mongo.SingleResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[key(filter)]
+	if !ok || doc.Lookup("record", "version").AsInt64() != int64(filter.(bson.M)["record.version"].(int)) {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	} else if c.conflicts > 0 {
+		// another instance replaces the document first
+		c.conflicts--
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	replaced, err := bson.Marshal(replacement)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	c.docs[key(filter)] = replaced
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+// key returns the key of the document with the "_id" of `filter`
+func key(filter interface{}) string {
+	doc, err := bson.Marshal(bson.M{"_id": filter.(bson.M)["_id"]})
+	if err != nil {
+		panic(err)
+	}
+	return bson.Raw(doc).Lookup("_id").String()
+}
@@ -0,0 +1,13 @@
+//go:build nogorm
+
+package schedule
+
+import "errors"
+
+// gormBuilt reports whether gorm is part of the build
+const gormBuilt = false
+
+// newGormStore is not available when gorm is left out of the build with the `nogorm` tag
+func newGormStore(name string, cfg *Config) (Store, error) {
+	return nil, errors.New("schedule was built without gorm, use a `SQLStore` as the `Config.Store` instead")
+}
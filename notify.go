@@ -0,0 +1,251 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier delivers notifications about the jobs of a `Scheduler`
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Notification is a message sent to a `Notifier`
+type Notification struct {
+	// Scheduler is the name of the scheduler the notification is about
+	Scheduler string `json:"scheduler"`
+
+	// Job is the name of the job the notification is about, if any
+	Job string `json:"job,omitempty"`
+
+	// Outcome is the outcome of the execution the notification is about, if any
+	Outcome Outcome `json:"outcome,omitempty"`
+
+	// Subject is a one line summary of the notification
+	Subject string `json:"subject"`
+
+	// Message is the plain text body of the notification
+	Message string `json:"message"`
+}
+
+// Outcome is the outcome of an execution reported by `WithNotifications`
+type Outcome string
+
+const (
+	// Succeeded is the outcome of an execution that finished without panicking
+	Succeeded = Outcome("success")
+
+	// Failed is the outcome of an execution that panicked
+	Failed = Outcome("failure")
+
+	// Missed is the outcome of an execution that started late, ie because the scheduler was not running when it was due
+	Missed = Outcome("missed")
+)
+
+// WithNotifications wraps `s` so that the outcome of every execution of the jobs added through it is sent to `n`.
+// Executions that start more than `missedAfter` after they were due are also reported as `Missed`, a `missedAfter` of 0 disables them.
+// Only the `outcomes` passed in are sent, or all of them if none are.
+// Notifications are sent in the background so that a slow notifier does not delay the jobs
+func WithNotifications(s Scheduler, n Notifier, missedAfter time.Duration, outcomes ...Outcome) Scheduler {
+	send := map[Outcome]bool{}
+	for _, o := range outcomes {
+		send[o] = true
+	}
+	notify := func(j Job, outcome Outcome, message string) {
+		if len(send) > 0 && !send[outcome] {
+			return
+		}
+		go func() {
+			if err := n.Notify(Notification{
+				Scheduler: j.Scheduler().Name(),
+				Job:       j.Name(),
+				Outcome:   outcome,
+				Subject:   fmt.Sprintf("%s: %s", j.Name(), outcome),
+				Message:   message,
+			}); err != nil {
+				log.Printf("schedule: %s failed to send the %s notification of %s: %s", j.Scheduler().Name(), outcome, j.Name(), err)
+			}
+		}()
+	}
+	return decorate(s, func(do func(Job, time.Time)) func(Job, time.Time) {
+		return func(j Job, t time.Time) {
+			if jj, ok := j.(*job); ok && missedAfter > 0 && jj.now().Sub(jj.LastRunAt) > missedAfter {
+				notify(j, Missed, fmt.Sprintf("%s was due at %s", j.Name(), jj.LastRunAt.In(j.Location()).Format(time.RFC3339)))
+			}
+			if err := try(do, j, t); err != nil {
+				notify(j, Failed, err.Error())
+				panic(err)
+			}
+			notify(j, Succeeded, fmt.Sprintf("%s succeeded", j.Name()))
+		}
+	})
+}
+
+// WithDigest wraps `s` so that the outcomes of all of its executions are batched into a single daily summary
+// which is sent to `n` at midnight while the scheduler is running.
+// Note: when using database synchronicity, each instance only reports the executions it performed
+func WithDigest(s Scheduler, n Notifier) Scheduler {
+	d := &digest{
+		notifier: n,
+		failed:   map[string]bool{},
+	}
+	d.decorator = &decorator{Scheduler: s, wrap: observe(d), self: d}
+	if _, ok := s.(Replacer); ok {
+		r := &replacingDigest{d}
+		d.self = r
+		return r
+	}
+	return d
+}
+
+// digest implements `Scheduler` and `MetricsRegistry`. It decorates the scheduler like `WithMetrics` and reports the outcomes itself
+type digest struct {
+	*decorator
+	notifier Notifier
+	mu       sync.Mutex
+	day      time.Time
+	outcomes []outcome
+	failed   map[string]bool
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// outcome is the result of a single execution
+type outcome struct {
+	job      string
+	duration time.Duration
+	err      error
+}
+
+// replacingDigest is a `digest` of a `Replacer`, which is a `Replacer` as well
+type replacingDigest struct {
+	*digest
+}
+
+// AddOrReplace create a new job that replaces the job named `name` if it was already added through the digest
+func (d *replacingDigest) AddOrReplace(name string) Amount {
+	return d.adopt(d.Scheduler.(Replacer).AddOrReplace(name))
+}
+
+// Start starts the scheduler and the daily digest
+func (d *digest) Start() {
+	d.stopDigest()
+	d.decorator.Start()
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	d.mu.Lock()
+	d.quit = quit
+	d.done = done
+	d.mu.Unlock()
+
+	// the digest also stops with the scheduler it decorates, ie when it is stopped with `Drainer.StopContext` after `As` found it
+	stopped := stopping(d)
+	go func() {
+		defer close(done)
+		for {
+			now := time.Now()
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			timer := time.NewTimer(midnight.Sub(now))
+			select {
+			case <-timer.C:
+				d.send()
+			case <-quit:
+				timer.Stop()
+				return
+			case <-stopped:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler and the daily digest
+func (d *digest) Stop() {
+	d.stopDigest()
+	d.decorator.Stop()
+}
+
+// stopDigest stops the daily digest if it is running
+func (d *digest) stopDigest() {
+	d.mu.Lock()
+	quit, done := d.quit, d.done
+	d.quit = nil
+	d.done = nil
+	d.mu.Unlock()
+	if quit == nil {
+		return
+	}
+	close(quit)
+	<-done
+}
+
+// Observe implements `MetricsRegistry`
+func (d *digest) Observe(j Job, duration time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.day.IsZero() {
+		now := time.Now()
+		d.day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	}
+	d.outcomes = append(d.outcomes, outcome{
+		job:      j.Name(),
+		duration: duration,
+		err:      err,
+	})
+}
+
+// send sends the summary of the outcomes collected since the last digest to the notifier
+func (d *digest) send() {
+	d.mu.Lock()
+	day := d.day
+	outcomes := d.outcomes
+	d.day = time.Time{}
+	d.outcomes = nil
+	d.mu.Unlock()
+	if len(outcomes) == 0 {
+		return
+	}
+
+	// count the successes and failures
+	var succeeded, failed int
+	var newFailures []string
+	failedToday := map[string]bool{}
+	for _, o := range outcomes {
+		if o.err == nil {
+			succeeded++
+			continue
+		}
+		failed++
+		if !d.failed[o.job] && !failedToday[o.job] {
+			newFailures = append(newFailures, o.job)
+		}
+		failedToday[o.job] = true
+	}
+	d.failed = failedToday
+
+	// find the slowest executions
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		return outcomes[i].duration > outcomes[j].duration
+	})
+	var slowest []string
+	for i := 0; i < len(outcomes) && i < 3; i++ {
+		slowest = append(slowest, fmt.Sprintf("%s (%s)", outcomes[i].job, outcomes[i].duration))
+	}
+
+	message := fmt.Sprintf("%d succeeded, %d failed\nslowest: %s", succeeded, failed, strings.Join(slowest, ", "))
+	if len(newFailures) > 0 {
+		message += fmt.Sprintf("\nnew failures: %s", strings.Join(newFailures, ", "))
+	}
+	if err := d.notifier.Notify(Notification{
+		Scheduler: d.Name(),
+		Subject:   fmt.Sprintf("%s digest for %s", d.Name(), day.Format("2006-01-02")),
+		Message:   message,
+	}); err != nil {
+		log.Printf("schedule: %s failed to send its digest: %s", d.Name(), err)
+	}
+}
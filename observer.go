@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// observerDiscovery is how often an observer refreshes its jobs when `Config.Discovery` is not set
+const observerDiscovery = 10 * time.Second
+
+// observe makes the jobs of an observer match the stored `records`. The stored jobs are added whether they have a task or not,
+// the state of the jobs that were already added is refreshed and the jobs that are no longer stored are removed
+func (s *scheduler) observe(records []Record) {
+	stored := map[string]bool{}
+	for _, r := range records {
+		stored[r.JobName] = true
+		if j, err := s.job(r.JobName); err == nil {
+			j.refresh(r)
+		} else if err := s.add(s.stored(r, nil)); err != nil {
+			log.Println(err)
+		}
+	}
+
+	// forget the jobs that were removed from the store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []Job
+	for _, j := range s.jobs {
+		if stored[j.Name()] {
+			jobs = append(jobs, j)
+			continue
+		}
+		s.forget(j.(*job))
+	}
+	s.jobs = jobs
+}
+
+// refresh replaces the state of the job with the state of its stored record `r`
+func (j *job) refresh(r Record) {
+	j.executing.Lock()
+	defer j.executing.Unlock()
+
+	// the settings of the scheduler aren't stored, so they are kept as they were set by `stored`
+	drift, granularity, skew, lease, redelivery, implicitStart, clock := j.drift, j.granularity, j.skew, j.lease, j.redelivery, j.implicitStart, j.clock
+	j.Record = r
+	j.drift, j.granularity, j.skew, j.lease, j.redelivery, j.implicitStart, j.clock = drift, granularity, skew, lease, redelivery, implicitStart, clock
+	j.stats.Store(r.stats())
+	var paused int32
+	if r.Paused {
+		paused = 1
+	}
+	atomic.StoreInt32(&j.paused, paused)
+}
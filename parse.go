@@ -0,0 +1,244 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// units are the names of the intervals that `Parse` understands
+var units = map[string]IntervalType{
+	"year": Years, "years": Years,
+	"month": Months, "months": Months,
+	"week": Weeks, "weeks": Weeks,
+	"day": Days, "days": Days,
+	"weekday": Weekdays, "weekdays": Weekdays,
+	"hour": Hours, "hours": Hours,
+	"minute": Minutes, "minutes": Minutes,
+	"second": Seconds, "seconds": Seconds,
+	"millisecond": Milliseconds, "milliseconds": Milliseconds,
+}
+
+// Parse parses a schedule written in plain english into a `JobSpec`, ie "every 2 weeks on monday and thursday at 9:30am".
+// It understands the sentences that `JobInfo.Description` returns, which mirror the builder methods:
+//
+//	once on 2024-01-02 at 9:00
+//	every [n|other] years|months|weeks|days|weekdays|hours|minutes|seconds|milliseconds
+//	every monday
+//	on monday, wednesday and friday | on the 15th | on february 28 | on day 28 of february | in february
+//	at 9 | at 9:30pm | at 21:30:15 | at noon | at midnight
+//	3 times | until 2024-12-31 | starting 2024-01-01
+//
+// The dates are in the local timezone. The `Name` and `Do` of the spec must be set before it is scheduled
+func Parse(s string) (JobSpec, error) {
+	p := &parser{tokens: strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})}
+	spec, err := p.parse()
+	if err != nil {
+		return JobSpec{}, fmt.Errorf("%q is not a valid schedule: %s", s, err)
+	}
+	return spec, nil
+}
+
+// parser holds the words of a schedule that `Parse` has not read yet
+type parser struct {
+	tokens []string
+}
+
+// peek returns the next word, or an empty string at the end of the schedule
+func (p *parser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+// next reads the next word
+func (p *parser) next() string {
+	t := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+// parse reads the interval of the schedule and then its clauses
+func (p *parser) parse() (JobSpec, error) {
+	var spec JobSpec
+	var date, clock *time.Time
+	switch t := p.next(); t {
+	case "once":
+		spec.Interval = Once
+	case "every":
+		spec.Every = 1
+		if n, err := strconv.Atoi(p.peek()); err == nil {
+			spec.Every = n
+			p.next()
+		} else if p.peek() == "other" {
+			spec.Every = 2
+			p.next()
+		}
+		unit := p.next()
+		if d, ok := weekday(unit); ok {
+			spec.Interval, spec.Day = Weeks, d
+		} else if i, ok := units[unit]; ok {
+			spec.Interval = i
+		} else {
+			return spec, fmt.Errorf("%q is not an interval", unit)
+		}
+	default:
+		return spec, fmt.Errorf("it should start with once or every, not %q", t)
+	}
+
+	// read the clauses in any order
+	var days []int
+	for len(p.tokens) > 0 {
+		switch t := p.next(); t {
+		case "on":
+			for {
+				if d, ok := weekday(p.peek()); ok {
+					days = append(days, d)
+				} else if m, ok := month(p.peek()); ok {
+					spec.Month = m
+				} else if d, ok := dayOfMonth(p.peek()); ok {
+					spec.Day = d
+				} else if t, err := time.ParseInLocation("2006-01-02", p.peek(), time.Local); err == nil {
+					date = &t
+				} else if w := p.peek(); w != "the" && w != "day" && w != "of" && w != "and" {
+					break
+				}
+				p.next()
+			}
+		case "in":
+			m, ok := month(p.next())
+			if !ok {
+				return spec, fmt.Errorf("in should be followed by a month")
+			}
+			spec.Month = m
+		case "at":
+			t, err := timeOfDay(p)
+			if err != nil {
+				return spec, err
+			}
+			clock = &t
+			spec.Hour, spec.Minute, spec.Second = t.Hour(), t.Minute(), t.Second()
+		case "until", "starting":
+			d, err := time.ParseInLocation("2006-01-02", p.next(), time.Local)
+			if err != nil {
+				return spec, fmt.Errorf("%s should be followed by a date like 2006-01-02", t)
+			} else if t == "until" {
+				spec.Until = d.AddDate(0, 0, 1).Add(-time.Second)
+			} else {
+				spec.Starting = d
+			}
+		default:
+			n, err := strconv.Atoi(t)
+			if err != nil || (p.peek() != "times" && p.peek() != "time") {
+				return spec, fmt.Errorf("%q was not expected", t)
+			}
+			p.next()
+			spec.Times = n
+		}
+	}
+
+	// the weekdays of weekly jobs are the day of the spec and its other days
+	if len(days) > 0 && spec.Interval != Weeks {
+		return spec, fmt.Errorf("only weekly jobs run on weekdays")
+	} else if len(days) > 0 {
+		spec.Day, spec.Days = days[0], days[1:]
+	}
+	switch spec.Interval {
+	case Once:
+		if date == nil {
+			return spec, fmt.Errorf("once should be followed by a date like on 2006-01-02")
+		}
+		// the time of day of a once job is part of its starting time
+		spec.Starting, spec.Hour, spec.Minute, spec.Second = *date, 0, 0, 0
+		if clock != nil {
+			spec.Starting = time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.Local)
+		}
+	case Years:
+		if spec.Month == 0 || spec.Day == 0 {
+			return spec, fmt.Errorf("a yearly job should say on which month and day it runs")
+		}
+	case Months:
+		if spec.Day == 0 {
+			return spec, fmt.Errorf("a monthly job should say on which day it runs")
+		}
+	}
+	return spec, nil
+}
+
+// weekday parses the name of a weekday or its abbreviation
+func weekday(s string) (int, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name+"s" || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return int(d), true
+		}
+	}
+	return 0, false
+}
+
+// month parses the name of a month or its abbreviation
+func month(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		if name := strings.ToLower(m.String()); s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// dayOfMonth parses a day of the month, ie "15" or "15th"
+func dayOfMonth(s string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	d, err := strconv.Atoi(s)
+	return d, err == nil && d >= 1 && d <= 31
+}
+
+// timeOfDay reads a time of day, ie "9", "9:30pm", "9:30 pm", "21:30:15", "noon" or "midnight"
+func timeOfDay(p *parser) (time.Time, error) {
+	s := p.next()
+	switch s {
+	case "noon":
+		return time.Date(0, 1, 1, 12, 0, 0, 0, time.UTC), nil
+	case "midnight":
+		return time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	if p.peek() == "am" || p.peek() == "pm" {
+		s += p.next()
+	}
+	meridiem := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		s, meridiem = s[:len(s)-2], s[len(s)-2:]
+	}
+	var hms [3]int
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return time.Time{}, fmt.Errorf("%q is not a time of day", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && (len(part) != 2 || n > 59)) {
+			return time.Time{}, fmt.Errorf("%q is not a time of day", s)
+		}
+		hms[i] = n
+	}
+	switch {
+	case meridiem != "" && (hms[0] < 1 || hms[0] > 12):
+		return time.Time{}, fmt.Errorf("%q is not a time of day", s+meridiem)
+	case meridiem == "pm" && hms[0] < 12:
+		hms[0] += 12
+	case meridiem == "am" && hms[0] == 12:
+		hms[0] = 0
+	case hms[0] > 23:
+		return time.Time{}, fmt.Errorf("%q is not a time of day", s)
+	}
+	return time.Date(0, 1, 1, hms[0], hms[1], hms[2], 0, time.UTC), nil
+}
@@ -8,15 +8,23 @@
 package schedule
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/mysql" // import the sql driver
 )
 
-// Scheduler executes a sets of `Jobs` at a given time
+// Scheduler executes a sets of `Jobs` at a given time.
+// The schedulers created by `New` also implement every optional interface below, ie `Replayer` or `Monitor`,
+// which the callers type-assert, so that the other implementations, ie decorators and fakes, only need the methods they support.
+// The decorators only implement the ones they can provide whatever they wrap, so the others are found through them with `As`
 type Scheduler interface {
 	// Name is the unique name of the scheduler. Note: any scheduler with the same name will reference the same table name for synchronicity purposes
 	Name() string
@@ -25,22 +33,162 @@ type Scheduler interface {
 	List() []Job
 
 	// Add create a new job ascociated with the scheduler and returns its first builder method
-	// Note: it will not be added to the scheduler until it is done being built (ie `Do` is called)
+	// Note: it will not be added to the scheduler until it is done being built (ie `Do` is called).
+	// A builder must only be used by one goroutine, but separate builders can be used concurrently. `Do` is the only synchronizing point,
+	// so jobs can safely be added before or after the scheduler is started
 	Add(name string) Amount
 
 	// Start starts the scheduler
 	Start()
 
-	// Stop stops the scheduler
+	// Stop stops the scheduler. It waits for the jobs that are running to finish
 	Stop()
+}
+
+// Replacer is implemented by the schedulers that can replace the definition of a job, ie when a configuration is redeployed
+type Replacer interface {
+	// AddOrReplace is like `Add`, but when `Do` is called it replaces the definition of the job named `name`
+	// in the scheduler and the database if it was already added, ie when a configuration is redeployed.
+	// The replaced job keeps its run count, statistics and paused state
+	AddOrReplace(name string) Amount
+}
+
+// Remover is implemented by the schedulers that can remove a job while they are running
+type Remover interface {
+	// Remove removes the job named `name` from the scheduler and the store. Like `Add`, it can be called while the scheduler is running
+	Remove(name string) error
+}
+
+// SpecAdder is implemented by the schedulers that can add the jobs described by a `JobSpec`, ie from a configuration file
+type SpecAdder interface {
+	// Schedule adds the job described by `spec` to the scheduler and the database. Like `Add`, it can be called while the scheduler is running.
+	// It returns an error instead of panicking if the spec is invalid
+	Schedule(spec JobSpec) error
+
+	// ScheduleAll adds the jobs described by `specs` like `Schedule`, but registers them with the store at once if it implements `BulkAdder`,
+	// ie thousands of jobs at startup. If a spec is invalid, none of the jobs are added
+	ScheduleAll(specs []JobSpec) error
+}
+
+// Crontabber is implemented by the schedulers that can import and export crontabs, ie to migrate legacy cron entries
+type Crontabber interface {
+	// LoadCrontab adds a job for every line of the crontab read from `r`, ie to migrate legacy cron entries.
+	// Each job is named after the command of its line, or after its command and line number when several lines run the same command,
+	// and executes the func that the command maps to in `registry`. Lines that do not have an equivalent schedule, ie ranges of hours,
+	// return an error, and none of the jobs are added if a line is invalid
+	LoadCrontab(r io.Reader, registry map[string]TaskFunc) error
+
+	// ExportCrontab writes an equivalent crontab line for every job to `w`, ie for audits or as a fallback during a migration.
+	// Each line runs a command named after its job. The jobs that a crontab cannot express are written as comments
+	ExportCrontab(w io.Writer) error
+}
+
+// TenantManager is implemented by the schedulers that can manage the jobs of a tenant at once, see `Job.Tenant`
+type TenantManager interface {
+	// ListTenant returns the jobs that belong to `tenant`
+	ListTenant(tenant string) []Job
+
+	// PauseTenant pauses or resumes every job that belongs to `tenant`.
+	// Paused jobs are skipped by every instance if the store implements `Editor`, otherwise only by this one
+	PauseTenant(tenant string, paused bool) error
+
+	// RemoveTenant removes every job that belongs to `tenant` from the scheduler and the store
+	RemoveTenant(tenant string) error
+}
+
+// Toggler is implemented by the schedulers that can disable a job without removing it
+type Toggler interface {
+	// Disable stops the job named `name` from being executed without removing it, until `Enable` is called.
+	// Disabled jobs are skipped by every instance if the store implements `Disabler`, otherwise only by this one.
+	// The `Disabled` column of the stored record can also be set by hand
+	Disable(name string) error
+
+	// Enable executes the job named `name` again after it was disabled
+	Enable(name string) error
+}
+
+// Drainer is implemented by the schedulers that can be stopped gracefully, ie during a rolling deploy
+type Drainer interface {
+	// Drain takes the scheduler out of rotation without stopping it, ie during a rolling deploy. It stops claiming new executions,
+	// so that the other instances pick up the schedule, while the jobs that are running finish. `State` reports `Draining` until it is stopped
+	Drain()
+
+	// StopContext stops the scheduler, waiting for the jobs that are running to finish until `ctx` is done.
+	// If `ctx` is done first, it cancels the context of the jobs, see `Job.Context`, and returns an error that names the interrupted job.
+	// Its func finishes in the background, so it should return early once its context is done
+	StopContext(ctx context.Context) error
+}
+
+// Monitor is implemented by the schedulers that report their state, ie for health checks and dashboards
+type Monitor interface {
+	// IsRunning reports whether the scheduler was started and has not been stopped
+	IsRunning() bool
 
+	// State returns a snapshot of the state of the scheduler, ie for health checks
+	State() State
+
+	// Healthy returns an error if the scheduler is not running, its ticker is stalled, its database cannot be reached before `ctx` is done
+	// or a job is overdue by more than `Config.OverdueThreshold`, ie for a /healthz endpoint
+	Healthy(ctx context.Context) error
+
+	// Stats returns the totals of every execution since the scheduler was created
+	Stats() Stats
+
+	// Events returns the channel that every `Event` of the scheduler is sent to. Every call returns the same channel.
+	// Events are dropped instead of blocking the scheduler when the channel is full, so it should be consumed continuously
+	Events() <-chan Event
+}
+
+// Replayer is implemented by the schedulers that can execute the past runs of a job again, ie to backfill after a bug fix
+type Replayer interface {
+	// Replay re-executes the job named `name` as if it was scheduled at `scheduledTime`, ie to backfill after a bug fix.
+	// Replays are not synchronized with the database and `Job.IsReplay` reports true for the `Job` passed to the func.
+	// It returns an error if the func panics
+	Replay(name string, scheduledTime time.Time) error
+
+	// Backfill executes every run the job named `name` would have had between `from` and `to` according to its definition,
+	// with the concurrency, rate and progress reporting of `opts`. Like `Replay`, backfilled runs are not synchronized with the database.
+	// It returns the errors of the runs whose func panicked once every run finished
+	Backfill(name string, from, to time.Time, opts BackfillOptions) error
+}
+
+// Historian is implemented by the schedulers that can return the audit log of a job
+type Historian interface {
+	// History returns the audit log of the job named `name`, which records when its schedule was added, modified, paused or removed,
+	// by whom and its spec before and after the change. Note: the store must implement `Auditor`
+	History(name string) ([]AuditEntry, error)
+}
+
+// registrar is the internal registration hook implemented by the schedulers in this package.
+// It is kept out of `Scheduler` so that the interface can be implemented and decorated outside of this package
+type registrar interface {
 	// add is used by the job to add itsself to the scheduler after it is done being built (ie `Do` is called).
 	// It will optionally also be added to the database depending on how the scheduler is configured
 	add(j *job) error
 
+	// addAll adds several jobs at once, so that they are registered with the store in bulk
+	addAll(jobs []*job) error
+
 	// update checks the `NextRunAt` field in a synchronous way in the database to determine if
 	// if it returns an error, the job should not be executed
 	update(j *job) error
+
+	// emit sends `e` to the channel returned by `Monitor.Events`
+	emit(e Event)
+
+	// owns reports whether this instance executes `j` when the jobs are sharded
+	owns(j *job) bool
+
+	// denied returns why the `Gatekeeper` denied the execution of `j` at `t` after recording the denial,
+	// or an empty string if the execution is allowed
+	denied(j *job, t time.Time) string
+
+	// finish is called after each execution with the time between when the job was due and when it started,
+	// the time it took and the error it failed with if any
+	finish(j *job, latency, duration time.Duration, err error)
+
+	// tracef logs why `j` did or did not run when `Config.Trace` is set
+	tracef(j *job, format string, args ...interface{})
 }
 
 // Config configures the scheduler
@@ -48,6 +196,17 @@ type Config struct {
 	// Name is the name of the scheduler
 	Name string
 
+	// TableName is the name of the table that the jobs are stored in, and the prefix of the other tables of the scheduler.
+	// It defaults to the name of the scheduler, so it should be set when the name is common, ie "default", or is not a valid identifier
+	TableName string
+
+	// TablePrefix is prepended to the table name, ie "scheduler_jobs_", so that the tables follow the naming conventions of the application
+	TablePrefix string
+
+	// Schema qualifies the tables of the scheduler, ie the mysql database or the postgres schema that they are in.
+	// It defaults to the database that is connected to
+	Schema string
+
 	// Database is the name of the mysql database used to synchronize the scheduler
 	// If a database is not passed in, the scheduler will not use database synchronicity
 	Database string
@@ -61,37 +220,348 @@ type Config struct {
 	// Password is the password of the mysql user
 	Password string
 
+	// DSN is the mysql data source name used to synchronize the scheduler. It takes precedence over the
+	// `Database`, `Instance`, `Username`, `Password` and `Params` fields
+	DSN string
+
+	// Params are the parameters of the mysql data source name, ie "tls", "timeout" or "loc".
+	// They are added to the defaults of charset=utf8, parseTime=True and loc=UTC. Times are always stored in UTC,
+	// the timezone that a job is evaluated in is set by the `Task.Timezone` builder method
+	Params map[string]string
+
+	// AutoMigrate creates or migrates the table of the scheduler when the mysql database is connected to.
+	// Otherwise the table must be created with `Migrate`, ie where the rights to change the schema are locked down
+	AutoMigrate bool
+
+	// ConnectRetry is how the scheduler retries to connect to the mysql database, which it connects to when it is started instead of in `New`,
+	// so that a service can boot while the database is briefly unavailable. No execution is claimed until it is connected.
+	// Zero `Attempts` retry until the scheduler is stopped, and the backoff defaults to a second and is capped at a minute
+	ConnectRetry RetryPolicy
+
+	// Actor is who or what the changes made through the scheduler are attributed to in the audit log, ie the name of the service
+	// or of the operator of an admin tool. It defaults to the instance, see `Config.InstanceID` and `Historian.History`
+	Actor string
+
+	// ErrorHandler receives the errors that cannot be returned, ie failing to connect to the database. They are logged if it is not set
+	ErrorHandler func(error)
+
+	// MaxOpenConns is the maximum number of open connections to the mysql database. Zero means the driver default
+	MaxOpenConns int
+
+	// MaxIdleConns is the maximum number of idle connections to the mysql database. Zero means the driver default
+	MaxIdleConns int
+
+	// ConnMaxLifetime is the maximum amount of time a connection to the mysql database may be reused. Zero means the driver default
+	ConnMaxLifetime time.Duration
+
 	// LogDB when set to true, all sql transactions will be logged
 	LogDB bool
+
+	// DriftPolicy decides what happens when the stored definition of a job was changed outside of the scheduler
+	DriftPolicy DriftPolicy
+
+	// ClaimStrategy is how the mysql database makes sure that only one instance claims each execution, ie `AdvisoryLocking`
+	// behind a proxy that routes the statements. It defaults to `PessimisticLocking`
+	ClaimStrategy ClaimStrategy
+
+	// LockTimeout is how long a claim waits for the lock of a job that another instance holds before it gives up the execution,
+	// so that the instances that pile onto a busy job do not stall their ticker. Zero waits for as long as the database does with `PessimisticLocking`,
+	// which is 50 seconds for InnoDB, and for 10 seconds with `AdvisoryLocking`, since an advisory lock could otherwise be waited for forever
+	LockTimeout time.Duration
+
+	// SkipLocked makes a claim give up the execution right away when another instance holds the lock of the job,
+	// with `SKIP LOCKED`, which requires mysql 8
+	SkipLocked bool
+
+	// Granularity is the precision that the times of the jobs are evaluated and stored with, ie `time.Minute`.
+	// Coarser granularities tolerate stores that truncate timestamps. The intervals of the jobs should be multiples of it.
+	// It defaults to `time.Second`. A finer granularity makes the scheduler tick at it, which `Interval.Milliseconds` requires
+	Granularity time.Duration
+
+	// ClockSkew is how far apart the clocks of the instances may be. A claim whose times are within it of the stored times
+	// is treated as the execution that another instance already claimed, so that instances with slightly different clocks
+	// do not execute it twice. The intervals of the jobs must be longer than it
+	ClockSkew time.Duration
+
+	// DatabaseClock makes the scheduler take the time from the clock of its database instead of the clock of its host,
+	// so that the instances that share a database agree on when the jobs are due even if their clocks drift.
+	// Note: the store must implement `Clock`
+	DatabaseClock bool
+
+	// Trace logs why every job did or did not run at every tick and claim, ie that it is not due yet, that another instance
+	// claimed the execution or that it is paused, to diagnose the jobs that did not fire. It is verbose, so it should only be set while debugging
+	Trace bool
+
+	// Gatekeeper is consulted before every execution of every job, ie to enforce maintenance freezes
+	Gatekeeper Gatekeeper
+
+	// Discovery is how often the scheduler loads the jobs that other instances added to the store, ie `time.Minute`,
+	// so that a fleet converges on the same jobs without every binary declaring all of them. Zero disables discovery.
+	// Note: the store must implement `Lister`
+	Discovery time.Duration
+
+	// Tasks binds the jobs found by discovery to their funcs by job name. It takes precedence over the tasks passed to `Register`.
+	// Jobs without a task are not discovered
+	Tasks map[string]func(Job, time.Time)
+
+	// OverdueThreshold is how late a due job can be before `Monitor.Healthy` reports it. It defaults to a minute
+	OverdueThreshold time.Duration
+
+	// Observer makes the scheduler load every job of the store and refresh their state every `Discovery` interval, or every 10 seconds,
+	// without ever claiming an execution, ie for dashboards and admin tooling. The jobs added to an observer are not saved to the store,
+	// but the jobs can still be paused and removed. Note: the store must implement `Lister`
+	Observer bool
+
+	// RemoveCompleted removes every job from the scheduler and the store once it completed, ie a `Once` job after it ran,
+	// so that the store does not accumulate the rows of jobs that will never run again
+	RemoveCompleted bool
+
+	// Workers is the maximum number of jobs that execute at the same time. It defaults to `DefaultWorkers`.
+	// Due jobs are executed by a pool of workers, so a slow job does not delay the other jobs.
+	// A single worker executes the jobs one at a time in the order they were added
+	Workers int
+
+	// Sharding makes each instance execute only the jobs it owns instead of every instance racing to claim every execution.
+	// The jobs are spread over the instances that are alive with consistent hashing, so few jobs move when an instance joins or leaves.
+	// Note: the store must implement `Membership`, which the mysql store does, or `New` returns an error
+	Sharding bool
+
+	// InstanceID is the unique name of this instance of the scheduler, which `Sharding` and `Task.PinTo` use.
+	// It defaults to the hostname and the process id
+	InstanceID string
+
+	// Tags are the other names of this instance that `Task.PinTo` matches, ie "gpu"
+	Tags []string
+
+	// QueueSize is the maximum number of due jobs that wait for a worker. It defaults to `DefaultQueueSize`
+	QueueSize int
+
+	// QueuePolicy decides what happens to a due job when the queue is full
+	QueuePolicy QueuePolicy
+
+	// StateFile is the path of a file that the state of the jobs is saved to when no database or store is configured,
+	// so that the jobs of a single instance keep track of their runs across restarts
+	StateFile string
+
+	// Store synchronizes the scheduler instead of the mysql database, ie a `NoopStore` or a `RecordingStore`.
+	// If neither a store or a database are passed in, the scheduler will use a `NoopStore`
+	Store Store
+}
+
+// BackfillOptions configures `Replayer.Backfill`
+type BackfillOptions struct {
+	// Concurrency is the maximum number of runs that execute at the same time. It defaults to 1
+	Concurrency int
+
+	// Interval is the minimum amount of time between the starts of two runs, ie to spare the systems that the job calls.
+	// Zero starts the runs as soon as a slot of the concurrency is free
+	Interval time.Duration
+
+	// Progress is called after every run with the time it was scheduled at, the number of runs that finished out of `total`
+	// and the error of the run if its func panicked. It is not called concurrently
+	Progress func(scheduled time.Time, done, total int, err error)
+}
+
+// Status is the lifecycle state of a `Scheduler`
+type Status int
+
+const (
+	// Stopped is the state of a scheduler before `Start` is called and after it is stopped
+	Stopped Status = iota
+
+	// Running is the state of a scheduler after `Start` is called
+	Running
+
+	// Draining is the state of a scheduler that is stopping while a job is still executing, or that was drained with `Drainer.Drain`
+	Draining
+)
+
+// String returns the name of the status
+func (st Status) String() string {
+	switch st {
+	case Running:
+		return "running"
+	case Draining:
+		return "draining"
+	}
+	return "stopped"
+}
+
+// State is a snapshot of the state of a `Scheduler`
+type State struct {
+	// Status is the lifecycle state of the scheduler
+	Status Status
+
+	// Pending is the number of jobs that will run again, as of the last tick of the scheduler
+	Pending int
+
+	// Executing is the number of jobs that are executing
+	Executing int
+
+	// Degraded is true while the connection to the database is dropped, see `StoreDegraded`
+	Degraded bool
+}
+
+// Stats are the totals of the executions of a `Scheduler`
+type Stats struct {
+	// Executions is the number of executions performed by this instance
+	Executions int
+
+	// Failures is the number of executions that panicked
+	Failures int
+
+	// Skipped is the number of executions that were skipped because another instance already performed them
+	Skipped int
+
+	// Dropped is the number of executions that were dropped because the queue of the workers was full
+	Dropped int
+
+	// AverageLatency is the average amount of time between when a job was due and when it started
+	AverageLatency time.Duration
+}
+
+// Gatekeeper centralizes the execution policy of a scheduler, ie maintenance freezes or change blackout calendars
+type Gatekeeper interface {
+	// Allow decides if `j` may run for the execution scheduled at `t`. When it denies an execution, reason explains why.
+	// Denied executions are skipped without counting toward `Task.Times` and are recorded in the audit log, see `AuditDenied`
+	Allow(j Job, t time.Time) (ok bool, reason string)
 }
 
-// New creates a new `Scheduler`
-func New(cfg *Config) Scheduler {
+// DriftPolicy decides what happens when the stored definition of a job was changed outside of the scheduler, ie by editing the table by hand
+type DriftPolicy int
+
+// QueuePolicy decides what happens to a due job when more jobs are due than the workers can execute and the queue is full
+type QueuePolicy int
+
+const (
+	// BlockQueue makes the scheduler wait for a worker before it dispatches the next job
+	BlockQueue QueuePolicy = iota
+
+	// DropOldest drops the job that has waited the longest to make room in the queue
+	DropOldest
+
+	// RejectNew drops the job that is due
+	RejectNew
+)
+
+const (
+	// OverwriteDrift replaces the stored definition with the one in the code
+	OverwriteDrift DriftPolicy = iota
+
+	// AdoptDrift replaces the definition in the code with the stored one
+	AdoptDrift
+)
+
+// New creates a new `Scheduler`. The mysql database is only connected to and migrated when the scheduler is started,
+// see `Config.ConnectRetry`. It returns an error if the state file cannot be opened
+func New(cfg *Config) (Scheduler, error) {
 	// create the scheduler
 	var s scheduler
 	s.name = cfg.Name
+	if s.table = cfg.TableName; len(s.table) == 0 {
+		s.table = s.name
+	}
+	s.table = cfg.TablePrefix + s.table
+	if len(cfg.Schema) > 0 {
+		s.table = cfg.Schema + "." + s.table
+	}
+	s.drift = cfg.DriftPolicy
+	s.gatekeeper = cfg.Gatekeeper
+	s.trace = cfg.Trace
+	s.granularity = cfg.Granularity
+	if s.skew = cfg.ClockSkew; s.skew < 0 {
+		s.skew = 0
+	}
+	s.clock = cfg.DatabaseClock
+	s.events = make(chan Event, eventBuffer)
+	s.discovery = cfg.Discovery
+	s.tasks = cfg.Tasks
+	s.removeCompleted = cfg.RemoveCompleted
+	s.overdueThreshold = cfg.OverdueThreshold
+	s.connectRetry = cfg.ConnectRetry
+	s.errorHandler = cfg.ErrorHandler
+	s.running = map[string]int{}
+	if s.granularity <= 0 {
+		s.granularity = time.Second
+	}
+	if s.overdueThreshold <= 0 {
+		s.overdueThreshold = time.Minute
+	}
+	if s.workers = cfg.Workers; s.workers <= 0 {
+		s.workers = DefaultWorkers
+	}
+	if s.queueSize = cfg.QueueSize; s.queueSize <= 0 {
+		s.queueSize = DefaultQueueSize
+	}
+	s.queuePolicy = cfg.QueuePolicy
+	s.sharding = cfg.Sharding
+	s.tags = cfg.Tags
+	if s.observer = cfg.Observer; s.observer {
+		s.sharding = false
+		if s.discovery <= 0 {
+			s.discovery = observerDiscovery
+		}
+	}
+	if s.instance = cfg.InstanceID; len(s.instance) == 0 {
+		hostname, _ := os.Hostname()
+		s.instance = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if s.actor = cfg.Actor; len(s.actor) == 0 {
+		s.actor = s.instance
+	}
 
-	// open the database
-	if len(cfg.Database) > 0 {
-		db, err := gorm.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local", cfg.Username, cfg.Password, cfg.Instance, cfg.Database))
-		if err != nil {
-			panic(err)
+	// pick the store
+	if cfg.Store != nil {
+		s.store = cfg.Store
+	} else if len(cfg.Database) > 0 || len(cfg.DSN) > 0 {
+		if !gormBuilt {
+			_, err := newGormStore(s.table, cfg)
+			return nil, err
+		}
+		if err := validTableName(s.table); err != nil {
+			return nil, err
 		}
-		db.SingularTable(true)
-		db.LogMode(cfg.LogDB)
-		if err := db.AutoMigrate(&job{
-			scheduler: &s,
-		}).Error; err != nil {
-			panic(err)
+		c := *cfg
+		s.store = &lazyStore{open: func() (Store, error) {
+			gs, err := newGormStore(s.table, &c)
+			if err != nil {
+				return nil, err
+			}
+			return gs, nil
+		}}
+	} else if len(cfg.StateFile) > 0 {
+		fs, err := NewFileStore(cfg.StateFile)
+		if err != nil {
+			return nil, err
 		}
-		s.db = db
+		s.store = fs
+	} else {
+		s.store = NoopStore{}
+	}
+	if _, ok := s.store.(Membership); s.sharding && !ok {
+		return nil, fmt.Errorf("%s can not shard its jobs, its store does not implement Membership", s.name)
 	}
 
-	return &s
+	return &s, nil
+}
+
+// MustNew is like `New` but panics if the scheduler cannot be created
+func MustNew(cfg *Config) Scheduler {
+	s, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
-// DefaultScheduler is the `Scheduler`` referenced by the `Add` and `List` funcs
-var DefaultScheduler = New(&Config{Name: "default"})
+// DefaultWorkers is the maximum number of jobs that execute at the same time when `Config.Workers` is not set
+const DefaultWorkers = 10
+
+// DefaultQueueSize is the maximum number of due jobs that wait for a worker when `Config.QueueSize` is not set
+const DefaultQueueSize = 256
+
+// DefaultScheduler is the `Scheduler“ referenced by the `Add` and `List` funcs
+var DefaultScheduler = MustNew(&Config{Name: "default"})
 
 func init() {
 	DefaultScheduler.Start()
@@ -107,13 +577,79 @@ func List() []Job {
 	return DefaultScheduler.List()
 }
 
+// registry holds the tasks passed to `Register`
+var registry = struct {
+	sync.Mutex
+	tasks map[string]func(Job, time.Time)
+}{tasks: map[string]func(Job, time.Time){}}
+
+// Register binds `do` to the jobs named `name` in every scheduler, so that a job stored in the database
+// can be re-bound to its func by name after a restart or when it is discovered from another instance
+func Register(name string, do func(Job, time.Time)) {
+	if do == nil {
+		panic("Register expects a func")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.tasks[name]; ok {
+		panic(name + " is already registered")
+	}
+	registry.tasks[name] = do
+}
+
+// registered returns the func passed to `Register` for the jobs named `name`
+func registered(name string) (func(Job, time.Time), bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	do, ok := registry.tasks[name]
+	return do, ok
+}
+
 // scheduler implments `Scheduler`
 type scheduler struct {
-	name string
-	jobs []Job
-	db   *gorm.DB
-	quit chan struct{}
-	done chan struct{}
+	name             string
+	table            string
+	mu               sync.Mutex
+	jobs             []Job
+	store            Store
+	drift            DriftPolicy
+	gatekeeper       Gatekeeper
+	trace            bool
+	granularity      time.Duration
+	skew             time.Duration
+	clock            bool
+	offset           int64
+	status           Status
+	draining         bool
+	degraded         bool
+	running          map[string]int
+	sharding         bool
+	instance         string
+	tags             []string
+	members          []string
+	workers          int
+	queueSize        int
+	queuePolicy      QueuePolicy
+	pending          int
+	stats            Stats
+	events           chan Event
+	latency          time.Duration
+	discovery        time.Duration
+	removeCompleted  bool
+	observer         bool
+	actor            string
+	lastTick         time.Time
+	overdueThreshold time.Duration
+	overdue          string
+	late             time.Duration
+	connectRetry     RetryPolicy
+	errorHandler     func(error)
+	tasks            map[string]func(Job, time.Time)
+	triggers         chan trigger
+	quit             chan struct{}
+	done             chan struct{}
+	ctx              context.Context
+	cancel           context.CancelFunc
 }
 
 // Name is the unique name of the scheduler. Note: any scheduler with the same name will reference the same table name for synchronicity purposes
@@ -123,7 +659,9 @@ func (s *scheduler) Name() string {
 
 // List returs a list of jobs added to this scheduler
 func (s *scheduler) List() []Job {
-	return s.jobs
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.jobs...)
 }
 
 // Add create a new job ascociated with the scheduler and returns its first builder method
@@ -132,139 +670,904 @@ func (s *scheduler) Add(name string) Amount {
 	var j job
 	j.JobName = name
 	j.scheduler = s
+	j.registrar = s
+	j.granularity = s.granularity
+	j.skew = s.skew
+	j.clock = s.now
 	return &j
 }
 
+// AddOrReplace create a new job that replaces the job named `name` if it was already added
+func (s *scheduler) AddOrReplace(name string) Amount {
+	j := s.Add(name).(*job)
+	j.replace = true
+	return j
+}
+
+// Schedule adds the job described by `spec` to the scheduler and the database
+func (s *scheduler) Schedule(spec JobSpec) error {
+	return schedule(s, spec)
+}
+
+// ScheduleAll adds the jobs described by `specs` to the scheduler and registers them with the store at once
+func (s *scheduler) ScheduleAll(specs []JobSpec) error {
+	return scheduleAll(s, specs)
+}
+
+// LoadCrontab adds a job for every line of the crontab read from `r`
+func (s *scheduler) LoadCrontab(r io.Reader, registry map[string]TaskFunc) error {
+	return loadCrontab(s, r, registry)
+}
+
+// ExportCrontab writes an equivalent crontab line for every job to `w`
+func (s *scheduler) ExportCrontab(w io.Writer) error {
+	return exportCrontab(s, w)
+}
+
+// Remove removes the job named `name` from the scheduler and the store
+func (s *scheduler) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, j := range s.jobs {
+		if j.Name() != name {
+			continue
+		} else if e, ok := s.store.(Editor); ok {
+			if err := e.Remove(s.table, name); err != nil {
+				return err
+			}
+		}
+		s.forget(j.(*job))
+		s.jobs = append(s.jobs[:i:i], s.jobs[i+1:]...)
+		s.audit(name, AuditRemoved, j.(*job).specJSON(), "")
+		return nil
+	}
+	return fmt.Errorf("%s has not been added to the scheduler", name)
+}
+
+// ListTenant returns the jobs that belong to `tenant`
+func (s *scheduler) ListTenant(tenant string) []Job {
+	var jobs []Job
+	for _, j := range s.List() {
+		if j.Tenant() == tenant {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
+// PauseTenant pauses or resumes every job that belongs to `tenant`
+func (s *scheduler) PauseTenant(tenant string, paused bool) error {
+	var p int32
+	if paused {
+		p = 1
+	}
+	action := AuditResumed
+	if paused {
+		action = AuditPaused
+	}
+	for _, a := range s.ListTenant(tenant) {
+		j := a.(*job)
+		changed := atomic.SwapInt32(&j.paused, p) != p
+		if e, ok := s.store.(Editor); ok {
+			if err := e.Pause(s.table, j.Name(), paused); err != nil {
+				return err
+			}
+		}
+		if changed {
+			spec := j.specJSON()
+			s.audit(j.JobName, action, spec, spec)
+		}
+	}
+	return nil
+}
+
+// Disable stops the job named `name` from being executed on every instance
+func (s *scheduler) Disable(name string) error {
+	return s.disable(name, true)
+}
+
+// Enable executes the job named `name` again
+func (s *scheduler) Enable(name string) error {
+	return s.disable(name, false)
+}
+
+// disable disables or enables the job named `name` in the store, or only on this instance if the store does not implement `Disabler`,
+// since a flag of this instance could not be cleared by the others
+func (s *scheduler) disable(name string, disabled bool) error {
+	var j *job
+	for _, a := range s.List() {
+		if a.Name() == name {
+			j = a.(*job)
+		}
+	}
+	if j == nil {
+		return fmt.Errorf("%s has not been added to the scheduler", name)
+	}
+	if d, ok := s.store.(Disabler); ok {
+		if err := d.Disable(s.table, name, disabled); err != nil {
+			return err
+		}
+	} else if disabled {
+		atomic.StoreInt32(&j.disabled, 1)
+	} else {
+		atomic.StoreInt32(&j.disabled, 0)
+	}
+	action := AuditEnabled
+	if disabled {
+		action = AuditDisabled
+	}
+	spec := j.specJSON()
+	s.audit(name, action, spec, spec)
+	return nil
+}
+
+// RemoveTenant removes every job that belongs to `tenant` from the scheduler and the store
+func (s *scheduler) RemoveTenant(tenant string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []Job
+	var err error
+	for _, j := range s.jobs {
+		if j.Tenant() != tenant {
+			jobs = append(jobs, j)
+			continue
+		}
+
+		// keep the jobs that could not be removed from the store
+		if e, ok := s.store.(Editor); ok {
+			if rErr := e.Remove(s.table, j.Name()); rErr != nil {
+				jobs = append(jobs, j)
+				err = rErr
+				continue
+			}
+		}
+		s.forget(j.(*job))
+		s.audit(j.Name(), AuditRemoved, j.(*job).specJSON(), "")
+	}
+	s.jobs = jobs
+	return err
+}
+
 // Start starts the scheduler
 func (s *scheduler) Start() {
 	// stop the ticker
-	if s.quit != nil {
-		s.Stop()
+	s.Stop()
+
+	// measure the clock of the database before the stored jobs are bound
+	if s.clock && s.connected() {
+		s.syncClock()
+	}
+
+	// bind the stored jobs that have a task after a restart, which a lazily connected database does once it is connected
+	if s.binds() && s.connected() {
+		s.discover()
+	}
+
+	// join the instances that share the jobs
+	if s.sharding {
+		s.heartbeat(s.now().Add(heartbeatTTL))
 	}
 
 	// start the ticker
+	s.mu.Lock()
 	s.quit = make(chan struct{})
 	s.done = make(chan struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.draining = false
+	s.lastTick = time.Now()
+	s.overdue, s.late = "", 0
+	s.triggers = make(chan trigger)
+	for _, j := range s.jobs {
+		s.listen(j.(*job))
+	}
+	quit, done, triggers := s.quit, s.done, s.triggers
+	s.mu.Unlock()
+	if ls, ok := s.store.(*lazyStore); ok && ls.connected() == nil {
+		go s.connect(ls, quit)
+	}
 	started := make(chan struct{})
-	go func(s *scheduler, started chan struct{}) {
-		ticker := time.NewTicker(time.Second)
+	go func(s *scheduler, started, quit, done chan struct{}, triggers chan trigger) {
+		// start the workers, which finish the jobs that were dispatched before the scheduler stopped
+		work := make(chan execution, s.queueSize)
+		var workers sync.WaitGroup
+		for i := 0; i < s.workers; i++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for e := range work {
+					s.execute(e)
+				}
+			}()
+		}
+		ticker := time.NewTicker(s.tick())
 		close(started)
+		discovered := time.Now()
+
+		// refresh the instances and the clock on the first tick, so that the instances that started together know each other
+		var heartbeat, synced time.Time
 		for {
 			select {
 			case t := <-ticker.C:
-				for _, j := range s.jobs {
-					j.execute(t)
+				s.mu.Lock()
+				s.lastTick = t
+				s.mu.Unlock()
+				if s.isDraining() || !s.connected() {
+					break
+				}
+				if s.clock && t.Sub(synced) >= clockSync {
+					s.syncClock()
+					synced = t
+				}
+				now := s.at(t)
+				if s.discovery > 0 && t.Sub(discovered) >= s.discovery {
+					s.discover()
+					discovered = t
+				}
+				if s.sharding && t.Sub(heartbeat) >= heartbeatInterval {
+					s.heartbeat(now.Add(heartbeatTTL))
+					heartbeat = t
+				}
+				if s.observer {
+					break
+				}
+
+				// dispatch the due jobs, skipping the jobs that are still executing, count the jobs that will run again
+				// and find the latest of the executions that have not started
+				var pending int
+				var expired []*job
+				var overdue string
+				var late time.Duration
+				for _, a := range s.List() {
+					j := a.(*job)
+					if !j.executing.TryLock() {
+						s.tracef(j, "did not run, its last execution has not finished")
+						pending++
+						if queued := atomic.LoadInt64(&j.queued); queued != 0 && now.Sub(time.Unix(0, queued)) > late {
+							overdue, late = j.JobName, now.Sub(time.Unix(0, queued))
+						}
+						continue
+					} else if j.expired(now) || (s.removeCompleted && j.completed()) {
+						j.executing.Unlock()
+						expired = append(expired, j)
+						continue
+					} else if !j.completed() {
+						pending++
+					}
+					if d := j.overdue(now); d > late {
+						overdue, late = j.JobName, d
+					}
+					if !j.due(now) && !j.redelivers(now) {
+						if j.completed() {
+							s.tracef(j, "did not run, it completed")
+						} else {
+							s.tracef(j, "did not run, it is not due until %s", j.NextRunAt)
+						}
+						j.executing.Unlock()
+						continue
+					}
+					atomic.StoreInt64(&j.queued, j.NextRunAt.UnixNano())
+					s.enqueue(work, execution{job: j, time: now})
+				}
+				s.mu.Lock()
+				s.pending = pending
+				s.overdue, s.late = overdue, late
+				s.mu.Unlock()
+				for _, j := range expired {
+					s.expire(j, now)
 				}
 				break
-			case <-s.quit:
+			case t := <-triggers:
+				if s.isDraining() || !s.connected() || s.observer {
+					break
+				} else if j, err := s.job(t.job.Name()); err != nil || j != t.job {
+					break
+				}
+
+				s.enqueue(work, execution{job: t.job, time: s.at(t.time), triggered: true})
+			case <-quit:
+				// finish the jobs of the current tick
 				ticker.Stop()
-				close(s.done)
+				close(work)
+				workers.Wait()
+				close(done)
 				return
 			}
 		}
-	}(s, started)
+	}(s, started, quit, done, triggers)
 	<-started
+	s.setStatus(Running)
+}
+
+// expire removes `j` because it was inactive for longer than its `Task.ExpireAfter` duration, or because it completed
+// and `Config.RemoveCompleted` is set
+func (s *scheduler) expire(j *job, now time.Time) {
+	if err := s.Remove(j.Name()); err != nil {
+		log.Printf("schedule: %s failed to expire: %s", j.JobName, err)
+		return
+	} else if s.removeCompleted && j.completed() {
+		log.Printf("schedule: %s completed and was removed", j.JobName)
+	} else {
+		log.Printf("schedule: %s expired", j.JobName)
+	}
+	s.emit(Event{Type: JobExpired, Job: j, Time: now})
+}
+
+// binds reports whether the scheduler binds the stored jobs that have a task when it is started
+func (s *scheduler) binds() bool {
+	registry.Lock()
+	defer registry.Unlock()
+	return s.discovery > 0 || len(s.tasks) > 0 || len(registry.tasks) > 0
+}
+
+// fail passes an error that cannot be returned to the `Config.ErrorHandler`, or logs it
+func (s *scheduler) fail(err error) {
+	if s.errorHandler != nil {
+		s.errorHandler(err)
+		return
+	}
+	log.Printf("schedule: %s", err)
+}
+
+// tick is how often the scheduler checks for due jobs, which is every second unless the granularity is finer
+func (s *scheduler) tick() time.Duration {
+	if s.granularity < time.Second {
+		return s.granularity
+	}
+	return time.Second
+}
+
+// execution is a due job that waits for a worker. The `executing` lock of a job that is due on a tick is held while it waits,
+// while a triggered execution waits for the execution of the job that is in progress once a worker picks it up
+type execution struct {
+	job       *job
+	time      time.Time
+	triggered bool
+}
+
+// enqueue adds `e` to the `work` queue according to the `QueuePolicy` of the scheduler
+func (s *scheduler) enqueue(work chan execution, e execution) {
+	switch s.queuePolicy {
+	case DropOldest:
+		for {
+			select {
+			case work <- e:
+				return
+			default:
+			}
+			select {
+			case old := <-work:
+				s.drop(old)
+			default:
+			}
+		}
+	case RejectNew:
+		select {
+		case work <- e:
+		default:
+			s.drop(e)
+		}
+	default:
+		work <- e
+	}
 }
 
-// Stop stops the scheduler
+// drop discards `e` because the queue is full. A job that was due on a tick is dispatched again on the next tick
+func (s *scheduler) drop(e execution) {
+	if !e.triggered {
+		atomic.StoreInt64(&e.job.queued, 0)
+		e.job.executing.Unlock()
+	}
+	log.Printf("schedule: %s was dropped because the queue is full", e.job.JobName)
+	s.mu.Lock()
+	s.stats.Dropped++
+	s.mu.Unlock()
+	s.emit(Event{Type: JobDropped, Job: e.job, Time: time.Now(), ScheduledAt: e.time})
+}
+
+// execute executes `e`, keeping track of the job while it is executing
+func (s *scheduler) execute(e execution) {
+	j := e.job
+	if e.triggered {
+		j.executing.Lock()
+	} else {
+		atomic.StoreInt64(&j.queued, 0)
+	}
+	defer j.executing.Unlock()
+	s.mu.Lock()
+	if s.draining {
+		// leave the executions that were queued before the scheduler was drained to the other instances
+		s.mu.Unlock()
+		return
+	}
+	s.running[j.Name()]++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.running[j.Name()]--; s.running[j.Name()] == 0 {
+			delete(s.running, j.Name())
+		}
+		s.mu.Unlock()
+	}()
+	if e.triggered {
+		j.trigger(e.time)
+	} else {
+		j.execute(e.time)
+	}
+}
+
+// Stop stops the scheduler. It waits for the jobs that are running to finish
 func (s *scheduler) Stop() {
-	if s.quit == nil {
+	s.StopContext(context.Background())
+}
+
+// Drain stops claiming new executions while the jobs that are running finish
+func (s *scheduler) Drain() {
+	s.mu.Lock()
+	if s.quit == nil || s.draining {
+		s.mu.Unlock()
 		return
 	}
-	close(s.quit)
-	<-s.done
+	s.draining = true
+	s.status = Draining
+	s.pending = 0
+	s.mu.Unlock()
+
+	// leave the instances that share the jobs right away, so that they take over the jobs of this one
+	if s.sharding {
+		s.heartbeat(s.now())
+	}
+}
+
+// isDraining reports whether the scheduler was drained with `Drain`
+func (s *scheduler) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+// StopContext stops the scheduler, waiting for the jobs that are running to finish until `ctx` is done
+func (s *scheduler) StopContext(ctx context.Context) error {
+	s.mu.Lock()
+	quit, done, draining, cancel := s.quit, s.done, s.draining, s.cancel
 	s.quit = nil
 	s.done = nil
+	s.mu.Unlock()
+	if quit == nil {
+		return nil
+	}
+	defer cancel()
+	close(quit)
+	s.setStatus(Draining)
+
+	// leave the instances that share the jobs once the jobs of the current tick are finished
+	if s.sharding && !draining {
+		defer s.heartbeat(s.now())
+	}
+	select {
+	case <-done:
+		s.mu.Lock()
+		s.status = Stopped
+		s.draining = false
+		s.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		// the scheduler is stopped once the interrupted job finishes
+		go func() {
+			<-done
+			s.mu.Lock()
+			if s.status == Draining {
+				s.status = Stopped
+				s.draining = false
+			}
+			s.mu.Unlock()
+		}()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var running []string
+		for name := range s.running {
+			running = append(running, name)
+		}
+		sort.Strings(running)
+		return fmt.Errorf("%s was interrupted while running %s: %v", s.name, strings.Join(running, ", "), ctx.Err())
+	}
+}
+
+// setStatus sets the lifecycle state of the scheduler
+func (s *scheduler) setStatus(status Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// IsRunning reports whether the scheduler was started and has not been stopped
+func (s *scheduler) IsRunning() bool {
+	return s.State().Status == Running
+}
+
+// State returns a snapshot of the state of the scheduler
+func (s *scheduler) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := State{
+		Status:   s.status,
+		Pending:  s.pending,
+		Degraded: s.degraded,
+	}
+	for _, n := range s.running {
+		st.Executing += n
+	}
+	return st
+}
+
+// Replay re-executes the job named `name` as if it was scheduled at `scheduledTime`, ie to backfill after a bug fix.
+// Replays are not synchronized with the database and `Job.IsReplay` reports true for the `Job` passed to the func
+func (s *scheduler) Replay(name string, scheduledTime time.Time) error {
+	j, err := s.job(name)
+	if err != nil {
+		return err
+	} else if s.observer {
+		return fmt.Errorf("%s is an observer, it does not execute jobs", s.name)
+	}
+	return try(j.do, replay{j, scheduledTime}, scheduledTime)
+}
+
+// Backfill executes every run the job named `name` would have had between `from` and `to` according to its definition
+func (s *scheduler) Backfill(name string, from, to time.Time, opts BackfillOptions) error {
+	j, err := s.job(name)
+	if err != nil {
+		return err
+	} else if s.observer {
+		return fmt.Errorf("%s is an observer, it does not execute jobs", s.name)
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var limit <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	// execute the runs, reporting the progress as they finish
+	runs := j.occurrences(from, to)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var done int
+	var errs []error
+	sem := make(chan struct{}, concurrency)
+	for i, t := range runs {
+		if i > 0 && limit != nil {
+			<-limit
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(t time.Time) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := try(j.do, replay{j, t}, t)
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s failed to backfill its run at %s: %s", name, t, err))
+			}
+			if opts.Progress != nil {
+				opts.Progress(t, done, len(runs), err)
+			}
+		}(t)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
 // add is used by the job to add itsself to the scheduler after it is done being built (ie `Do` is called).
 // It will optionally also be added to the database depending on how the scheduler is configured
 func (s *scheduler) add(j *job) error {
-	for _, a := range s.jobs {
-		if a.Name() == j.Name() {
-			return fmt.Errorf("%s is already added to the scheduler", j.Name())
+	return s.addAll([]*job{j})
+}
+
+// addAll adds `jobs` to the scheduler and registers them with the store at once if it implements `BulkAdder`
+func (s *scheduler) addAll(jobs []*job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	indexes := make([]int, len(jobs))
+	added := map[string]bool{}
+	for i, j := range jobs {
+		indexes[i] = -1
+		if added[j.Name()] {
+			return fmt.Errorf("%s is added more than once", j.Name())
+		}
+		added[j.Name()] = true
+		for k, a := range s.jobs {
+			if a.Name() != j.Name() {
+				continue
+			} else if !j.replace {
+				return fmt.Errorf("%s is already added to the scheduler", j.Name())
+			}
+			indexes[i] = k
 		}
 	}
 
-	// don't forget to append the job to the list of jobs in the scheduler at the end of this
+	// don't forget to add the jobs to the list of jobs in the scheduler at the end of this
 	defer func() {
-		s.jobs = append(s.jobs, j)
+		for i, j := range jobs {
+			if indexes[i] < 0 {
+				s.jobs = append(s.jobs, j)
+				continue
+			}
+			s.forget(s.jobs[indexes[i]].(*job))
+			s.jobs[indexes[i]] = j
+		}
 	}()
+	checksums := make([]string, len(jobs))
+	records := make([]*Record, len(jobs))
+	for i, j := range jobs {
+		checksums[i] = j.checksum()
+		j.Checksum = checksums[i]
+		j.drift = s.drift
+		if _, ok := s.store.(Finisher); !ok && j.lease > 0 {
+			log.Printf("schedule: %s cannot be performed at least once, the store does not implement Finisher", j.JobName)
+			j.lease = 0
+		}
 
-	// no database logic needed
-	if s.db == nil {
-		return nil
+		// the replaced job keeps its state, and its new definition wins over a definition that drifted
+		if indexes[i] >= 0 {
+			old := s.jobs[indexes[i]].(*job)
+			j.RunCount = old.RunCount
+			j.LastRunAt = old.LastRunAt
+			j.Paused = atomic.LoadInt32(&old.paused) == 1
+			j.shareStats(&old.Record)
+			j.drift = OverwriteDrift
+		}
+		records[i] = &j.Record
 	}
-
-	// select the job from the database
-	tx := s.db.Begin()
-	var dbJ job
-	if err := tx.Raw(fmt.Sprintf("select * from `%s` where `job_name` = \"%s\" for update", s.name, j.JobName)).Scan(&dbJ).Error; err == gorm.ErrRecordNotFound {
-		// create a new job in the database
-		if err := tx.Create(j).Error; err != nil {
-			if err := tx.Rollback().Error; err != nil {
-				log.Println(err)
-				return nil
-			}
-			log.Println(err)
-			return nil
+	// an observer does not save anything to the store
+	if !s.observer {
+		if err := addAll(s.store, s.table, records); err != nil {
+			return err
 		}
+	}
+	for i, j := range jobs {
+		s.registered(j, checksums[i])
+		if !s.observer {
+			s.audited(j)
+		}
+		s.emit(Event{Type: JobScheduled, Job: j, Time: time.Now(), ScheduledAt: j.NextRunAt})
 
-	} else if err != nil {
-		// catasriphic server error
-		if err := tx.Rollback().Error; err != nil {
-			return err
+		// listen to the trigger source of a job that is added while the scheduler is running
+		if s.quit != nil {
+			s.listen(j)
 		}
-		return err
-	} else if err := tx.Save(j).Error; err != nil {
-		if err := tx.Rollback().Error; err != nil {
-			return err
+	}
+	return nil
+}
+
+// registered applies the state that the store merged into the record of `j` when it was added,
+// where `checksum` is the checksum of the definition of the job before it was added
+func (s *scheduler) registered(j *job, checksum string) {
+	j.stats.Store(j.Record.stats())
+
+	// the job stays paused after a restart
+	if j.Paused {
+		atomic.StoreInt32(&j.paused, 1)
+	}
+
+	// an execution that was not completed before a restart is performed again once its lease expires, instead of when it is due
+	if !j.PendingUntil.IsZero() {
+		j.redeliverAt = j.PendingUntil
+		if !j.NextRunAt.After(j.LastRunAt) {
+			j.caclulateNextRunAt(j.LastRunAt.Add(time.Nanosecond))
 		}
-		return err
 	}
-	// commit the change to the db
-	if err := tx.Commit().Error; err != nil {
-		if err := tx.Rollback().Error; err != nil {
-			return err
+
+	// recalculate the next run if the stored definition was adopted
+	if j.Checksum != checksum {
+		j.reconcile()
+	}
+}
+
+// reconcile recalculates the next run of a job after the definition of its record was replaced with a stored one
+func (j *job) reconcile() {
+	if loc, err := time.LoadLocation(j.Zone); err == nil && j.Zone != "" {
+		j.loc = loc
+	}
+	j.caclulateNextRunAt(j.now())
+}
+
+// discover adds the stored jobs that have a task, ie the jobs added by other instances
+func (s *scheduler) discover() {
+	lister, ok := s.store.(Lister)
+	if !ok {
+		if s.discovery > 0 {
+			log.Printf("schedule: %s cannot discover jobs, its store does not implement Lister", s.name)
 		}
+		return
+	}
+	records, err := lister.List(s.table)
+	if err != nil {
 		log.Println(err)
+		return
+	} else if s.observer {
+		s.observe(records)
+		return
 	}
-	return nil
+	for _, r := range records {
+		do, ok := s.tasks[r.JobName]
+		if !ok {
+			do, ok = registered(r.JobName)
+		}
+		if !ok {
+			continue
+		} else if _, err := s.job(r.JobName); err == nil {
+			continue
+		}
+		if err := s.add(s.stored(r, do)); err != nil {
+			log.Println(err)
+			continue
+		}
+		log.Printf("schedule: discovered %s", r.JobName)
+	}
+}
+
+// stored returns a job built from its stored record that executes `do`
+func (s *scheduler) stored(r Record, do func(Job, time.Time)) *job {
+	var j job
+	j.Record = r
+	j.scheduler = s
+	j.registrar = s
+	j.granularity = s.granularity
+	j.skew = s.skew
+	j.clock = s.now
+	j.do = do
+	if len(r.Zone) > 0 {
+		if loc, err := time.LoadLocation(r.Zone); err == nil {
+			j.loc = loc
+		}
+	}
+	return &j
+}
+
+// job returns the job named `name`
+func (s *scheduler) job(name string) (*job, error) {
+	for _, j := range s.List() {
+		if j.Name() == name {
+			return j.(*job), nil
+		}
+	}
+	return nil, fmt.Errorf("%s has not been added to the scheduler", name)
 }
 
 // update checks the `NextRunAt` field in a synchronous way in the database to determine if
 // if it returns an error, the job should not be executed
 func (s *scheduler) update(j *job) error {
-	if s.db == nil {
-		return nil
+	err := s.store.Claim(s.table, &j.Record)
+	for attempt := 1; attempt < claimAttempts && isConnectionError(err); attempt++ {
+		time.Sleep(claimBackoff << uint(attempt-1))
+		err = s.store.Claim(s.table, &j.Record)
 	}
-	var dbJ job
-	tx := s.db.Begin()
-	if err := tx.Raw(fmt.Sprintf("select * from `%s` where `job_name` = \"%s\" for update", s.name, j.JobName)).Scan(&dbJ).Error; err != nil {
-		if err := tx.Rollback().Error; err != nil {
-			return err
-		}
-		return err
+	s.degrade(j, isConnectionError(err), err)
+	switch err {
+	case nil:
+		s.tracef(j, "claimed its execution at %s", j.LastRunAt)
+	case ErrAlreadyExecuted:
+		s.tracef(j, "did not run at %s, another instance claimed the execution", j.LastRunAt)
+	case ErrPending:
+		s.tracef(j, "did not run at %s, another instance has not completed the execution", j.LastRunAt)
+	case ErrNotCompleted:
+		s.tracef(j, "performs an earlier execution that was not completed before its execution at %s", j.LastRunAt)
+	default:
+		s.tracef(j, "did not run at %s, it could not be claimed: %s", j.LastRunAt, err)
 	}
-	// check to see if another instance using the same database already performed this execution
-	if (dbJ.NextRunAt.After(j.NextRunAt) || dbJ.NextRunAt.Equal(j.NextRunAt)) && (dbJ.LastRunAt.After(j.LastRunAt) || dbJ.LastRunAt.Equal(j.LastRunAt)) {
-		if err := tx.Rollback().Error; err != nil {
-			return err
+	if err == ErrDefinitionChanged {
+		log.Printf("schedule: %s was added with a new definition by another instance, its schedule was recomputed", j.JobName)
+		j.reconcile()
+	} else if err == ErrAlreadyExecuted || err == ErrPending {
+		if err == ErrPending {
+			// perform the execution again if the other instance does not complete it
+			j.redeliverAt = j.PendingUntil
 		}
-		return fmt.Errorf("another instance already executed")
+		s.mu.Lock()
+		s.stats.Skipped++
+		s.mu.Unlock()
+		s.emit(Event{Type: JobClaimedElsewhere, Job: j, Time: time.Now(), ScheduledAt: j.LastRunAt})
 	}
-	// save our new run info
-	if err := tx.Save(j).Error; err != nil {
-		if err := tx.Rollback().Error; err != nil {
-			return err
+	return err
+}
+
+// denied returns why the `Gatekeeper` denied the execution of `j` at `t` after recording the denial,
+// or an empty string if the execution is allowed
+func (s *scheduler) denied(j *job, t time.Time) string {
+	if s.gatekeeper == nil {
+		return ""
+	}
+	ok, reason := s.gatekeeper.Allow(j, t)
+	if ok {
+		return ""
+	} else if len(reason) == 0 {
+		reason = "no reason was given"
+	}
+	log.Printf("schedule: %s was denied by the gatekeeper: %s", j.JobName, reason)
+	spec := j.specJSON()
+	s.appendAudit(AuditEntry{Time: time.Now(), JobName: j.JobName, Action: AuditDenied, Actor: s.actor, Before: spec, After: spec, Reason: reason})
+	s.emit(Event{Type: JobSkipped, Job: j, Time: time.Now(), ScheduledAt: t, Err: fmt.Errorf("%s was denied by the gatekeeper: %s", j.JobName, reason)})
+	return reason
+}
+
+// degrade keeps track of whether the connection to the database is dropped, emitting `StoreDegraded` for every execution
+// that is skipped while it is and `StoreRecovered` once an execution is claimed again
+func (s *scheduler) degrade(j *job, degraded bool, err error) {
+	s.mu.Lock()
+	changed := s.degraded != degraded
+	s.degraded = degraded
+	s.mu.Unlock()
+	switch {
+	case degraded:
+		if changed {
+			s.fail(fmt.Errorf("%s lost its connection to the database: %s", s.name, err))
 		}
-		return err
+		s.emit(Event{Type: StoreDegraded, Job: j, Time: time.Now(), ScheduledAt: j.LastRunAt, Err: err})
+	case changed:
+		log.Printf("schedule: %s reconnected to the database", s.name)
+		s.emit(Event{Type: StoreRecovered, Job: j, Time: time.Now(), ScheduledAt: j.LastRunAt})
 	}
-	// commit the change to the db
-	if err := tx.Commit().Error; err != nil {
-		if err := tx.Rollback().Error; err != nil {
-			return err
+}
+
+// emit sends `e` to the channel returned by `Events`, dropping it if the channel is full
+func (s *scheduler) emit(e Event) {
+	select {
+	case s.events <- e:
+	default:
+	}
+}
+
+// tracef logs why `j` did or did not run when `Config.Trace` is set
+func (s *scheduler) tracef(j *job, format string, args ...interface{}) {
+	if s.trace {
+		log.Printf("schedule: trace: %s %s", j.JobName, fmt.Sprintf(format, args...))
+	}
+}
+
+// Events returns the channel that every `Event` of the scheduler is sent to
+func (s *scheduler) Events() <-chan Event {
+	return s.events
+}
+
+// finish is called after each execution with the time between when the job was due and when it started,
+// the time it took and the error it failed with if any
+func (s *scheduler) finish(j *job, latency, duration time.Duration, err error) {
+	s.mu.Lock()
+	s.stats.Executions++
+	s.latency += latency
+	if err != nil {
+		s.stats.Failures++
+	}
+	s.mu.Unlock()
+	e := Event{Type: JobFinished, Job: j, Time: time.Now(), ScheduledAt: j.LastRunAt, Duration: duration}
+	if err != nil {
+		e.Type = JobFailed
+		e.Err = err
+	}
+	s.emit(e)
+
+	// share the counters of the job with every instance
+	if f, ok := s.store.(Finisher); ok {
+		if err := f.Finish(s.table, &j.Record, duration, err); err != nil {
+			log.Println(err)
 		}
 	}
-	return nil
+}
+
+// Stats returns the totals of every execution since the scheduler was created
+func (s *scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	if stats.Executions > 0 {
+		stats.AverageLatency = s.latency / time.Duration(stats.Executions)
+	}
+	return stats
 }
@@ -1,16 +1,36 @@
 package schedule_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/internal/step"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestSeconds(t *testing.T) {
-	s := schedule.New(&schedule.Config{
-		Name: "test",
+	s := schedule.MustNew(&schedule.Config{
+		Name:    "test",
+		Workers: 1,
 	})
 	now := time.Now()
 	var amounts []int
@@ -51,16 +71,17 @@ func TestDatabaseSeconds(t *testing.T) {
 	// create 10 competing test schedulers
 	var ss []schedule.Scheduler
 	config := schedule.Config{
-		Name:     "second-test-scheduler",
-		Database: "test",
-		Instance: "127.0.0.1:3306",
-		Username: "test",
-		Password: "test",
+		Name:        "second-test-scheduler",
+		Database:    "test",
+		Instance:    "127.0.0.1:3306",
+		Username:    "test",
+		Password:    "test",
+		AutoMigrate: true,
 		// LogDB:    true,
 	}
 	now := time.Now()
 	for i := 0; i < 10; i++ {
-		s := schedule.New(&config)
+		s := schedule.MustNew(&config)
 		s.Add("1-second").Every(1).Seconds().Starting(now).Do(test)
 		s.Add("2-second").Every(2).Seconds().Starting(now).Do(test)
 		s.Add("3-second").Every(3).Seconds().Starting(now).Do(test)
@@ -100,16 +121,17 @@ func TestDatabaseOnce(t *testing.T) {
 	// create 10 competing test schedulers
 	var ss []schedule.Scheduler
 	config := schedule.Config{
-		Name:     "second-test-scheduler",
-		Database: "test",
-		Instance: "127.0.0.1:3306",
-		Username: "test",
-		Password: "test",
+		Name:        "second-test-scheduler",
+		Database:    "test",
+		Instance:    "127.0.0.1:3306",
+		Username:    "test",
+		Password:    "test",
+		AutoMigrate: true,
 		// LogDB:    true,
 	}
 	now := time.Now()
 	for i := 0; i < 10; i++ {
-		s := schedule.New(&config)
+		s := schedule.MustNew(&config)
 		s.Add("once").Once().Starting(now).Do(test)
 		s.Start()
 		ss = append(ss, s)
@@ -124,3 +146,1974 @@ func TestDatabaseOnce(t *testing.T) {
 		0,
 	}, amounts, "the seconds are in the correct order")
 }
+
+func TestDatabaseAdvisoryLocking(t *testing.T) {
+	var runs int32
+	config := schedule.Config{
+		Name:          "advisory-test-scheduler",
+		Database:      "test",
+		Instance:      "127.0.0.1:3306",
+		Username:      "test",
+		Password:      "test",
+		AutoMigrate:   true,
+		ClaimStrategy: schedule.AdvisoryLocking,
+	}
+
+	// 10 competing schedulers claim each execution with the lock of the job
+	now := time.Now()
+	var ss []schedule.Scheduler
+	for i := 0; i < 10; i++ {
+		s := schedule.MustNew(&config)
+		s.Add("1-second").Every(1).Seconds().Starting(now).MustDo(func(j schedule.Job, now time.Time) {
+			atomic.AddInt32(&runs, 1)
+		})
+		s.Start()
+		ss = append(ss, s)
+	}
+	<-time.NewTimer(3500 * time.Millisecond).C
+	for _, s := range ss {
+		s.Stop()
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&runs), "each execution is claimed once")
+}
+
+func TestDatabaseSkipLocked(t *testing.T) {
+	var runs int32
+	config := schedule.Config{
+		Name:        "skip-locked-test-scheduler",
+		Database:    "test",
+		Instance:    "127.0.0.1:3306",
+		Username:    "test",
+		Password:    "test",
+		AutoMigrate: true,
+		LockTimeout: time.Second,
+		SkipLocked:  true,
+	}
+
+	// the schedulers that lose the lock of the job give up the execution instead of waiting for it
+	now := time.Now()
+	var ss []schedule.Scheduler
+	for i := 0; i < 10; i++ {
+		s := schedule.MustNew(&config)
+		s.Add("1-second").Every(1).Seconds().Starting(now).MustDo(func(j schedule.Job, now time.Time) {
+			atomic.AddInt32(&runs, 1)
+		})
+		s.Start()
+		ss = append(ss, s)
+	}
+	<-time.NewTimer(3500 * time.Millisecond).C
+	skipped := 0
+	for _, s := range ss {
+		s.Stop()
+		skipped += s.(schedule.Monitor).Stats().Skipped
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&runs), "each execution is claimed once")
+	assert.Equal(t, 27, skipped, "the other schedulers give up the execution")
+}
+
+func TestDatabaseLockTimeout(t *testing.T) {
+	db, err := sql.Open("mysql", "test:test@tcp(127.0.0.1:3306)/test?parseTime=true")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	// the claims share the only connection with the application
+	db.SetMaxOpenConns(1)
+	var before, after int
+	if !assert.NoError(t, db.QueryRow("SELECT @@SESSION.innodb_lock_wait_timeout").Scan(&before)) {
+		return
+	}
+	store, err := schedule.NewSQLStore(db, "mysql")
+	if !assert.NoError(t, err) {
+		return
+	}
+	store.AutoMigrate = true
+	store.LockTimeout = time.Second
+	var runs int32
+	s := schedule.MustNew(&schedule.Config{Name: "lock-timeout-test-scheduler", Store: store})
+	s.Add("1-second").Every(1).Seconds().Starting(time.Now()).MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	s.Stop()
+	assert.NotZero(t, atomic.LoadInt32(&runs))
+	assert.NoError(t, db.QueryRow("SELECT @@SESSION.innodb_lock_wait_timeout").Scan(&after))
+	assert.Equal(t, before, after, "the lock timeout of the claims does not leak into the connection")
+}
+
+func TestDatabaseAdvisoryLockTimeout(t *testing.T) {
+	db, err := sql.Open("mysql", "test:test@tcp(127.0.0.1:3306)/test?parseTime=true")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	store, err := schedule.NewSQLStore(db, "mysql")
+	if !assert.NoError(t, err) {
+		return
+	}
+	store.AutoMigrate = true
+	store.ClaimStrategy = schedule.AdvisoryLocking
+	store.LockTimeout = time.Second
+	r := schedule.Record{JobName: "job", IntervalAmount: 1, IntervalType: schedule.Seconds, NextRunAt: time.Now()}
+	if !assert.NoError(t, store.Add("advisory-timeout-test-scheduler", &r)) {
+		return
+	}
+
+	// another instance holds the lock of the job
+	conn, err := db.Conn(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	h := fnv.New64a()
+	h.Write([]byte("advisory-timeout-test-scheduler.job"))
+	_, err = conn.ExecContext(context.Background(), "SELECT GET_LOCK(CONCAT('schedule-', ?), 0)", int64(h.Sum64()))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the claim gives up once the lock timeout passes instead of the default of the advisory locks
+	start := time.Now()
+	assert.Equal(t, schedule.ErrAlreadyExecuted, store.Claim("advisory-timeout-test-scheduler", &r))
+	assert.True(t, time.Since(start) < 5*time.Second, "the claim waits for the lock timeout")
+}
+
+func TestWithRetries(t *testing.T) {
+	s := schedule.WithRetries(schedule.MustNew(&schedule.Config{
+		Name: "retry-test",
+	}), schedule.RetryPolicy{
+		Attempts: 3,
+	})
+	var attempts int
+	s.Add("retry").Every(1).Seconds().Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {
+		attempts++
+		if attempts < 3 {
+			panic("fail")
+		}
+	})
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	s.Stop()
+	assert.New(t).Equal(3, attempts, "the task is retried until it succeeds")
+}
+
+// minimal is a third party `Scheduler` that only implements the required methods
+type minimal struct {
+	schedule.Scheduler
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	assert := assert.New(t)
+
+	// the optional interfaces of the schedulers created by `New` are found through the decorators
+	s := schedule.WithRetries(schedule.MustNew(&schedule.Config{Name: "optional-test"}), schedule.RetryPolicy{})
+	s.Add("job").Every(1).Days().At(9, 0, 0).MustDo(func(j schedule.Job, now time.Time) {})
+	_, ok := s.(schedule.Replayer)
+	assert.False(ok, "a decorator does not implement the interfaces that depend on what it wraps")
+	var r schedule.Replayer
+	if assert.True(schedule.As(s, &r)) {
+		assert.NoError(r.Replay("job", time.Now()))
+	}
+	var m schedule.Monitor
+	if assert.True(schedule.As(s, &m)) {
+		assert.False(m.IsRunning())
+	}
+	_, ok = s.(schedule.Replacer)
+	assert.True(ok, "a decorator of a replacer replaces")
+	_, ok = s.(schedule.SpecAdder)
+	assert.True(ok, "a decorator always adds specs through itself")
+
+	// and are not found when the scheduler they wrap does not implement them
+	s = schedule.WithDigest(schedule.WithRetries(minimal{schedule.MustNew(&schedule.Config{Name: "minimal-test"})}, schedule.RetryPolicy{}), &schedule.Webhook{})
+	s.Add("job").Every(1).Days().At(9, 0, 0).MustDo(func(j schedule.Job, now time.Time) {})
+	assert.False(schedule.As(s, &r))
+	var d schedule.Drainer
+	assert.False(schedule.As(s, &d))
+	_, ok = s.(schedule.Replacer)
+	assert.False(ok)
+	assert.Len(s.List(), 1)
+}
+
+func TestWithRetriesStop(t *testing.T) {
+	policy := schedule.RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Minute,
+	}
+	digest := &schedule.Webhook{URL: "http://127.0.0.1:0"}
+	for name, s := range map[string]schedule.Scheduler{
+		"retries":           schedule.WithRetries(schedule.MustNew(&schedule.Config{Name: "retry-stop-test"}), policy),
+		"retries in digest": schedule.WithDigest(schedule.WithRetries(schedule.MustNew(&schedule.Config{Name: "retry-digest-stop-test"}), policy), digest),
+		"digest in retries": schedule.WithRetries(schedule.WithDigest(schedule.MustNew(&schedule.Config{Name: "digest-retry-stop-test"}), digest), policy),
+	} {
+		failed := make(chan struct{}, 1)
+		s.Add("retry").Every(1).Seconds().Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {
+			failed <- struct{}{}
+			panic("fail")
+		})
+		s.Start()
+		<-failed
+		start := time.Now()
+		s.Stop()
+		assert.True(t, time.Since(start) < 5*time.Second, "stop does not wait for the backoff of the retry with %s", name)
+	}
+}
+
+func TestTimes(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{
+		Name: "times-test",
+	})
+	var runs int
+	s.Add("twice").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {
+		runs++
+	})
+	s.Start()
+	<-time.NewTimer(3500 * time.Millisecond).C
+	s.Stop()
+	assert.New(t).Equal(2, runs, "the job stops after running twice")
+}
+
+func TestRecordingStore(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	config := schedule.Config{
+		Name:  "recording-test",
+		Store: store,
+	}
+
+	// create 2 competing schedulers that share the store
+	var runs int
+	var mu sync.Mutex
+	now := time.Now()
+	var ss []schedule.Scheduler
+	for i := 0; i < 2; i++ {
+		s := schedule.MustNew(&config)
+		s.Add("1-second").Every(1).Seconds().Starting(now).Do(func(j schedule.Job, now time.Time) {
+			mu.Lock()
+			runs++
+			mu.Unlock()
+		})
+		s.Start()
+		ss = append(ss, s)
+	}
+	<-time.NewTimer(3500 * time.Millisecond).C
+	for _, s := range ss {
+		s.Stop()
+	}
+	assert := assert.New(t)
+	assert.Equal(3, runs, "each execution only happens once")
+	assert.Len(store.Executions("recording-test", "1-second"), 3, "each execution is recorded")
+}
+
+func TestDiscovery(t *testing.T) {
+	store := schedule.NewRecordingStore()
+
+	// the first scheduler declares the job
+	s1 := schedule.MustNew(&schedule.Config{Name: "discovery-test", Store: store})
+	s1.Add("1-second").Every(1).Seconds().Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {})
+
+	// the second scheduler only knows its task
+	var runs int
+	var mu sync.Mutex
+	s2 := schedule.MustNew(&schedule.Config{
+		Name:      "discovery-test",
+		Store:     store,
+		Discovery: time.Second,
+		Tasks: map[string]func(schedule.Job, time.Time){
+			"1-second": func(j schedule.Job, now time.Time) {
+				mu.Lock()
+				runs++
+				mu.Unlock()
+			},
+		},
+	})
+	s2.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s2.Stop()
+	assert := assert.New(t)
+	assert.Len(s2.List(), 1, "the job is discovered")
+	assert.NotZero(runs, "the discovered job is executed")
+}
+
+func TestRegister(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s1 := schedule.MustNew(&schedule.Config{Name: "register-test", Store: store})
+	s1.Add("send-invoices").Every(1).Days().At(9, 0, 0).Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {})
+
+	// a restarted instance re-binds the stored job to its registered task
+	schedule.Register("send-invoices", func(j schedule.Job, now time.Time) {})
+	s2 := schedule.MustNew(&schedule.Config{Name: "register-test", Store: store})
+	s2.Start()
+	s2.Stop()
+	assert.Len(t, s2.List(), 1, "the stored job is bound to the registered task")
+}
+
+func TestSchedule(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "schedule-test"})
+	s.Start()
+	defer s.Stop()
+
+	// add a job while the scheduler is running
+	var runs int
+	var mu sync.Mutex
+	assert := assert.New(t)
+	assert.NoError(s.(schedule.SpecAdder).Schedule(schedule.JobSpec{
+		Name:     "1-second",
+		Every:    1,
+		Interval: schedule.Seconds,
+		Do: func(j schedule.Job, now time.Time) {
+			mu.Lock()
+			runs++
+			mu.Unlock()
+		},
+	}))
+	assert.Error(s.(schedule.SpecAdder).Schedule(schedule.JobSpec{
+		Name:     "invalid",
+		Every:    1,
+		Interval: schedule.IntervalType("fortnights"),
+		Do:       func(j schedule.Job, now time.Time) {},
+	}), "invalid specs return an error")
+	<-time.NewTimer(2500 * time.Millisecond).C
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotZero(runs, "the job is executed")
+}
+
+func TestTenants(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "tenant-test", Store: store})
+	now := time.Now()
+	s.Add("acme-invoices").Every(1).Days().At(9, 0, 0).Starting(now).ForTenant("acme").Do(func(j schedule.Job, now time.Time) {})
+	s.Add("acme-reports").Every(1).Weeks().On(1).At(9, 0, 0).Starting(now).ForTenant("acme").Do(func(j schedule.Job, now time.Time) {})
+	s.Add("globex-invoices").Every(1).Days().At(9, 0, 0).Starting(now).ForTenant("globex").Do(func(j schedule.Job, now time.Time) {})
+
+	assert := assert.New(t)
+	assert.Len(s.(schedule.TenantManager).ListTenant("acme"), 2, "only the jobs of the tenant are listed")
+	assert.NoError(s.(schedule.TenantManager).PauseTenant("acme", true))
+	for _, r := range store.Records("tenant-test") {
+		assert.Equal(r.TenantName == "acme", r.Paused, "only the jobs of the tenant are paused")
+	}
+	assert.NoError(s.(schedule.TenantManager).RemoveTenant("acme"))
+	assert.Len(s.List(), 1, "the jobs of the tenant are removed from the scheduler")
+	assert.Len(store.Records("tenant-test"), 1, "the jobs of the tenant are removed from the store")
+}
+
+func TestDisable(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	var runs int32
+	var ss []schedule.Scheduler
+	for i := 0; i < 2; i++ {
+		s := schedule.MustNew(&schedule.Config{Name: "disable-test", Store: store})
+		s.Add("1-second").Every(1).Seconds().Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {
+			atomic.AddInt32(&runs, 1)
+		})
+		ss = append(ss, s)
+	}
+	assert := assert.New(t)
+	assert.Error(ss[0].(schedule.Toggler).Disable("missing"))
+
+	// a job that is disabled by one instance is not executed by any of them
+	assert.NoError(ss[0].(schedule.Toggler).Disable("1-second"))
+	assert.True(store.Records("disable-test")[0].Disabled)
+	for _, s := range ss {
+		s.Start()
+	}
+	<-time.NewTimer(1500 * time.Millisecond).C
+	assert.Zero(atomic.LoadInt32(&runs), "the disabled job is not executed")
+
+	// and it is executed again once another instance enables it
+	assert.NoError(ss[1].(schedule.Toggler).Enable("1-second"))
+	<-time.NewTimer(1500 * time.Millisecond).C
+	for _, s := range ss {
+		s.Stop()
+	}
+	assert.NotZero(atomic.LoadInt32(&runs), "the enabled job is executed")
+	assert.False(store.Records("disable-test")[0].Disabled)
+}
+
+// traceLog collects the log output of the scheduler
+type traceLog struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *traceLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *traceLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+func TestTrace(t *testing.T) {
+	var out traceLog
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	s := schedule.MustNew(&schedule.Config{Name: "trace-test", Trace: true})
+	now := time.Now()
+	s.Add("1-second").Every(1).Seconds().Starting(now).Do(func(j schedule.Job, now time.Time) {})
+	s.Add("paused").Every(1).Seconds().Starting(now).ForTenant("acme").Do(func(j schedule.Job, now time.Time) {})
+	s.Add("daily").Every(1).Days().At(now.Hour(), now.Minute(), now.Second()).Starting(now).Do(func(j schedule.Job, now time.Time) {})
+	s.(schedule.TenantManager).PauseTenant("acme", true)
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	s.Stop()
+
+	assert := assert.New(t)
+	assert.Contains(out.String(), "schedule: trace: 1-second claimed its execution at")
+	assert.Regexp(`schedule: trace: paused did not run at .*, it is paused`, out.String())
+	assert.Contains(out.String(), "schedule: trace: daily did not run, it is not due until")
+}
+
+func TestManager(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	m := schedule.NewManager(store)
+	assert := assert.New(t)
+	billing, err := m.New(&schedule.Config{Name: "billing"})
+	assert.NoError(err)
+	reports, err := m.New(&schedule.Config{Name: "reports"})
+	assert.NoError(err)
+	_, err = m.New(&schedule.Config{Name: "reports"})
+	assert.Error(err, "scheduler names are unique")
+
+	now := time.Now()
+	billing.Add("invoices").Every(1).Days().At(9, 0, 0).Starting(now).Do(func(j schedule.Job, now time.Time) {})
+	reports.Add("weekly").Every(1).Weeks().On(1).At(9, 0, 0).Starting(now).Do(func(j schedule.Job, now time.Time) {})
+	m.Start()
+	m.Stop()
+	assert.Len(m.List(), 2, "the jobs of every scheduler are listed")
+	assert.Len(store.Records("billing"), 1, "the schedulers share the store of the manager")
+	assert.Equal(reports, m.Scheduler("reports"))
+}
+
+func TestStopContext(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "stop-test"})
+	started := make(chan struct{})
+	s.Add("slow").Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {
+		close(started)
+		time.Sleep(2 * time.Second)
+	})
+	s.Start()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := s.(schedule.Drainer).StopContext(ctx)
+	if assert.Error(t, err, "the shutdown is bounded by the context") {
+		assert.Contains(t, err.Error(), "slow", "the interrupted job is reported")
+	}
+
+	// the funcs that watch the context of their job return once the shutdown stops waiting for them
+	s = schedule.MustNew(&schedule.Config{Name: "stop-test"})
+	started = make(chan struct{})
+	cancelled := make(chan error, 1)
+	s.Add("cancellable").Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {
+		close(started)
+		select {
+		case <-j.Context().Done():
+			cancelled <- j.Context().Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+	})
+	s.Start()
+	<-started
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, s.(schedule.Drainer).StopContext(ctx))
+	assert.Equal(t, context.Canceled, <-cancelled, "the context of the job is cancelled")
+}
+
+func TestRequiresHealthy(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "requires-healthy-test", Workers: 1})
+	events := s.(schedule.Monitor).Events()
+	var down int32 = 1
+	var deferred, recovered, other int32
+	s.Add("deferred").Every(1).Seconds().RequiresHealthy(func(ctx context.Context) error {
+		if atomic.LoadInt32(&down) == 1 {
+			return errors.New("the downstream service is down")
+		}
+		return nil
+	}).MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&recovered, 1)
+	})
+
+	// a slow check does not hold up the worker that executes the other jobs
+	s.Add("slow").Every(1).Seconds().RequiresHealthy(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).MustDo(func(j schedule.Job, now time.Time) {})
+	s.Add("other").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&other, 1)
+	})
+	s.Start()
+	time.Sleep(1500 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(&recovered), "the job does not run while its dependency is down")
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(3 * time.Second)
+	s.Stop()
+	assert.NotZero(t, atomic.LoadInt32(&recovered), "the job runs once its dependency is healthy again")
+	assert.True(t, atomic.LoadInt32(&other) >= 3, "the other job ran %d times", atomic.LoadInt32(&other))
+
+	// the failed checks are sent as events
+	for len(events) > 0 {
+		if e := <-events; e.Type == schedule.JobDeferred && e.Job.Name() == "deferred" {
+			assert.EqualError(t, e.Err, "the downstream service is down")
+			deferred++
+		}
+	}
+	assert.NotZero(t, deferred)
+}
+
+func TestState(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "state-test"})
+	s.Add("daily").Every(1).Days().At(9, 0, 0).Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {})
+	assert := assert.New(t)
+	assert.False(s.(schedule.Monitor).IsRunning(), "the scheduler was never started")
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	assert.True(s.(schedule.Monitor).IsRunning())
+	assert.Equal(schedule.State{Status: schedule.Running, Pending: 1}, s.(schedule.Monitor).State())
+	s.Stop()
+	assert.Equal(schedule.Stopped, s.(schedule.Monitor).State().Status)
+}
+
+func TestDrain(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "drain-test"})
+	started := make(chan struct{}, 1)
+	var runs int32
+	s.Add("slow").Every(1).Seconds().Starting(time.Now()).Do(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+		started <- struct{}{}
+		time.Sleep(time.Second)
+	})
+	s.Start()
+	<-started
+	s.(schedule.Drainer).Drain()
+	assert.Equal(t, schedule.State{Status: schedule.Draining, Executing: 1}, s.(schedule.Monitor).State(), "the running job finishes")
+	<-time.NewTimer(2500 * time.Millisecond).C
+	assert.Equal(t, schedule.Draining, s.(schedule.Monitor).State().Status)
+	assert.Equal(t, 0, s.(schedule.Monitor).State().Executing)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs), "no new executions are claimed")
+	s.Stop()
+	assert.Equal(t, schedule.Stopped, s.(schedule.Monitor).State().Status)
+}
+
+func TestExpireAfter(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "expire-test", Store: store})
+	s.Add("temporary").Every(1).Seconds().Starting(time.Now()).Times(1).ExpireAfter(time.Second).MustDo(func(j schedule.Job, now time.Time) {})
+	s.Add("permanent").Every(1).Seconds().Starting(time.Now()).Times(1).MustDo(func(j schedule.Job, now time.Time) {})
+	s.Start()
+	<-time.NewTimer(3500 * time.Millisecond).C
+	s.Stop()
+	if assert.Len(t, s.List(), 1, "the temporary job was removed") {
+		assert.Equal(t, "permanent", s.List()[0].Name())
+	}
+	assert.Len(t, store.Records("expire-test"), 1, "the temporary job was removed from the store")
+}
+
+func TestRemoveCompleted(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "completed-test", Store: store, RemoveCompleted: true})
+	var runs int32
+	s.Add("once").Once().Starting(time.Now().Add(time.Second)).MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	assert.False(t, s.List()[0].Completed())
+	s.Start()
+	<-time.NewTimer(3500 * time.Millisecond).C
+	s.Stop()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	assert.Empty(t, s.List(), "the completed job was removed")
+	assert.Empty(t, store.Records("completed-test"), "the completed job was removed from the store")
+}
+
+func TestLazyConnect(t *testing.T) {
+	errs := make(chan error, 10)
+	s, err := schedule.New(&schedule.Config{
+		Name:         "lazy-test",
+		DSN:          "user:password@tcp(127.0.0.1:1)/test?timeout=100ms",
+		ConnectRetry: schedule.RetryPolicy{Attempts: 2, Backoff: 10 * time.Millisecond},
+		ErrorHandler: func(err error) { errs <- err },
+	})
+	if !assert.NoError(t, err, "the database is not connected to by New") {
+		return
+	}
+	var runs int32
+	s.Add("job").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	s.Stop()
+	assert.Len(t, errs, 2, "every attempt to connect is reported")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs), "nothing is executed until the database is connected")
+}
+
+// droppedStore fails every claim with a dropped connection while `dropped` is set
+type droppedStore struct {
+	schedule.Store
+	dropped int32
+}
+
+func (s *droppedStore) Claim(scheduler string, r *schedule.Record) error {
+	if atomic.LoadInt32(&s.dropped) == 1 {
+		return driver.ErrBadConn
+	}
+	return s.Store.Claim(scheduler, r)
+}
+
+func TestReconnect(t *testing.T) {
+	store := &droppedStore{Store: schedule.NewRecordingStore(), dropped: 1}
+	errs := make(chan error, 10)
+	s := schedule.MustNew(&schedule.Config{Name: "reconnect-test", Store: store, ErrorHandler: func(err error) { errs <- err }})
+	var runs int32
+	s.Add("job").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	s.Start()
+	defer s.Stop()
+	e := <-s.(schedule.Monitor).Events()
+	for e.Type != schedule.StoreDegraded {
+		e = <-s.(schedule.Monitor).Events()
+	}
+	assert.True(t, s.(schedule.Monitor).State().Degraded)
+	assert.Len(t, errs, 1, "the dropped connection is reported")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs), "nothing is executed while the connection is dropped")
+
+	atomic.StoreInt32(&store.dropped, 0)
+	for e.Type != schedule.StoreRecovered {
+		e = <-s.(schedule.Monitor).Events()
+	}
+	assert.False(t, s.(schedule.Monitor).State().Degraded)
+}
+
+func TestHealthy(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "healthy-test", Workers: 1, OverdueThreshold: time.Second})
+	assert.Error(t, s.(schedule.Monitor).Healthy(context.Background()), "a stopped scheduler is not healthy")
+	block := make(chan struct{})
+	s.Add("slow").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {
+		<-block
+	})
+	s.Add("fast").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {})
+	s.Start()
+	defer s.Stop()
+	assert.NoError(t, s.(schedule.Monitor).Healthy(context.Background()))
+
+	// the fast job waits for the only worker behind the slow job
+	<-time.NewTimer(3500 * time.Millisecond).C
+	err := s.(schedule.Monitor).Healthy(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "overdue")
+	}
+	close(block)
+}
+
+func TestTableName(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "default", TableName: "table_name_test", Store: store})
+	s.Add("job").Every(1).Hours().MustDo(func(j schedule.Job, now time.Time) {})
+	assert.Len(t, store.Records("table_name_test"), 1, "the jobs are stored in the table")
+	assert.Empty(t, store.Records("default"))
+
+	_, err := schedule.New(&schedule.Config{Name: "table name test", DSN: "user:password@tcp(127.0.0.1:1)/test"})
+	assert.Error(t, err, "a name with a space is not a valid table name")
+}
+
+func TestTablePrefix(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "default", TablePrefix: "scheduler_jobs_", Schema: "jobs", Store: store})
+	s.Add("job").Every(1).Hours().MustDo(func(j schedule.Job, now time.Time) {})
+	assert.Len(t, store.Records("jobs.scheduler_jobs_default"), 1, "the table is prefixed and qualified by the schema")
+	ddl, err := schedule.Schema("mysql", "jobs.scheduler_jobs_default")
+	if assert.NoError(t, err) {
+		assert.Contains(t, ddl, "CREATE TABLE `jobs`.`scheduler_jobs_default`")
+		assert.Contains(t, ddl, "`jobs`.`scheduler_jobs_default_audit`", "the audit log is created with the table")
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := schedule.Migrations("mysql", "migrations_test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "the migrations are in order")
+		assert.Contains(t, m.SQL, "`migrations_test")
+	}
+	_, err = schedule.Migrations("oracle", "migrations_test")
+	assert.Error(t, err)
+
+	// the schema creates every table that the migrations create
+	ddl, err := schedule.Schema("mysql", "migrations_test")
+	if assert.NoError(t, err) {
+		created := regexp.MustCompile("CREATE TABLE (?:IF NOT EXISTS )?(`[^`]+`) \\(")
+		var tables int
+		for _, m := range migrations {
+			for _, match := range created.FindAllStringSubmatch(m.SQL, -1) {
+				tables++
+				assert.Regexp(t, "CREATE TABLE (IF NOT EXISTS )?"+regexp.QuoteMeta(match[1])+" \\(", ddl)
+			}
+		}
+		assert.Equal(t, 3, tables, "the jobs, the instances and the audit log")
+	}
+
+	// the first migration creates the table of the first version, the columns added since are added by the others
+	assert.Contains(t, migrations[0].SQL, "`next_run_at`")
+	for _, column := range []string{"tenant_name", "day_mask", "weekday", "occurrence", "window_start", "end_at", "max_runs", "run_count",
+		"paused", "zone", "checksum", "version", "failure_count", "pending_until", "payload", "disabled"} {
+		var added []int
+		for _, m := range migrations {
+			if strings.Contains(m.SQL, "`"+column+"`") && !strings.Contains(m.SQL, "MODIFY COLUMN") {
+				added = append(added, m.Version)
+			}
+		}
+		if assert.Len(t, added, 1, column) {
+			assert.NotEqual(t, 1, added[0], column)
+		}
+	}
+
+	// the times keep the fractions of a second, which only the mysql tables of the first versions truncated
+	assert.Contains(t, migrations[11].SQL, "MODIFY COLUMN `next_run_at` DATETIME(6)")
+	postgres, err := schedule.Migrations("postgres", "migrations_test")
+	if assert.NoError(t, err) {
+		assert.Empty(t, postgres[11].SQL)
+	}
+}
+
+func TestDatabaseMigrate(t *testing.T) {
+	db, err := sql.Open("mysql", "test:test@tcp(127.0.0.1:3306)/test?parseTime=true")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	// a table created by the auto-migration of the first version of this package
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS `migrate_test`, `migrate_test_migrations`, `migrate_test_instances`, `migrate_test_audit`",
+		"CREATE TABLE `migrate_test` (`job_name` varchar(255), `interval_amount` int, `interval_type` varchar(255), `month` int, " +
+			"`day` int, `hour` int, `minute` int, `second` int, `start_at` datetime NULL, `last_run_at` datetime NULL, " +
+			"`next_run_at` datetime NULL, PRIMARY KEY (`job_name`))",
+		"INSERT INTO `migrate_test` (`job_name`, `interval_amount`, `interval_type`) VALUES ('job', 1, 'days')",
+	} {
+		if _, err := db.Exec(stmt); !assert.NoError(t, err) {
+			return
+		}
+	}
+	if !assert.NoError(t, schedule.Migrate(db, "mysql", "migrate_test")) {
+		return
+	}
+	assert.NoError(t, schedule.Migrate(db, "mysql", "migrate_test"), "the migrations that were applied are not applied again")
+
+	// the job keeps the state that the columns added by the migrations hold
+	store, err := schedule.NewSQLStore(db, "mysql")
+	if !assert.NoError(t, err) {
+		return
+	}
+	s := schedule.MustNew(&schedule.Config{Name: "migrate", TableName: "migrate_test", Store: store})
+	assert.NoError(t, s.Add("job").Every(1).Days().At(9, 0, 0).Starting(time.Now()).ForTenant("acme").Do(func(schedule.Job, time.Time) {}))
+	assert.NoError(t, s.(schedule.TenantManager).PauseTenant("acme", true))
+	var paused bool
+	var version int
+	assert.NoError(t, db.QueryRow("SELECT `paused`, `version` FROM `migrate_test` WHERE `job_name` = 'job'").Scan(&paused, &version))
+	assert.True(t, paused)
+	assert.NotZero(t, version)
+
+	// the times are not truncated to seconds
+	next := time.Date(2030, 1, 1, 0, 0, 0, int(250*time.Millisecond), time.UTC)
+	var stored time.Time
+	assert.NoError(t, db.QueryRow("UPDATE `migrate_test` SET `next_run_at` = ? WHERE `job_name` = 'job'", next).Err())
+	assert.NoError(t, db.QueryRow("SELECT `next_run_at` FROM `migrate_test` WHERE `job_name` = 'job'").Scan(&stored))
+	assert.True(t, next.Equal(stored), "%s was stored as %s", next, stored)
+}
+
+func TestObserver(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "observer-test", Store: store})
+	var runs int32
+	s.Add("job").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	s.Start()
+	defer s.Stop()
+
+	// the observer loads the job without a task and never executes it
+	observer := schedule.MustNew(&schedule.Config{Name: "observer-test", Store: store, Observer: true, Discovery: time.Second})
+	observer.Start()
+	defer observer.Stop()
+	<-time.NewTimer(3500 * time.Millisecond).C
+	jobs := observer.List()
+	if assert.Len(t, jobs, 1) {
+		assert.NotZero(t, jobs[0].Stats().RunCount, "the state of the job is refreshed")
+	}
+	assert.Len(t, store.Executions("observer-test", "job"), int(atomic.LoadInt32(&runs)), "only the scheduler executes the job")
+	assert.Error(t, observer.(schedule.Replayer).Replay("job", time.Now()))
+}
+
+func TestHistory(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	config := schedule.Config{Name: "history-test", Store: store, Actor: "ops"}
+	for i := 0; i < 2; i++ {
+		s := schedule.MustNew(&config)
+		s.Add("payout").Every(1).Days().At(9, 0, 0).ForTenant("acme").MustDo(func(j schedule.Job, now time.Time) {})
+	}
+	s := schedule.MustNew(&config)
+	s.(schedule.Replacer).AddOrReplace("payout").Every(1).Days().At(17, 0, 0).ForTenant("acme").MustDo(func(j schedule.Job, now time.Time) {})
+	assert.NoError(t, s.(schedule.TenantManager).PauseTenant("acme", true))
+	assert.NoError(t, s.(schedule.Remover).Remove("payout"))
+
+	history, err := s.(schedule.Historian).History("payout")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var actions []schedule.AuditAction
+	for _, e := range history {
+		actions = append(actions, e.Action)
+		assert.Equal(t, "ops", e.Actor)
+	}
+	assert.Equal(t, []schedule.AuditAction{schedule.AuditAdded, schedule.AuditModified, schedule.AuditPaused, schedule.AuditRemoved}, actions,
+		"restarting the scheduler does not change the schedule")
+	if assert.Len(t, history, 4) {
+		assert.Contains(t, history[1].Before, `"hour":9`)
+		assert.Contains(t, history[1].After, `"hour":17`)
+	}
+}
+
+// freeze is a `Gatekeeper` that denies the executions before `until`
+type freeze struct {
+	until time.Time
+}
+
+// Allow implements `schedule.Gatekeeper`
+func (f freeze) Allow(j schedule.Job, t time.Time) (bool, string) {
+	if t.Before(f.until) {
+		return false, "change freeze"
+	}
+	return true, ""
+}
+
+func TestGatekeeper(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := schedule.MustNew(&schedule.Config{Name: "gatekeeper-test", Store: store, Gatekeeper: freeze{until: start.Add(2 * 24 * time.Hour)}})
+	events := s.(schedule.Monitor).Events()
+	s.Add("job").Every(1).Days().At(9, 0, 0).Starting(start).Times(2).MustDo(func(j schedule.Job, now time.Time) {})
+
+	// the denied executions do not count toward the runs of the job
+	var runs []time.Time
+	assert.NoError(t, step.Step(s, start.Add(10*24*time.Hour), func(name string, t time.Time) {
+		runs = append(runs, t)
+	}))
+	if assert.Len(t, runs, 2) {
+		assert.True(t, start.Add(2*24*time.Hour).Equal(runs[0]), "the executions during the freeze are denied")
+	}
+
+	// the denials are recorded in the history of the job and sent as events
+	history, err := s.(schedule.Historian).History("job")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var denied []schedule.AuditEntry
+	for _, e := range history {
+		if e.Action == schedule.AuditDenied {
+			denied = append(denied, e)
+		}
+	}
+	if assert.Len(t, denied, 2) {
+		assert.Equal(t, "change freeze", denied[0].Reason)
+	}
+	var skipped int
+	for len(events) > 0 {
+		if e := <-events; e.Type == schedule.JobSkipped && assert.Error(t, e.Err) {
+			assert.Contains(t, e.Err.Error(), "change freeze")
+			skipped++
+		}
+	}
+	assert.Equal(t, 2, skipped)
+}
+
+func TestReconcile(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	config := schedule.Config{Name: "reconcile-test", Store: store}
+	old := schedule.MustNew(&config)
+	old.Add("job").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {})
+	old.Start()
+
+	// a newer version of the service changes the interval of the job
+	s := schedule.MustNew(&config)
+	s.Add("job").Every(3).Seconds().MustDo(func(j schedule.Job, now time.Time) {})
+	time.Sleep(2500 * time.Millisecond)
+	old.Stop()
+
+	assert.Equal(t, 3, store.Records("reconcile-test")[0].IntervalAmount, "the old instance does not save its definition over the new one")
+	assert.Equal(t, 3, old.List()[0].Amount(), "the old instance adopts the new definition")
+}
+
+func TestClockSkew(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "skew-test", Store: store, ClockSkew: 2 * time.Second})
+	due := time.Now().Truncate(time.Second).Add(2 * time.Second)
+	s.Add("job").Every(10).Seconds().Starting(due.Add(-10 * time.Second)).MustDo(func(j schedule.Job, now time.Time) {})
+	assert.Error(t, s.Add("fast").Every(2).Seconds().Do(func(j schedule.Job, now time.Time) {}), "the interval must be longer than the clock skew")
+
+	// an instance whose clock is 1.5 seconds behind already claimed the execution
+	r := store.Records("skew-test")[0]
+	r.LastRunAt = due.Add(-1500 * time.Millisecond)
+	r.NextRunAt = r.LastRunAt.Add(10 * time.Second)
+	assert.NoError(t, store.Claim("skew-test", &r))
+
+	s.Start()
+	time.Sleep(3 * time.Second)
+	s.Stop()
+	assert.Len(t, store.Executions("skew-test", "job"), 1, "the execution is not claimed twice")
+	assert.Equal(t, 1, s.(schedule.Monitor).Stats().Skipped)
+}
+
+// aheadStore is a store whose database clock is an hour ahead of the local clock
+type aheadStore struct {
+	*schedule.RecordingStore
+}
+
+func (aheadStore) Now() (time.Time, error) {
+	return time.Now().Add(time.Hour), nil
+}
+
+func TestClock(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "clock-test", Store: aheadStore{schedule.NewRecordingStore()}, DatabaseClock: true})
+	ran := make(chan time.Time, 1)
+	s.Add("job").Once().Starting(time.Now().Add(time.Hour + 2*time.Second)).MustDo(func(j schedule.Job, now time.Time) {
+		ran <- now
+	})
+	s.Start()
+	defer s.Stop()
+	select {
+	case now := <-ran:
+		assert.WithinDuration(t, time.Now().Add(time.Hour), now, 2*time.Second, "the job runs at the time of the database")
+	case <-time.After(5 * time.Second):
+		t.Error("the job did not run at the time of the database")
+	}
+
+	// the times that the instances share are measured with the clock of the database
+	store := schedule.NewRecordingStore()
+	s = schedule.MustNew(&schedule.Config{Name: "clock-test", Store: aheadStore{store}, DatabaseClock: true})
+	s.Start()
+	s.Stop()
+	s.Add("lease").Every(1).Seconds().StartingNow().AtLeastOnce(time.Minute).MustDo(func(j schedule.Job, now time.Time) {
+		time.Sleep(2 * time.Second)
+	})
+	if jobs := s.List(); assert.Len(t, jobs, 1) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), jobs[0].NextRun(), 2*time.Second, "the job starts counting at the time of the database")
+	}
+	s.Start()
+	defer s.Stop()
+	time.Sleep(1500 * time.Millisecond)
+	if records := store.Records("clock-test"); assert.Len(t, records, 1) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour+time.Minute), records[0].PendingUntil, 2*time.Second, "the lease expires at the time of the database")
+	}
+}
+
+func TestAtLeastOnce(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "at-least-once-test", Store: store})
+	due := time.Now().Truncate(time.Second).Add(time.Second)
+	var runs int32
+	s.Add("job").Every(10).Seconds().Starting(due.Add(-10 * time.Second)).AtLeastOnce(time.Second).MustDo(func(j schedule.Job, now time.Time) {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("failed")
+		}
+	})
+	s.Start()
+	time.Sleep(3500 * time.Millisecond)
+	s.Stop()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs), "the failed execution is performed again once its lease expires")
+	assert.Equal(t, []time.Time{due, due}, store.Executions("at-least-once-test", "job"))
+	assert.True(t, store.Records("at-least-once-test")[0].PendingUntil.IsZero(), "the execution completed")
+}
+
+func TestAtLeastOnceRestart(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	due := time.Now().Truncate(time.Second).Add(time.Second)
+
+	// the first instance crashes while it performs the execution, which never completes
+	crashed := schedule.MustNew(&schedule.Config{Name: "at-least-once-restart-test", Store: store})
+	started, block := make(chan struct{}), make(chan struct{})
+	crashed.Add("job").Every(2).Seconds().Starting(due.Add(-2 * time.Second)).AtLeastOnce(time.Second).MustDo(func(j schedule.Job, now time.Time) {
+		close(started)
+		<-block
+	})
+	crashed.Start()
+	<-started
+
+	// the restarted instance performs it again once its lease expires, before the next execution is due, and then the next one
+	var mu sync.Mutex
+	var runs []time.Time
+	restarted := schedule.MustNew(&schedule.Config{Name: "at-least-once-restart-test", Store: store})
+	restarted.Add("job").Every(2).Seconds().Starting(due.Add(-2 * time.Second)).AtLeastOnce(time.Second).MustDo(func(j schedule.Job, now time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs = append(runs, j.LastRun())
+		if j.LastRun().Equal(due) {
+			assert.True(t, now.Before(due.Add(2*time.Second)), "the execution is performed again as soon as its lease expires")
+		}
+	})
+	restarted.Start()
+	time.Sleep(due.Add(3500 * time.Millisecond).Sub(time.Now()))
+	restarted.Stop()
+	close(block)
+	crashed.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []time.Time{due, due.Add(2 * time.Second)}, runs)
+	assert.Equal(t, []time.Time{due, due, due.Add(2 * time.Second)}, store.Executions("at-least-once-restart-test", "job")[:3])
+
+	// an execution whose lease expired is performed again before the execution that is due, which is not consumed by the claim
+	stored := schedule.Record{JobName: "job", LastRunAt: due, PendingUntil: time.Now().Add(-time.Second)}
+	r := schedule.Record{JobName: "job", LastRunAt: due.Add(2 * time.Second)}
+	assert.Equal(t, schedule.ErrNotCompleted, r.Claim(&stored))
+	assert.Equal(t, due.Add(2*time.Second), r.LastRunAt)
+}
+
+func TestRestartWithoutStarting(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	noop := func(schedule.Job, time.Time) {}
+	first := schedule.MustNew(&schedule.Config{Name: "restart-test", Store: store})
+	first.Add("job").Every(5).Hours().MustDo(noop)
+	checksum := store.Records("restart-test")[0].Checksum
+	start := first.List()[0].Definition().Starting
+	time.Sleep(1100 * time.Millisecond)
+
+	// restarting the same code keeps counting from when the job was first added, so its definition does not change
+	restarted := schedule.MustNew(&schedule.Config{Name: "restart-test", Store: store})
+	restarted.Add("job").Every(5).Hours().MustDo(noop)
+	assert.Equal(t, checksum, store.Records("restart-test")[0].Checksum)
+	assert.True(t, start.Equal(restarted.List()[0].Definition().Starting))
+	assert.Equal(t, start.Add(5*time.Hour), restarted.List()[0].NextRun())
+	history, err := restarted.(schedule.Historian).History("job")
+	assert.NoError(t, err)
+	assert.Len(t, history, 1, "the restart is not audited as a modification")
+}
+
+func TestExecutionKey(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "execution-key-test", Store: store})
+	keys := make(chan string, 10)
+	s.Add("job").Every(1).Seconds().MustDo(func(j schedule.Job, now time.Time) {
+		keys <- j.ExecutionKey()
+	})
+	s.Start()
+	key := <-keys
+	s.Stop()
+
+	executions := store.Executions("execution-key-test", "job")
+	if assert.NotEmpty(t, executions) {
+		assert.Equal(t, schedule.ExecutionKey("job", executions[0]), key)
+		assert.Equal(t, "job@"+executions[0].UTC().Format(time.RFC3339Nano), key)
+	}
+	assert.Equal(t, store.Records("execution-key-test")[0].LastExecutionKey, schedule.ExecutionKey("job", store.Records("execution-key-test")[0].LastRunAt))
+
+	// a replay has the key of the execution it performs again
+	if assert.NotEmpty(t, executions) && assert.NoError(t, s.(schedule.Replayer).Replay("job", executions[0])) {
+		assert.Equal(t, key, <-keys)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	type invoice struct {
+		CustomerID int
+	}
+	store := schedule.NewRecordingStore()
+
+	// the first scheduler declares the job with its payload
+	s1 := schedule.MustNew(&schedule.Config{Name: "payload-test", Store: store})
+	assert.NoError(t, s1.Add("invoice-42").Every(1).Seconds().Starting(time.Now()).WithPayload(invoice{CustomerID: 42}).Do(func(j schedule.Job, now time.Time) {}))
+	assert.Error(t, s1.Add("invalid").Every(1).Seconds().WithPayload(func() {}).Do(func(j schedule.Job, now time.Time) {}))
+
+	// the second scheduler discovers the job and hands its stored payload to the task
+	customers := make(chan int, 10)
+	s2 := schedule.MustNew(&schedule.Config{
+		Name:      "payload-test",
+		Store:     store,
+		Discovery: time.Second,
+		Tasks: map[string]func(schedule.Job, time.Time){
+			"invoice-42": func(j schedule.Job, now time.Time) {
+				var v invoice
+				if assert.NoError(t, j.Payload(&v)) {
+					customers <- v.CustomerID
+				}
+			},
+		},
+	})
+	s2.Start()
+	customer := <-customers
+	s2.Stop()
+	assert.Equal(t, 42, customer)
+	assert.JSONEq(t, `{"CustomerID":42}`, string(store.Records("payload-test")[0].Payload))
+}
+
+func TestReplay(t *testing.T) {
+	type invoice struct {
+		CustomerID int
+	}
+	type run struct {
+		invoice invoice
+		replay  bool
+		lastRun time.Time
+		key     string
+	}
+	runs := make(chan run, 10)
+	s := schedule.MustNew(&schedule.Config{Name: "replay-test", Store: schedule.NoopStore{}})
+	assert.NoError(t, s.Add("invoice-42").Every(1).Seconds().WithPayload(invoice{CustomerID: 42}).Do(func(j schedule.Job, now time.Time) {
+		var r run
+		assert.NoError(t, j.Payload(&r.invoice))
+		r.replay, r.lastRun, r.key = j.IsReplay(), j.LastRun(), j.ExecutionKey()
+		runs <- r
+		if j.IsReplay() && r.lastRun.IsZero() {
+			panic("the replay does not have a scheduled time")
+		}
+	}))
+	s.Start()
+	scheduled := <-runs
+	s.Stop()
+	assert.False(t, scheduled.replay)
+
+	// the replay performs the execution again with the same payload and scheduled time
+	if assert.NoError(t, s.(schedule.Replayer).Replay("invoice-42", scheduled.lastRun)) {
+		replayed := <-runs
+		assert.True(t, replayed.replay)
+		assert.Equal(t, scheduled.invoice, replayed.invoice, "the replay has the payload of the execution")
+		assert.True(t, scheduled.lastRun.Equal(replayed.lastRun), "the replay has the scheduled time of the execution")
+		assert.Equal(t, scheduled.key, replayed.key)
+	}
+
+	// a replay that panics returns an error instead of crashing the process
+	assert.Error(t, s.(schedule.Replayer).Replay("invoice-42", time.Time{}))
+	<-runs
+}
+
+func TestBackfill(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "backfill-test", Store: schedule.NoopStore{}})
+	start := time.Date(2023, time.January, 30, 0, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	var ran []time.Time
+	assert.NoError(t, s.Add("report").Every(1).Days().At(9, 0, 0).Starting(start).Also(func(a schedule.Amount) schedule.Starting {
+		return a.Every(1).Months().On(1).At(0, 0, 0)
+	}).Do(func(j schedule.Job, now time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, j.LastRun())
+		if j.LastRun().Equal(time.Date(2023, time.January, 31, 9, 0, 0, 0, time.UTC)) {
+			panic("failed")
+		}
+	}))
+
+	// every run of every schedule of the job is executed, at the rate and with the progress of the options
+	var progress []int
+	began := time.Now()
+	err := s.(schedule.Replayer).Backfill("report", start, time.Date(2023, time.February, 1, 12, 0, 0, 0, time.UTC), schedule.BackfillOptions{
+		Concurrency: 2,
+		Interval:    10 * time.Millisecond,
+		Progress: func(scheduled time.Time, done, total int, err error) {
+			assert.Equal(t, 4, total)
+			progress = append(progress, done)
+		},
+	})
+	assert.True(t, time.Since(began) >= 30*time.Millisecond, "the runs are started at the rate of the options")
+	if assert.Error(t, err, "the runs that panicked are returned") {
+		assert.Contains(t, err.Error(), "failed")
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, progress)
+	assert.Len(t, ran, 4, "the run that panicked does not stop the others")
+	assert.Contains(t, ran, time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC), "the runs of the other schedules are backfilled")
+
+	// the runs follow the definition of the job instead of the runs it already had
+	var times []time.Time
+	assert.NoError(t, s.Add("twice").Every(1).Days().At(9, 0, 0).Starting(start).Times(2).Do(func(j schedule.Job, now time.Time) {
+		times = append(times, j.LastRun())
+	}))
+	assert.NoError(t, s.(schedule.Replayer).Backfill("twice", start.AddDate(0, 0, 1), start.AddDate(0, 0, 5), schedule.BackfillOptions{}))
+	assert.Equal(t, []time.Time{time.Date(2023, time.January, 31, 9, 0, 0, 0, time.UTC)}, times, "only the first runs are backfilled")
+}
+
+func TestTyped(t *testing.T) {
+	type invoice struct {
+		CustomerID int
+	}
+	s := schedule.MustNew(&schedule.Config{Name: "typed-test"})
+	customers := make(chan int, 10)
+	send := schedule.Typed[invoice](func(ctx context.Context, j schedule.Job, v invoice) error {
+		customers <- v.CustomerID
+		return errors.New("failed")
+	})
+	assert.NoError(t, send.Do(s.Add("invoice-42").Every(1).Seconds().Starting(time.Now()).Times(1), invoice{CustomerID: 42}))
+	s.Start()
+	customer := <-customers
+	s.Stop()
+	assert.Equal(t, 42, customer)
+
+	// the returned error fails the execution
+	stats := s.List()[0].Stats()
+	assert.Equal(t, 1, stats.FailureCount)
+	assert.Equal(t, "failed", stats.LastError)
+}
+
+func TestStats(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	config := schedule.Config{
+		Name:  "stats-test",
+		Store: store,
+	}
+
+	// create 2 competing schedulers whose job always fails
+	now := time.Now()
+	var ss []schedule.Scheduler
+	for i := 0; i < 2; i++ {
+		s := schedule.MustNew(&config)
+		s.Add("1-second").Every(1).Seconds().Starting(now).Times(2).Do(func(j schedule.Job, now time.Time) {
+			panic("failed")
+		})
+		s.Start()
+		ss = append(ss, s)
+	}
+	<-time.NewTimer(2500 * time.Millisecond).C
+	var total schedule.Stats
+	for _, s := range ss {
+		s.Stop()
+		stats := s.(schedule.Monitor).Stats()
+		total.Executions += stats.Executions
+		total.Failures += stats.Failures
+		total.Skipped += stats.Skipped
+	}
+	assert.Equal(t, schedule.Stats{Executions: 2, Failures: 2, Skipped: 2}, total)
+}
+
+func TestJobInfo(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "job-info-test"})
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s.Add("daily").Every(1).Days().At(9, 0, 0).Starting(start).ForTenant("acme").MustDo(func(j schedule.Job, now time.Time) {})
+	assert.NoError(t, step.Step(s, start.AddDate(0, 0, 1), func(string, time.Time) {}))
+
+	// a job can be handed out without its mutations
+	var info schedule.JobInfo = s.List()[0]
+	assert := assert.New(t)
+	assert.Equal("daily", info.Name())
+	assert.Equal("acme", info.Tenant())
+	assert.Equal(time.Date(2023, time.January, 1, 9, 0, 0, 0, time.UTC), info.LastRun().UTC())
+	assert.Equal(time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC), info.NextRun().UTC())
+	assert.Equal(1, info.Stats().RunCount)
+	spec := info.Definition()
+	assert.Equal(schedule.Days, spec.Interval)
+	assert.Equal(9, spec.Hour)
+	assert.Equal("acme", spec.Tenant)
+}
+
+func TestDescribe(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "describe-test"})
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	noop := func(j schedule.Job, now time.Time) {}
+	s.Add("weekly").Every(2).Weeks().On(int(time.Monday), int(time.Wednesday), int(time.Friday)).At(9, 30, 0).Starting(start).MustDo(noop)
+	s.Add("occurrence").Every(1).Months().OnWeekdayOccurrence(time.Tuesday, 2).At(8, 0, 0).Starting(start).MustDo(noop)
+	s.Add("yearly").Every(1).Years().In(time.February).On(28).At(12, 0, 0).Starting(start).MustDo(noop)
+	s.Add("once").Once().Starting(start.Add(time.Hour)).MustDo(noop)
+	s.Add("minutely").Every(5).Minutes().Starting(start).MustDo(noop)
+
+	schedule.RegisterCatalog("de", schedule.Catalog{
+		Sentences:  map[schedule.IntervalType]string{schedule.Minutes: "alle {every}"},
+		Units:      map[schedule.IntervalType][2]string{schedule.Minutes: {"Minute", "Minuten"}},
+		Every:      "%d %s",
+		TimeLayout: "15:04",
+	})
+	descriptions := map[string]string{}
+	for _, j := range s.List() {
+		descriptions[j.Name()] = j.Description()
+	}
+	minutely := s.List()[4]
+
+	assert := assert.New(t)
+	assert.Equal(map[string]string{
+		"weekly":     "every 2 weeks on Monday, Wednesday and Friday at 09:30:00",
+		"occurrence": "every month on the second Tuesday at 08:00:00",
+		"yearly":     "every year on day 28 of February at 12:00:00",
+		"once":       "once on 2023-01-01 at 01:00:00",
+		"minutely":   "every 5 minutes",
+	}, descriptions)
+	assert.Equal("alle 5 Minuten", minutely.Describe("de-DE"), "the catalog of the language of the locale is used")
+	assert.Equal("every 5 minutes", minutely.Describe("fr"), "the descriptions fall back on english")
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	for input, expected := range map[string]schedule.JobSpec{
+		"every 2 weeks on monday and thursday at 9:30am": {Every: 2, Interval: schedule.Weeks, Day: 1, Days: []int{4}, Hour: 9, Minute: 30},
+		"every Friday at 5pm":                            {Every: 1, Interval: schedule.Weeks, Day: 5, Hour: 17},
+		"every other month on the 15th at noon 3 times":  {Every: 2, Interval: schedule.Months, Day: 15, Hour: 12, Times: 3},
+		"every year in feb on the 28th at 12:00:30 am":   {Every: 1, Interval: schedule.Years, Month: time.February, Day: 28, Second: 30},
+		"every weekday at 21:15":                         {Every: 1, Interval: schedule.Weekdays, Hour: 21, Minute: 15},
+		"every 15 minutes until 2024-12-31": {Every: 15, Interval: schedule.Minutes,
+			Until: time.Date(2024, time.December, 31, 23, 59, 59, 0, time.Local)},
+		"once on 2024-01-02 at 9:00": {Interval: schedule.Once, Starting: time.Date(2024, time.January, 2, 9, 0, 0, 0, time.Local)},
+	} {
+		spec, err := schedule.Parse(input)
+		if assert.NoError(err, input) {
+			assert.Equal(expected, spec, input)
+		}
+	}
+	for _, input := range []string{"daily", "every fortnight", "every month at 9", "every day on monday", "every day at 25:00", "every day at 13pm", "once at 9"} {
+		_, err := schedule.Parse(input)
+		assert.Error(err, input)
+	}
+
+	// the descriptions of the jobs can be parsed back into their specs
+	s := schedule.MustNew(&schedule.Config{Name: "parse-test"})
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.Local)
+	noop := func(j schedule.Job, now time.Time) {}
+	s.Add("weekly").Every(2).Weeks().On(int(time.Monday), int(time.Wednesday), int(time.Friday)).At(9, 30, 0).Starting(start).MustDo(noop)
+	s.Add("yearly").Every(1).Years().In(time.February).On(28).At(12, 0, 0).Starting(start).MustDo(noop)
+	for _, j := range s.List() {
+		spec, err := schedule.Parse(j.Description())
+		if assert.NoError(err) {
+			spec.Name, spec.Starting, spec.Timezone = j.Name(), start, start.Location()
+			assert.Equal(j.Definition(), spec)
+		}
+	}
+}
+
+func TestJobStats(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "job-stats-test", Store: store})
+	var runs int
+	s.Add("flaky").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {
+		runs++
+		if runs == 1 {
+			panic("failed")
+		}
+	})
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s.Stop()
+
+	// the counters survive a restart
+	s = schedule.MustNew(&schedule.Config{Name: "job-stats-test", Store: store})
+	s.Add("flaky").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {})
+	stats := s.List()[0].Stats()
+	assert := assert.New(t)
+	assert.Equal(2, stats.RunCount)
+	assert.Equal(1, stats.FailureCount)
+	assert.Equal("failed", stats.LastError)
+	assert.NotZero(stats.LastDuration, "the duration of the last execution is stored")
+}
+
+func TestEvents(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "events-test"})
+	var runs int
+	s.Add("flaky").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {
+		runs++
+		if runs == 1 {
+			panic("failed")
+		}
+	})
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s.Stop()
+
+	var types []schedule.EventType
+	for len(s.(schedule.Monitor).Events()) > 0 {
+		types = append(types, (<-s.(schedule.Monitor).Events()).Type)
+	}
+	assert.Equal(t, []schedule.EventType{
+		schedule.JobScheduled,
+		schedule.JobStarted,
+		schedule.JobFailed,
+		schedule.JobStarted,
+		schedule.JobFinished,
+	}, types)
+}
+
+func TestWebhook(t *testing.T) {
+	// the first request fails and is retried
+	var requests int
+	notifications := make(chan schedule.Notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var n schedule.Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		notifications <- n
+	}))
+	defer server.Close()
+
+	s := schedule.WithNotifications(schedule.MustNew(&schedule.Config{Name: "webhook-test"}), &schedule.Webhook{
+		URL:   server.URL,
+		Retry: schedule.RetryPolicy{Attempts: 2},
+	}, time.Minute)
+	s.Add("1-second").Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {})
+	s.Start()
+	defer s.Stop()
+	select {
+	case n := <-notifications:
+		assert.Equal(t, "1-second", n.Job)
+		assert.Equal(t, schedule.Succeeded, n.Outcome)
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Error("the notification was not posted")
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := schedule.Config{
+		Name:      "state-file-test",
+		StateFile: filepath.Join(dir, "state.json"),
+	}
+
+	// the runs of a job are kept across restarts
+	var runs int
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		s := schedule.MustNew(&config)
+		s.Add("1-second").Every(1).Seconds().Starting(start).Times(2).Do(func(j schedule.Job, now time.Time) {
+			runs++
+		})
+		s.Start()
+		<-time.NewTimer(2500 * time.Millisecond).C
+		s.Stop()
+	}
+	assert.Equal(t, 2, runs, "the job does not run more than 2 times")
+}
+
+func TestTriggeredBy(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "trigger-test"})
+	trigger := schedule.NewHTTPTrigger()
+	var runs int
+	assert.NoError(t, s.Add("import").Every(1).Years().In(time.January).On(1).At(0, 0, 0).Starting(time.Now()).TriggeredBy(trigger).Do(func(j schedule.Job, now time.Time) {
+		runs++
+	}))
+	srv := httptest.NewServer(trigger)
+	defer srv.Close()
+	s.Start()
+	for i := 0; i < 2; i++ {
+		res, err := http.Post(srv.URL, "text/plain", nil)
+		if assert.NoError(t, err) {
+			res.Body.Close()
+			assert.Equal(t, http.StatusAccepted, res.StatusCode)
+		}
+		<-time.NewTimer(10 * time.Millisecond).C
+	}
+	s.Stop()
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, 2, s.List()[0].Stats().RunCount)
+}
+
+func TestSpec(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "spec-test"})
+	var runs int
+	assert.NoError(t, s.Add("every").Spec("@every 1s").Times(2).Do(func(j schedule.Job, now time.Time) {
+		runs++
+	}))
+	assert.Error(t, s.Add("unknown").Spec("@fortnightly").Do(func(j schedule.Job, now time.Time) {}))
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s.Stop()
+	assert.Equal(t, 2, runs)
+}
+
+func TestStartingNow(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "starting-now-test"})
+	var mu sync.Mutex
+	runs := map[string]int{}
+	count := func(j schedule.Job, now time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs[j.Name()]++
+	}
+	s.Add("default").Every(1).Seconds().Times(2).MustDo(count)
+	s.Add("explicit").Every(1).Seconds().StartingNow().Times(2).MustDo(count)
+	s.Add("once").Once().MustDo(count)
+	s.Start()
+	<-time.NewTimer(3500 * time.Millisecond).C
+	s.Stop()
+	assert.Equal(t, map[string]int{"default": 2, "explicit": 2, "once": 1}, runs)
+}
+
+func TestMilliseconds(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "milliseconds-test", Granularity: 10 * time.Millisecond})
+	var runs int32
+	s.Add("poll").Every(100).Milliseconds().Times(5).MustDo(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	s.Start()
+	<-time.NewTimer(time.Second).C
+	s.Stop()
+	assert.Equal(t, int32(5), atomic.LoadInt32(&runs))
+
+	// the interval can not be shorter than the granularity
+	coarse := schedule.MustNew(&schedule.Config{Name: "coarse-test"})
+	assert.Error(t, coarse.Add("poll").Every(100).Milliseconds().Do(func(j schedule.Job, now time.Time) {}))
+}
+
+func TestOffset(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "offset-test"})
+	start := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	s.Add("hourly").Every(1).Hours().Starting(start).Offset(10 * time.Minute).MustDo(func(j schedule.Job, now time.Time) {})
+	s.Add("weekly").Every(1).Weeks().On(int(time.Monday)).At(0, 0, 0).Starting(start).Offset(3 * time.Hour).MustDo(func(j schedule.Job, now time.Time) {})
+	var next []time.Time
+	for _, j := range s.List() {
+		var v struct {
+			NextRunAt time.Time `json:"next_run_at"`
+		}
+		b, err := json.Marshal(j)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(b, &v))
+		next = append(next, v.NextRunAt)
+	}
+	assert.Equal(t, []time.Time{start.Add(70 * time.Minute), start.Add(3 * time.Hour)}, next)
+	assert.Error(t, s.Add("negative").Every(1).Hours().Starting(start).Offset(-time.Minute).Do(func(j schedule.Job, now time.Time) {}))
+}
+
+func TestAligned(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "aligned-test"})
+	start := time.Date(2020, 1, 6, 10, 3, 17, 0, time.UTC)
+	s.Add("aligned").Every(5).Minutes().Starting(start).Aligned().MustDo(func(j schedule.Job, now time.Time) {})
+	var v struct {
+		NextRunAt time.Time `json:"next_run_at"`
+	}
+	b, err := json.Marshal(s.List()[0])
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &v))
+	assert.Equal(t, time.Date(2020, 1, 6, 10, 5, 0, 0, time.UTC), v.NextRunAt, "the job runs on a multiple of 5 minutes")
+	assert.Error(t, s.Add("daily").Every(1).Days().At(9, 0, 0).Starting(start).Aligned().Do(func(j schedule.Job, now time.Time) {}))
+}
+
+func TestOnMissingDay(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "missing-day-test"})
+	start := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	noop := func(j schedule.Job, now time.Time) {}
+	s.Add("roll-over").Every(1).Months().On(31).At(9, 0, 0).Starting(start).MustDo(noop)
+	s.Add("clamp").Every(1).Months().On(31).At(9, 0, 0).Starting(start).OnMissingDay(schedule.ClampMissingDay).MustDo(noop)
+	s.Add("skip").Every(1).Months().On(31).At(9, 0, 0).Starting(start).OnMissingDay(schedule.SkipMissingDay).MustDo(noop)
+	s.Add("leap-day").Every(1).Years().In(time.February).On(29).At(9, 0, 0).Starting(start).OnMissingDay(schedule.ClampMissingDay).MustDo(noop)
+	var next []time.Time
+	for _, j := range s.List() {
+		var v struct {
+			NextRunAt time.Time `json:"next_run_at"`
+		}
+		b, err := json.Marshal(j)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(b, &v))
+		next = append(next, v.NextRunAt)
+	}
+	assert.Equal(t, []time.Time{
+		time.Date(2021, 3, 3, 9, 0, 0, 0, time.UTC),
+		time.Date(2021, 2, 28, 9, 0, 0, 0, time.UTC),
+		time.Date(2021, 3, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2021, 2, 28, 9, 0, 0, 0, time.UTC),
+	}, next)
+	assert.Error(t, s.Add("daily").Every(1).Days().At(9, 0, 0).OnMissingDay(schedule.ClampMissingDay).Do(noop))
+
+	// a schedule whose months never have its day is rejected instead of searched forever
+	err := s.Add("february-30").Every(1).Years().In(time.February).On(30).At(9, 0, 0).Starting(start).OnMissingDay(schedule.SkipMissingDay).Do(noop)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "never runs")
+	}
+	assert.Error(t, s.Add("every-april-31").Every(12).Months().On(31).At(9, 0, 0).Starting(time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)).OnMissingDay(schedule.SkipMissingDay).Do(noop))
+	assert.Error(t, s.Add("leap-day-every-4").Every(4).Years().In(time.February).On(29).At(9, 0, 0).Starting(start.AddDate(1, 0, 0)).OnMissingDay(schedule.SkipMissingDay).Do(noop))
+}
+
+func TestScheduleAll(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	s := schedule.MustNew(&schedule.Config{Name: "schedule-all-test", Store: store})
+	noop := func(j schedule.Job, now time.Time) {}
+	var specs []schedule.JobSpec
+	for i := 0; i < 100; i++ {
+		specs = append(specs, schedule.JobSpec{Name: fmt.Sprintf("job-%d", i), Every: 1, Interval: schedule.Minutes, Do: noop})
+	}
+	assert.NoError(t, s.(schedule.SpecAdder).ScheduleAll(specs))
+	assert.Len(t, s.List(), 100)
+	assert.Len(t, store.Records("schedule-all-test"), 100)
+
+	// nothing is added if a spec is invalid
+	assert.Error(t, s.(schedule.SpecAdder).ScheduleAll([]schedule.JobSpec{
+		{Name: "valid", Every: 1, Interval: schedule.Minutes, Do: noop},
+		{Name: "invalid", Every: 1, Interval: "fortnights", Do: noop},
+	}))
+	assert.Len(t, s.List(), 100)
+}
+
+func TestLoadCrontab(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "crontab-test"})
+	noop := func(schedule.Job, time.Time) {}
+	registry := map[string]schedule.TaskFunc{
+		"/usr/bin/cleanup":         noop,
+		"/usr/bin/report --weekly": noop,
+		"/usr/bin/heartbeat":       noop,
+		"/usr/bin/rotate":          noop,
+	}
+	assert.NoError(t, s.(schedule.Crontabber).LoadCrontab(strings.NewReader(`
+# legacy jobs
+MAILTO=ops@example.com
+30 2 * * *   /usr/bin/cleanup
+0 9 * * 1,3,5 /usr/bin/report --weekly
+@every 15m /usr/bin/heartbeat
+@monthly /usr/bin/rotate
+`), registry))
+	var intervals []schedule.IntervalType
+	for _, j := range s.List() {
+		intervals = append(intervals, j.Interval())
+	}
+	assert.Equal(t, []schedule.IntervalType{schedule.Days, schedule.Weeks, schedule.Minutes, schedule.Months}, intervals)
+
+	// unknown commands and unsupported schedules are errors
+	assert.Error(t, s.(schedule.Crontabber).LoadCrontab(strings.NewReader("* * * * * /usr/bin/unknown"), registry))
+	assert.Error(t, s.(schedule.Crontabber).LoadCrontab(strings.NewReader("0 9-17 * * * /usr/bin/cleanup"), registry))
+
+	// the lines that run the same command are named apart, and nothing is added if a line is invalid
+	s = schedule.MustNew(&schedule.Config{Name: "crontab-test"})
+	assert.Error(t, s.(schedule.Crontabber).LoadCrontab(strings.NewReader(`
+0 2 * * * /usr/bin/cleanup
+0 9-17 * * * /usr/bin/report --weekly
+`), registry))
+	assert.Empty(t, s.List(), "the lines before the invalid one are not added")
+	assert.NoError(t, s.(schedule.Crontabber).LoadCrontab(strings.NewReader(`
+0 2 * * * /usr/bin/cleanup
+0 14 * * * /usr/bin/cleanup
+@hourly /usr/bin/heartbeat
+`), registry))
+	var names []string
+	for _, j := range s.List() {
+		names = append(names, j.Name())
+	}
+	assert.Equal(t, []string{"/usr/bin/cleanup (line 2)", "/usr/bin/cleanup (line 3)", "/usr/bin/heartbeat"}, names)
+}
+
+func TestExportCrontab(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "export-test"})
+	noop := func(schedule.Job, time.Time) {}
+	start := time.Date(2020, time.January, 1, 0, 5, 0, 0, time.Local)
+	s.Add("heartbeat").Every(15).Minutes().Starting(start).Do(noop)
+	s.Add("cleanup").Every(1).Days().At(2, 30, 0).Starting(start).Do(noop)
+	s.Add("report").Every(1).Weeks().On(1, 3, 5).At(9, 0, 0).Starting(start).Do(noop)
+	s.Add("audit").Every(3).Days().At(0, 0, 0).Starting(start).Do(noop)
+	var b strings.Builder
+	assert.NoError(t, s.(schedule.Crontabber).ExportCrontab(&b))
+	assert.Equal(t, `5-59/15 * * * * heartbeat
+30 2 * * * cleanup
+0 9 * * 1,3,5 report
+# audit: every 3 days can not be expressed in a crontab
+`, b.String())
+}
+
+func TestJSON(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "json-test"})
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s.Add("report").Every(1).Weeks().On(1, 3, 5).At(9, 30, 0).Starting(start).Times(10).Do(func(schedule.Job, time.Time) {})
+	b, err := json.Marshal(s.List()[0])
+	assert.NoError(t, err)
+
+	// a marshaled job can be scheduled again
+	var spec schedule.JobSpec
+	assert.NoError(t, json.Unmarshal(b, &spec))
+	assert.Equal(t, "report", spec.Name)
+	assert.Equal(t, schedule.Weeks, spec.Interval)
+	assert.Equal(t, 1, spec.Day)
+	assert.Equal(t, []int{3, 5}, spec.Days)
+	assert.Equal(t, 9, spec.Hour)
+	assert.Equal(t, 30, spec.Minute)
+	assert.Equal(t, 10, spec.Times)
+	assert.True(t, start.Equal(spec.Starting))
+	assert.Equal(t, time.UTC, spec.Timezone)
+	spec.Do = func(schedule.Job, time.Time) {}
+	assert.NoError(t, schedule.MustNew(&schedule.Config{Name: "json-copy-test"}).(schedule.SpecAdder).Schedule(spec))
+}
+
+func TestClone(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "clone-test", Workers: 1})
+	var runs []string
+	s.Add("sync-template").Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {})
+	for _, customer := range []string{"acme", "globex"} {
+		customer := customer
+		assert.NoError(t, s.List()[0].Clone("sync-"+customer).ForTenant(customer).Do(func(j schedule.Job, now time.Time) {
+			runs = append(runs, j.Tenant())
+		}))
+	}
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	s.Stop()
+	assert.Equal(t, []string{"acme", "globex"}, runs)
+	assert.Equal(t, schedule.Seconds, s.List()[2].Interval())
+}
+
+func TestValidation(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "validation-test"})
+	noop := func(schedule.Job, time.Time) {}
+	err := s.Add("invalid").Every(0).Weeks().On(9).At(25, 0, 0).Starting(time.Now()).Do(noop)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Every(0)")
+		assert.Contains(t, err.Error(), "time.Weekday")
+		assert.Contains(t, err.Error(), "25:00:00")
+	}
+	assert.Empty(t, s.List())
+	assert.Panics(t, func() {
+		s.Add("must").Every(-1).Seconds().Starting(time.Now()).MustDo(noop)
+	})
+
+	// the amount passed in is left as it is
+	amounts := []int{0}
+	assert.Error(t, s.Add("variadic").Every(amounts...).Seconds().Starting(time.Now()).Do(noop))
+	assert.Equal(t, []int{0}, amounts)
+}
+
+func TestAddOrReplace(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "replace-test"})
+	var runs int
+	do := func(j schedule.Job, now time.Time) {
+		runs++
+	}
+	assert.NoError(t, s.Add("sync").Every(1).Years().In(time.January).On(1).At(0, 0, 0).Starting(time.Now()).Do(do))
+	assert.Error(t, s.Add("sync").Every(1).Seconds().Starting(time.Now()).Do(do), "duplicate names are still an error")
+	assert.NoError(t, s.(schedule.Replacer).AddOrReplace("sync").Every(1).Seconds().Starting(time.Now()).Times(1).Do(do))
+	assert.Len(t, s.List(), 1)
+	assert.Equal(t, schedule.Seconds, s.List()[0].Interval())
+	s.Start()
+	<-time.NewTimer(1500 * time.Millisecond).C
+	s.Stop()
+	assert.Equal(t, 1, runs)
+}
+
+func TestConcurrentJobs(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "concurrent-test"})
+	s.Start()
+	defer s.Stop()
+
+	// add, list and remove jobs while the scheduler is running
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			assert.NoError(t, s.Add(name).Every(1).Seconds().Starting(time.Now()).Do(func(schedule.Job, time.Time) {}))
+			s.List()
+			assert.NoError(t, s.(schedule.Remover).Remove(name))
+		}(fmt.Sprintf("job-%d", i))
+	}
+	wg.Wait()
+	assert.Empty(t, s.List())
+	assert.Error(t, s.(schedule.Remover).Remove("job-0"))
+}
+
+func TestWorkers(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "workers-test"})
+	var runs int32
+	s.Add("slow").Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {
+		<-time.NewTimer(3 * time.Second).C
+	})
+	s.Add("fast").Every(1).Seconds().Starting(time.Now()).Times(2).Do(func(j schedule.Job, now time.Time) {
+		atomic.AddInt32(&runs, 1)
+	})
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs), "a slow job does not delay the other jobs")
+	assert.Equal(t, 1, s.(schedule.Monitor).State().Executing)
+	s.Stop()
+}
+
+func TestQueuePolicy(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{
+		Name:        "queue-test",
+		Workers:     1,
+		QueueSize:   1,
+		QueuePolicy: schedule.RejectNew,
+	})
+	noop := func(schedule.Job, time.Time) {}
+	s.Add("slow").Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {
+		<-time.NewTimer(2 * time.Second).C
+	})
+	s.Add("a").Every(1).Seconds().Starting(time.Now()).Do(noop)
+	s.Add("b").Every(1).Seconds().Starting(time.Now()).Do(noop)
+	s.Start()
+	<-time.NewTimer(2500 * time.Millisecond).C
+	s.Stop()
+	assert.NotZero(t, s.(schedule.Monitor).Stats().Dropped, "the jobs that do not fit in the queue are dropped")
+}
+
+func TestSharding(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	var mu sync.Mutex
+	owners := map[string]string{}
+	var schedulers []schedule.Scheduler
+	for _, instance := range []string{"a", "b"} {
+		s := schedule.MustNew(&schedule.Config{Name: "sharding-test", Store: store, Sharding: true, InstanceID: instance})
+		for i := 0; i < 10; i++ {
+			instance := instance
+			s.Add(fmt.Sprintf("job-%d", i)).Every(1).Seconds().Starting(time.Now()).Times(1).Do(func(j schedule.Job, now time.Time) {
+				mu.Lock()
+				defer mu.Unlock()
+				owners[j.Name()] = instance
+			})
+		}
+		schedulers = append(schedulers, s)
+	}
+	for _, s := range schedulers {
+		s.Start()
+	}
+	<-time.NewTimer(1500 * time.Millisecond).C
+	for _, s := range schedulers {
+		s.Stop()
+	}
+
+	// every job is executed once by the instance that owns it
+	assert.Len(t, owners, 10)
+	var a int
+	for _, instance := range owners {
+		if instance == "a" {
+			a++
+		}
+	}
+	assert.True(t, a > 0 && a < 10, "the jobs are spread over the instances")
+	for i := 0; i < 10; i++ {
+		assert.Len(t, store.Executions("sharding-test", fmt.Sprintf("job-%d", i)), 1)
+	}
+}
+
+func TestShardingRequiresMembership(t *testing.T) {
+	_, err := schedule.New(&schedule.Config{Name: "sharding-test", Store: schedule.NoopStore{}, Sharding: true})
+	assert.Error(t, err, "sharding with a store that does not implement Membership")
+	_, err = schedule.New(&schedule.Config{Name: "sharding-test", Store: schedule.NewRecordingStore(), Sharding: true})
+	assert.NoError(t, err)
+	_, err = schedule.New(&schedule.Config{Name: "sharding-test", Database: "test", Sharding: true})
+	assert.NoError(t, err, "the database store implements Membership")
+}
+
+func TestPinTo(t *testing.T) {
+	store := schedule.NewRecordingStore()
+	var mu sync.Mutex
+	var instances []string
+	var schedulers []schedule.Scheduler
+	for _, cfg := range []*schedule.Config{
+		{Name: "pin-test", Store: store, InstanceID: "a"},
+		{Name: "pin-test", Store: store, InstanceID: "b", Tags: []string{"gpu"}},
+	} {
+		instance := cfg.InstanceID
+		s := schedule.MustNew(cfg)
+		s.Add("train").Every(1).Seconds().Starting(time.Now()).Times(2).PinTo("gpu").Do(func(j schedule.Job, now time.Time) {
+			mu.Lock()
+			defer mu.Unlock()
+			instances = append(instances, instance)
+		})
+		s.Start()
+		schedulers = append(schedulers, s)
+	}
+	<-time.NewTimer(2500 * time.Millisecond).C
+	for _, s := range schedulers {
+		s.Stop()
+	}
+	assert.Equal(t, []string{"b", "b"}, instances, "the job only runs on the instance with the tag")
+	assert.Len(t, schedulers[0].List(), 1, "the job is still listed by every instance")
+}
+
+func TestOnWeekday(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "on-weekday-test"})
+	noop := func(schedule.Job, time.Time) {}
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.Add("report").Every(1).Weeks().OnWeekday(time.Monday, time.Wednesday, time.Friday).At(9, 0, 0).Starting(start).Do(noop))
+	spec := s.List()[0].Definition()
+	assert.Equal(t, int(time.Monday), spec.Day)
+	assert.Equal(t, []int{int(time.Wednesday), int(time.Friday)}, spec.Days)
+	assert.Equal(t, time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC), s.List()[0].NextRun())
+
+	// the weekdays are only for weekly jobs
+	err := s.Add("monthly").Every(1).Months().OnWeekday(time.Monday).At(9, 0, 0).Starting(start).Do(noop)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "OnWeekday")
+	}
+	assert.Error(t, s.Add("invalid").Every(1).Weeks().OnWeekday(time.Weekday(7)).At(9, 0, 0).Starting(start).Do(noop))
+}
+
+func TestAtClock(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "at-clock-test"})
+	noop := func(schedule.Job, time.Time) {}
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.Add("standup").Every(1).Weekdays().AtClock("09:30").Starting(start).Do(noop))
+	assert.NoError(t, s.Add("backup").Every(1).Days().AtClock("23:15:30").Starting(start).Do(noop))
+	assert.Equal(t, time.Date(2023, time.January, 2, 9, 30, 0, 0, time.UTC), s.List()[0].NextRun())
+	assert.Equal(t, time.Date(2023, time.January, 1, 23, 15, 30, 0, time.UTC), s.List()[1].NextRun())
+	for _, clock := range []string{"", "9h30", "24:00", "09:60", "9:30pm"} {
+		assert.Error(t, s.Add("invalid").Every(1).Days().AtClock(clock).Starting(start).Do(noop), clock)
+	}
+}
+
+func TestAlso(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "also-test"})
+	start := time.Date(2023, time.January, 30, 0, 0, 0, 0, time.UTC)
+	var ran []time.Time
+	assert.NoError(t, s.Add("report").Every(1).Days().At(9, 0, 0).Starting(start).Also(func(a schedule.Amount) schedule.Starting {
+		return a.Every(1).Months().On(1).At(0, 0, 0)
+	}).Do(func(j schedule.Job, now time.Time) {}))
+
+	// the job runs at the earliest time that any of its schedules is due
+	assert.NoError(t, step.Step(s, time.Date(2023, time.February, 2, 0, 0, 0, 0, time.UTC), func(name string, at time.Time) {
+		ran = append(ran, at)
+	}))
+	assert.Equal(t, []time.Time{
+		time.Date(2023, time.January, 30, 9, 0, 0, 0, time.UTC),
+		time.Date(2023, time.January, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 1, 9, 0, 0, 0, time.UTC),
+	}, ran)
+	assert.Equal(t, "every day at 09:00:00 and every month on day 1 at 00:00:00", s.List()[0].Description())
+
+	// the rules must be valid recurring schedules
+	noop := func(schedule.Job, time.Time) {}
+	err := s.Add("invalid").Every(1).Days().At(9, 0, 0).Starting(start).Also(func(a schedule.Amount) schedule.Starting {
+		return a.Every(1).Weeks().On(9).At(0, 0, 0)
+	}).Do(noop)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Also: day must be a valid time.Weekday")
+	}
+	assert.Error(t, s.Add("once").Once().Starting(start).Also(func(a schedule.Amount) schedule.Starting {
+		return a.Every(1).Hours()
+	}).Do(noop))
+	assert.Error(t, s.Add("nil").Every(1).Hours().Starting(start).Also(func(a schedule.Amount) schedule.Starting { return nil }).Do(noop))
+}
+
+func TestOnWeekdayOccurrence(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "weekday-occurrence-test"})
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	noop := func(schedule.Job, time.Time) {}
+	s.Add("second-tuesday").Every(1).Months().OnWeekdayOccurrence(time.Tuesday, 2).At(8, 0, 0).Starting(start).MustDo(noop)
+	s.Add("last-friday").Every(1).Months().OnWeekdayOccurrence(time.Friday, -1).At(17, 0, 0).Starting(start).MustDo(noop)
+	s.Add("thanksgiving").Every(1).Years().In(time.November).OnWeekdayOccurrence(time.Thursday, 4).At(12, 0, 0).Starting(start).MustDo(noop)
+	runs := map[string][]time.Time{}
+	assert.NoError(t, step.Step(s, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), func(name string, at time.Time) {
+		runs[name] = append(runs[name], at)
+	}))
+	assert.Equal(t, []time.Time{
+		time.Date(2023, time.January, 10, 8, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 14, 8, 0, 0, 0, time.UTC),
+	}, runs["second-tuesday"][:2])
+	assert.Equal(t, time.Date(2024, time.February, 13, 8, 0, 0, 0, time.UTC), runs["second-tuesday"][13])
+	assert.Equal(t, []time.Time{
+		time.Date(2023, time.January, 27, 17, 0, 0, 0, time.UTC),
+		time.Date(2023, time.February, 24, 17, 0, 0, 0, time.UTC),
+	}, runs["last-friday"][:2])
+	assert.Equal(t, []time.Time{time.Date(2023, time.November, 23, 12, 0, 0, 0, time.UTC)}, runs["thanksgiving"])
+
+	// a fifth weekday that none of the months of the schedule has is rejected instead of searched forever
+	err := s.Add("fifth-monday").Every(24).Months().OnWeekdayOccurrence(time.Monday, 5).At(9, 0, 0).Starting(time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)).Do(noop)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "never runs")
+	}
+	assert.Error(t, s.Add("sixth").Every(1).Months().OnWeekdayOccurrence(time.Monday, 6).At(9, 0, 0).Starting(start).Do(noop))
+	assert.Error(t, s.Add("weekly").Every(1).Weeks().OnWeekdayOccurrence(time.Monday, 1).At(9, 0, 0).Starting(start).Do(noop))
+}
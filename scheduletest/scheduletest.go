@@ -0,0 +1,258 @@
+// Package scheduletest provides fakes of `schedule.Scheduler` and `schedule.Job`, so that the code that depends on them
+// can be unit tested without tickers or databases
+package scheduletest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/internal/step"
+)
+
+// Scheduler is a fake `schedule.Scheduler`. The jobs are added to it with the builder methods like to any scheduler
+// and are kept in memory, but it never ticks: the jobs are only executed by `Tick` and `Run`.
+// `Start` and the methods that stop it only change its status
+type Scheduler struct {
+	scheduler
+
+	// Unhealthy is the error that `Healthy` returns while the scheduler is running
+	Unhealthy error
+
+	mu     sync.Mutex
+	status schedule.Status
+}
+
+// scheduler is every optional interface of `schedule.Scheduler`, which the schedulers created by `schedule.New` implement,
+// so that the fake implements them as well
+type scheduler interface {
+	schedule.Scheduler
+	schedule.Replacer
+	schedule.Remover
+	schedule.SpecAdder
+	schedule.Crontabber
+	schedule.TenantManager
+	schedule.Toggler
+	schedule.Drainer
+	schedule.Monitor
+	schedule.Replayer
+	schedule.Historian
+}
+
+// New creates a fake `Scheduler` named `name`
+func New(name string) *Scheduler {
+	return &Scheduler{scheduler: schedule.MustNew(&schedule.Config{Name: name, Store: schedule.NoopStore{}}).(scheduler)}
+}
+
+// Tick executes every job that is due up to `now` at the time it is due, as if the scheduler was running until `now`.
+// The funcs are called synchronously, so they have all returned when it does
+func (s *Scheduler) Tick(now time.Time) error {
+	return step.Step(s.scheduler, now, func(string, time.Time) {})
+}
+
+// Run executes the job named `name` right away as if it was scheduled at `t`, whether it is due or not.
+// Like `Scheduler.Replay`, `schedule.IsReplay` reports true for the job that is passed to its func
+func (s *Scheduler) Run(name string, t time.Time) error {
+	return s.scheduler.Replay(name, t)
+}
+
+// Start sets the status of the scheduler to `schedule.Running` without starting a ticker
+func (s *Scheduler) Start() {
+	s.setStatus(schedule.Running)
+}
+
+// Stop sets the status of the scheduler to `schedule.Stopped`
+func (s *Scheduler) Stop() {
+	s.setStatus(schedule.Stopped)
+}
+
+// Drain sets the status of the scheduler to `schedule.Stopped`, since no job is ever executing in the background
+func (s *Scheduler) Drain() {
+	s.setStatus(schedule.Stopped)
+}
+
+// StopContext sets the status of the scheduler to `schedule.Stopped`
+func (s *Scheduler) StopContext(ctx context.Context) error {
+	s.setStatus(schedule.Stopped)
+	return nil
+}
+
+// IsRunning reports whether `Start` was called since the scheduler was last stopped
+func (s *Scheduler) IsRunning() bool {
+	return s.State().Status == schedule.Running
+}
+
+// State returns the status of the scheduler
+func (s *Scheduler) State() schedule.State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return schedule.State{Status: s.status}
+}
+
+// Healthy returns `Unhealthy`, or an error if the scheduler is not running
+func (s *Scheduler) Healthy(ctx context.Context) error {
+	if !s.IsRunning() {
+		return fmt.Errorf("%s is not running", s.Name())
+	}
+	return s.Unhealthy
+}
+
+// setStatus sets the status of the scheduler
+func (s *Scheduler) setStatus(status schedule.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Job is a fake `schedule.Job` to pass to the funcs under test. Every method returns the field that is documented to be returned by it,
+// except `Clone`, which panics because a fake job cannot be built upon
+type Job struct {
+	// JobName is returned by `Name`
+	JobName string
+
+	// IntervalAmount and IntervalType are returned by `Amount` and `Interval`
+	IntervalAmount int
+	IntervalType   schedule.IntervalType
+
+	// Summary is returned by `Description` and `Describe`
+	Summary string
+
+	// Owner is returned by `Scheduler`
+	Owner schedule.Scheduler
+
+	// TenantName is returned by `Tenant`
+	TenantName string
+
+	// Spec is returned by `Definition`
+	Spec schedule.JobSpec
+
+	// Due is returned by `NextRun`
+	Due time.Time
+
+	// Done is returned by `Completed`
+	Done bool
+
+	// Counters are returned by `Stats`
+	Counters schedule.JobStats
+
+	// Zone is returned by `Location`. It defaults to UTC
+	Zone *time.Location
+
+	// ScheduledAt is returned by `LastRun`, and is the time of the execution that `ExecutionKey` returns the key of
+	ScheduledAt time.Time
+
+	// Data is the payload that `Payload` unmarshals, after it was marshaled to JSON like the payloads that are stored
+	Data interface{}
+
+	// Ctx is returned by `Context`. It defaults to `context.Background`
+	Ctx context.Context
+
+	// Replayed is returned by `IsReplay`
+	Replayed bool
+}
+
+// Name implements `schedule.Job`
+func (j *Job) Name() string {
+	return j.JobName
+}
+
+// Amount implements `schedule.Job`
+func (j *Job) Amount() int {
+	return j.IntervalAmount
+}
+
+// Interval implements `schedule.Job`
+func (j *Job) Interval() schedule.IntervalType {
+	return j.IntervalType
+}
+
+// Description implements `schedule.Job`
+func (j *Job) Description() string {
+	return j.Summary
+}
+
+// Describe implements `schedule.Job`
+func (j *Job) Describe(locale string) string {
+	return j.Summary
+}
+
+// Scheduler implements `schedule.Job`
+func (j *Job) Scheduler() schedule.Scheduler {
+	return j.Owner
+}
+
+// Tenant implements `schedule.Job`
+func (j *Job) Tenant() string {
+	return j.TenantName
+}
+
+// Definition implements `schedule.Job`
+func (j *Job) Definition() schedule.JobSpec {
+	return j.Spec
+}
+
+// NextRun implements `schedule.Job`
+func (j *Job) NextRun() time.Time {
+	return j.Due
+}
+
+// LastRun implements `schedule.Job`
+func (j *Job) LastRun() time.Time {
+	return j.ScheduledAt
+}
+
+// Completed implements `schedule.Job`
+func (j *Job) Completed() bool {
+	return j.Done
+}
+
+// Stats implements `schedule.Job`
+func (j *Job) Stats() schedule.JobStats {
+	return j.Counters
+}
+
+// Clone implements `schedule.Job`, but it panics
+func (j *Job) Clone(name string) schedule.Task {
+	panic("scheduletest: a fake job cannot be cloned")
+}
+
+// Location implements `schedule.Job`
+func (j *Job) Location() *time.Location {
+	if j.Zone == nil {
+		return time.UTC
+	}
+	return j.Zone
+}
+
+// ExecutionKey implements `schedule.Job`
+func (j *Job) ExecutionKey() string {
+	return schedule.ExecutionKey(j.JobName, j.ScheduledAt)
+}
+
+// Context implements `schedule.Job`
+func (j *Job) Context() context.Context {
+	if j.Ctx == nil {
+		return context.Background()
+	}
+	return j.Ctx
+}
+
+// Payload implements `schedule.Job`
+func (j *Job) Payload(v interface{}) error {
+	if j.Data == nil {
+		return nil
+	}
+	data, err := json.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// IsReplay implements `schedule.Job`
+func (j *Job) IsReplay() bool {
+	return j.Replayed
+}
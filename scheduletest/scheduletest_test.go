@@ -0,0 +1,76 @@
+package scheduletest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/scheduletest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	s := scheduletest.New("scheduletest-test")
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var ran []time.Time
+	var replayed bool
+	s.Add("daily").Every(1).Days().At(9, 0, 0).Starting(start).MustDo(func(j schedule.Job, now time.Time) {
+		ran = append(ran, now)
+		replayed = schedule.IsReplay(j)
+	})
+
+	// the jobs only run when the scheduler is ticked
+	assert := assert.New(t)
+	s.Start()
+	assert.True(s.IsRunning())
+	assert.NoError(s.Healthy(context.Background()))
+	s.Unhealthy = errors.New("unhealthy")
+	assert.Error(s.Healthy(context.Background()))
+	assert.NoError(s.Tick(start.AddDate(0, 0, 2)))
+	assert.Equal([]time.Time{
+		time.Date(2023, time.January, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC),
+	}, ran)
+	assert.NoError(s.Run("daily", start))
+	assert.True(replayed)
+	assert.Error(s.Run("missing", start))
+	s.Stop()
+	assert.False(s.IsRunning())
+}
+
+func TestJob(t *testing.T) {
+	type invoice struct {
+		CustomerID int
+	}
+	at := time.Date(2023, time.January, 1, 9, 0, 0, 0, time.UTC)
+	var j schedule.Job = &scheduletest.Job{JobName: "invoice-42", TenantName: "acme", ScheduledAt: at, Data: invoice{CustomerID: 42}}
+
+	var v invoice
+	assert := assert.New(t)
+	assert.NoError(j.Payload(&v))
+	assert.Equal(42, v.CustomerID)
+	assert.Equal("acme", j.Tenant())
+	assert.Equal(schedule.ExecutionKey("invoice-42", at), j.ExecutionKey())
+	assert.Equal(time.UTC, j.Location())
+	assert.Panics(func() { j.Clone("invoice-43") })
+
+	// every other method of a zero job returns its zero field
+	var zero schedule.Job = &scheduletest.Job{}
+	assert.NotPanics(func() {
+		zero.Name()
+		zero.Description()
+		zero.Describe("de")
+		zero.Definition()
+		zero.NextRun()
+		zero.LastRun()
+		zero.Completed()
+		zero.Stats()
+		zero.Amount()
+		zero.Interval()
+		zero.Scheduler()
+		zero.Context()
+		zero.IsReplay()
+	})
+}
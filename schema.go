@@ -0,0 +1,19 @@
+package schedule
+
+import "fmt"
+
+// Schema returns the DDL needed to create the tables used to synchronize a scheduler whose table is named `name`, which is its `Config.TableName`
+// or its name qualified by its `Config.Schema` and prefixed by its `Config.TablePrefix`. They are the tables that `Migrations` creates:
+// the table of its jobs, the table of its instances, see `Config.Sharding`, and its audit log, see `Historian.History`.
+// The DDL is in the given `dialect` (ie "mysql" or "postgres"), so that the tables can be created without granting DDL rights to the application
+func Schema(dialect, name string) (string, error) {
+	d, ok := sqlDialects[dialect]
+	if !ok {
+		return "", fmt.Errorf("%s is not a supported dialect", dialect)
+	}
+	var ddl string
+	for _, t := range tables {
+		ddl += t.create(d, name, 0)
+	}
+	return ddl, nil
+}
@@ -0,0 +1,88 @@
+package schedule
+
+import (
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+const (
+	// heartbeatInterval is how often a sharded scheduler tells the other instances that it is alive
+	heartbeatInterval = 10 * time.Second
+
+	// heartbeatTTL is how long an instance that stopped sending heartbeats keeps its jobs
+	heartbeatTTL = 3 * heartbeatInterval
+)
+
+// heartbeat records that this instance is alive until `expires` and refreshes the instances that share the jobs
+func (s *scheduler) heartbeat(expires time.Time) {
+	m, ok := s.store.(Membership)
+	if !ok {
+		log.Printf("schedule: %s cannot shard its jobs, its store does not implement Membership", s.name)
+		return
+	}
+	if err := m.Heartbeat(s.table, s.instance, expires); err != nil {
+		log.Printf("schedule: %s failed to send a heartbeat: %s", s.name, err)
+		return
+	}
+	members, err := m.Members(s.table, s.now())
+	if err != nil {
+		log.Printf("schedule: %s failed to list its instances: %s", s.name, err)
+		return
+	}
+	s.mu.Lock()
+	s.members = members
+	s.mu.Unlock()
+}
+
+// owns reports whether this instance executes `j`. A pinned job is only executed by the instances it is pinned to.
+// Every instance executes every other job when the jobs are not sharded, or when the instances are unknown
+func (s *scheduler) owns(j *job) bool {
+	if len(j.PinnedTo) > 0 {
+		if j.PinnedTo == s.instance {
+			return true
+		}
+		for _, tag := range s.tags {
+			if j.PinnedTo == tag {
+				return true
+			}
+		}
+		return false
+	} else if !s.sharding {
+		return true
+	}
+	s.mu.Lock()
+	members := s.members
+	s.mu.Unlock()
+	if len(members) == 0 {
+		return true
+	}
+	return owner(members, j.JobName) == s.instance
+}
+
+// owner picks the instance that owns the job named `name` with rendezvous hashing,
+// so only the jobs of an instance that joins or leaves move to another instance
+func owner(members []string, name string) string {
+	var best string
+	var max uint64
+	for _, m := range members {
+		h := fnv.New64a()
+		h.Write([]byte(m))
+		h.Write([]byte{0})
+		h.Write([]byte(name))
+		if sum := mix(h.Sum64()); len(best) == 0 || sum > max {
+			best, max = m, sum
+		}
+	}
+	return best
+}
+
+// mix spreads the bits of a fnv hash, which barely changes when only the first byte of its input does
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,77 @@
+// Package simulate steps a `schedule.Scheduler` through simulated time and records every execution it makes,
+// so that the schedules of its jobs can be verified in tests, ie a year of monthly jobs in milliseconds
+package simulate
+
+import (
+	"time"
+
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/internal/step"
+)
+
+// Execution is an execution that a simulated scheduler made
+type Execution struct {
+	// Job is the name of the job
+	Job string
+
+	// Time is the time the execution was scheduled at
+	Time time.Time
+}
+
+// Simulation is a scheduler whose clock only moves when it is advanced. The jobs are added to it like to any `schedule.Scheduler`,
+// but they should be added with `Starting`, which defaults to the real time, ie `Starting(sim.Now())`.
+// It is never started, the executions are performed by `Advance` instead
+type Simulation struct {
+	schedule.Scheduler
+	now        time.Time
+	executions []Execution
+}
+
+// New creates a `Simulation` of the scheduler configured by `cfg` whose clock starts at `start`.
+// The jobs are synchronized with the store of `cfg` like the jobs of a running scheduler, so leave it empty to use a `schedule.NoopStore`
+func New(cfg *schedule.Config, start time.Time) (*Simulation, error) {
+	s, err := schedule.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulation{Scheduler: s, now: start}, nil
+}
+
+// Now is the simulated time
+func (sim *Simulation) Now() time.Time {
+	return sim.now
+}
+
+// Advance moves the simulated clock forward by `d`, ie `30 * 24 * time.Hour`, performing every execution that comes due on the way
+func (sim *Simulation) Advance(d time.Duration) error {
+	return sim.AdvanceTo(sim.now.Add(d))
+}
+
+// AdvanceTo moves the simulated clock forward to `t`, performing every execution that comes due on the way
+func (sim *Simulation) AdvanceTo(t time.Time) error {
+	if err := step.Step(sim.Scheduler, t, func(name string, at time.Time) {
+		sim.executions = append(sim.executions, Execution{Job: name, Time: at})
+	}); err != nil {
+		return err
+	}
+	if t.After(sim.now) {
+		sim.now = t
+	}
+	return nil
+}
+
+// Executions returns every execution that was performed so far, in order
+func (sim *Simulation) Executions() []Execution {
+	return append([]Execution(nil), sim.executions...)
+}
+
+// Times returns the times of the executions of the job named `name` that were performed so far, in order
+func (sim *Simulation) Times(name string) []time.Time {
+	var ts []time.Time
+	for _, e := range sim.executions {
+		if e.Job == name {
+			ts = append(ts, e.Time)
+		}
+	}
+	return ts
+}
@@ -0,0 +1,43 @@
+package simulate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/simulate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulation(t *testing.T) {
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sim, err := simulate.New(&schedule.Config{Name: "simulate-test"}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []time.Time
+	sim.Add("month-end").Every(1).Months().On(31).At(9, 0, 0).Starting(sim.Now()).OnMissingDay(schedule.ClampMissingDay).MustDo(func(j schedule.Job, now time.Time) {
+		ran = append(ran, now)
+	})
+	sim.Add("twice").Every(1).Weeks().On(int(time.Monday)).At(8, 0, 0).Starting(sim.Now()).Times(2).MustDo(func(j schedule.Job, now time.Time) {})
+
+	// a year of executions
+	assert := assert.New(t)
+	began := time.Now()
+	assert.NoError(sim.Advance(365 * 24 * time.Hour))
+	assert.Less(int64(time.Since(began)), int64(time.Second), "the simulation does not wait for the executions")
+	assert.Equal(start.AddDate(1, 0, 0), sim.Now())
+
+	times := sim.Times("month-end")
+	if assert.Len(times, 12) {
+		assert.Equal(time.Date(2023, time.January, 31, 9, 0, 0, 0, time.UTC), times[0].UTC())
+		assert.Equal(time.Date(2023, time.February, 28, 9, 0, 0, 0, time.UTC), times[1].UTC())
+		assert.Equal(time.Date(2023, time.December, 31, 9, 0, 0, 0, time.UTC), times[11].UTC())
+	}
+	assert.Equal(times, ran, "the funcs are called with the simulated times")
+	assert.Equal([]time.Time{
+		time.Date(2023, time.January, 2, 8, 0, 0, 0, time.UTC),
+		time.Date(2023, time.January, 9, 8, 0, 0, 0, time.UTC),
+	}, sim.Times("twice"))
+	assert.Len(sim.Executions(), 14)
+}
@@ -0,0 +1,32 @@
+package schedule
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Email is a `Notifier` that sends every notification as a plain text email through an smtp server
+type Email struct {
+	// Addr is the address of the smtp server, ie "smtp.example.com:587"
+	Addr string
+
+	// Auth authenticates with the smtp server, ie `smtp.PlainAuth`. It may be nil
+	Auth smtp.Auth
+
+	// From is the sender of the emails
+	From string
+
+	// To are the recipients of the emails
+	To []string
+}
+
+// header removes the line breaks that would end a header of an email early
+var header = strings.NewReplacer("\r", "", "\n", " ")
+
+// Notify implements `Notifier`
+func (e *Email) Notify(n Notification) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		e.From, strings.Join(e.To, ", "), header.Replace(n.Subject), n.Message)
+	return smtp.SendMail(e.Addr, e.Auth, e.From, e.To, []byte(msg))
+}
@@ -0,0 +1,178 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JobSpec describes a job as data, so that jobs can be created at runtime with `SpecAdder.Schedule`,
+// ie from a configuration file or an admin endpoint
+type JobSpec struct {
+	// Name is the name of the job. It must be unique to the scheduler
+	Name string
+
+	// Every is the amount of `Interval` that will elapse between executions. It is ignored by `Once`
+	Every int
+
+	// Interval is the interval of time that will elapse between executions
+	Interval IntervalType
+
+	// Month is the month of yearly jobs
+	Month time.Month
+
+	// Day is the day of the month of yearly and monthly jobs, or the weekday of weekly jobs
+	Day int
+
+	// Days are the other weekdays of weekly jobs that run on more than one weekday
+	Days []int
+
+	// Hour, Minute and Second are the time of day of yearly, monthly, weekly, daily and weekday jobs
+	Hour, Minute, Second int
+
+	// Starting is the time we start counting. It defaults to now
+	Starting time.Time
+
+	// Timezone is the location the job is evaluated in. It defaults to the location of `Starting`
+	Timezone *time.Location
+
+	// Offset delays every execution within its interval, if it is set
+	Offset time.Duration
+
+	// Until stops the job from running after a time, if it is set
+	Until time.Time
+
+	// Times stops the job after it has run a number of times, if it is set
+	Times int
+
+	// ExpireAfter removes the job once it has been inactive for a duration, if it is set
+	ExpireAfter time.Duration
+
+	// Tenant is the tenant that the job belongs to
+	Tenant string
+
+	// PinnedTo is the instance or tag of an instance that the job is only executed on, if it is set
+	PinnedTo string
+
+	// Payload is the JSON of the payload that is attached to the job with `Task.WithPayload`, if it is set
+	Payload json.RawMessage
+
+	// Do is the func that will be executed
+	Do func(Job, time.Time)
+}
+
+// schedule builds the job described by `spec` with the builder methods of `s`, returning any misuse of the builder as an error
+func schedule(s Scheduler, spec JobSpec) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", spec.Name, r)
+		}
+	}()
+	if spec.Do == nil {
+		return fmt.Errorf("%s does not have a func", spec.Name)
+	}
+	starting := spec.Starting
+	if starting.IsZero() {
+		starting = time.Now()
+	}
+
+	// build the job
+	a := s.Add(spec.Name)
+	var st Starting
+	switch spec.Interval {
+	case Once:
+		st = a.Once()
+	case Years:
+		st = a.Every(spec.Every).Years().In(spec.Month).On(spec.Day).At(spec.Hour, spec.Minute, spec.Second)
+	case Months:
+		st = a.Every(spec.Every).Months().On(spec.Day).At(spec.Hour, spec.Minute, spec.Second)
+	case Weeks:
+		st = a.Every(spec.Every).Weeks().On(spec.Day, spec.Days...).At(spec.Hour, spec.Minute, spec.Second)
+	case Days:
+		st = a.Every(spec.Every).Days().At(spec.Hour, spec.Minute, spec.Second)
+	case Weekdays:
+		st = a.Every(spec.Every).Weekdays().At(spec.Hour, spec.Minute, spec.Second)
+	case Hours:
+		st = a.Every(spec.Every).Hours()
+	case Minutes:
+		st = a.Every(spec.Every).Minutes()
+	case Seconds:
+		st = a.Every(spec.Every).Seconds()
+	case Milliseconds:
+		st = a.Every(spec.Every).Milliseconds()
+	default:
+		return fmt.Errorf("%s has an unknown interval %q", spec.Name, spec.Interval)
+	}
+	t := st.Starting(starting)
+	if spec.Timezone != nil {
+		t = t.Timezone(spec.Timezone)
+	}
+	if spec.Offset > 0 {
+		t = t.Offset(spec.Offset)
+	}
+	if !spec.Until.IsZero() {
+		t = t.Until(spec.Until)
+	}
+	if spec.Times > 0 {
+		t = t.Times(spec.Times)
+	}
+	if spec.ExpireAfter > 0 {
+		t = t.ExpireAfter(spec.ExpireAfter)
+	}
+	if len(spec.PinnedTo) > 0 {
+		t = t.PinTo(spec.PinnedTo)
+	}
+	if len(spec.Tenant) > 0 {
+		t = t.ForTenant(spec.Tenant)
+	}
+	if len(spec.Payload) > 0 {
+		t = t.WithPayload(spec.Payload)
+	}
+	return t.Do(spec.Do)
+}
+
+// scheduleAll builds the jobs described by `specs` with the builder methods of `s` and adds them at once.
+// Nothing is added if a spec is invalid
+func scheduleAll(s Scheduler, specs []JobSpec) error {
+	b := batch{Scheduler: s}
+	for _, spec := range specs {
+		if err := schedule(&b, spec); err != nil {
+			return err
+		}
+	}
+	return b.commit()
+}
+
+// batch collects the jobs built through the `Scheduler` it embeds instead of adding them one by one
+type batch struct {
+	Scheduler
+	registrar
+	jobs []*job
+}
+
+// Add create a new job that is collected by the batch when `Do` is called
+func (b *batch) Add(name string) Amount {
+	a := b.Scheduler.Add(name)
+	if j, ok := a.(*job); ok {
+		b.registrar = j.registrar
+		j.registrar = b
+	}
+	return a
+}
+
+// add collects `j`
+func (b *batch) add(j *job) error {
+	b.jobs = append(b.jobs, j)
+	return nil
+}
+
+// commit adds the collected jobs to the scheduler at once
+func (b *batch) commit() error {
+	if len(b.jobs) == 0 {
+		return nil
+	}
+	for _, j := range b.jobs {
+		j.registrar = b.registrar
+	}
+	return b.registrar.addAll(b.jobs)
+}
@@ -0,0 +1,951 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// SQLStore is a `Store` that only depends on `database/sql`, for applications that do not use gorm.
+// Build with the `nogorm` tag to leave gorm out of the binary entirely.
+// Note: the mysql driver must be opened with parseTime=true
+type SQLStore struct {
+	// ClaimStrategy is how executions are claimed. It must be set before the store is used
+	ClaimStrategy ClaimStrategy
+
+	// AutoMigrate creates the tables of each scheduler the first time they are used. Otherwise they must be created
+	// with `Migrate`. It must be set before the store is used
+	AutoMigrate bool
+
+	// LockTimeout is how long a claim waits for the lock of a job that another instance holds before it gives up the execution.
+	// Zero waits for as long as the database does with `PessimisticLocking`, and for 10 seconds with `AdvisoryLocking`,
+	// since an advisory lock could otherwise be waited for forever. It must be set before the store is used
+	LockTimeout time.Duration
+
+	// SkipLocked makes a claim give up the execution right away when another instance holds the lock of the job,
+	// with `SKIP LOCKED` on mysql 8 and postgres 9.5 or later. It must be set before the store is used
+	SkipLocked bool
+
+	db       *sql.DB
+	dialect  sqlDialect
+	mu       sync.Mutex
+	migrated map[string]bool
+	members  map[string]bool
+	audited  map[string]bool
+}
+
+// NewSQLStore creates a `SQLStore` that uses `db`. The `dialect` is "mysql", "postgres" or "sqlite3".
+// The tables of the schedulers are created with `Migrate`, or the first time they are used if `AutoMigrate` is set
+func NewSQLStore(db *sql.DB, dialect string) (*SQLStore, error) {
+	d, ok := sqlDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a supported dialect", dialect)
+	}
+	return &SQLStore{
+		db:       db,
+		dialect:  d,
+		migrated: map[string]bool{},
+		members:  map[string]bool{},
+		audited:  map[string]bool{},
+	}, nil
+}
+
+// Add implements `Store`
+func (ss *SQLStore) Add(scheduler string, r *Record) error {
+	if err := ss.migrate(scheduler); err != nil {
+		return err
+	}
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	var stored Record
+	if err := ss.selectForUpdate(tx, scheduler, r.JobName, &stored); err == sql.ErrNoRows {
+		// create a new job in the database
+		if err := ss.insert(tx, scheduler, r); err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else if err != nil {
+		tx.Rollback()
+		return err
+	} else {
+		// the state shared by every instance must survive restarts
+		r.Merge(&stored)
+		if err := ss.update(tx, scheduler, r, false); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddAll implements `BulkAdder`. It selects every stored record of the scheduler in one query
+// and inserts the new records with as few statements as the placeholders of the database allow
+func (ss *SQLStore) AddAll(scheduler string, rs []*Record) error {
+	if err := ss.migrate(scheduler); err != nil {
+		return err
+	}
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	records, err := ss.selectAll(tx, scheduler, ss.dialect.lock)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	stored := map[string]Record{}
+	for _, r := range records {
+		stored[r.JobName] = r
+	}
+	var inserts []*Record
+	for _, r := range rs {
+		s, ok := stored[r.JobName]
+		if !ok {
+			inserts = append(inserts, r)
+			continue
+		}
+
+		// the state shared by every instance must survive restarts
+		r.Merge(&s)
+		if err := ss.update(tx, scheduler, r, false); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	columns, _ := recordColumns(&Record{})
+	size := maxPlaceholders / len(columns)
+	for len(inserts) > 0 {
+		n := size
+		if n > len(inserts) {
+			n = len(inserts)
+		}
+		if err := ss.insert(tx, scheduler, inserts[:n]...); err != nil {
+			tx.Rollback()
+			return err
+		}
+		inserts = inserts[n:]
+	}
+	return tx.Commit()
+}
+
+// Claim implements `Store`
+func (ss *SQLStore) Claim(scheduler string, r *Record) error {
+	if ss.ClaimStrategy == AdvisoryLocking && len(ss.dialect.lockJob) > 0 {
+		unlock, err := ss.dialect.advisoryLock(ss.db, scheduler, r.JobName, ss.LockTimeout, ss.SkipLocked)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		var stored Record
+		if err := ss.selectRecord(ss.db, scheduler, r.JobName, &stored, ""); err != nil {
+			return err
+		} else if err := r.Claim(&stored); err != nil {
+			return err
+		}
+		return ss.update(ss.db, scheduler, r, false)
+	} else if ss.ClaimStrategy == OptimisticLocking {
+		var stored Record
+		if err := ss.selectRecord(ss.db, scheduler, r.JobName, &stored, ""); err != nil {
+			return err
+		} else if err := r.Claim(&stored); err != nil {
+			return err
+		}
+		return ss.update(ss.db, scheduler, r, true)
+	}
+
+	// the claim holds a connection of its own, so that the lock timeout it sets can be restored before the connection is released
+	ctx := context.Background()
+	conn, err := ss.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	restore, err := ss.dialect.waitLocks(tx, ss.LockTimeout)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	lock := ss.dialect.lock
+	if ss.SkipLocked && len(ss.dialect.skipLocked) > 0 {
+		lock = ss.dialect.skipLocked
+	}
+	var stored Record
+	err = ss.selectRecord(tx, scheduler, r.JobName, &stored, lock)
+	if rErr := restore(); rErr != nil {
+		tx.Rollback()
+		// a connection that kept the lock timeout of the claim must not go back to the pool
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return rErr
+	}
+	if err != nil {
+		tx.Rollback()
+		if (err == sql.ErrNoRows && lock == ss.dialect.skipLocked) || isLockTimeout(err) {
+			// another instance is claiming the execution
+			return ErrAlreadyExecuted
+		}
+		return err
+	} else if err := r.Claim(&stored); err != nil {
+		tx.Rollback()
+		return err
+	} else if err := ss.update(tx, scheduler, r, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// List implements `Lister`
+func (ss *SQLStore) List(scheduler string) ([]Record, error) {
+	return ss.selectAll(ss.db, scheduler, "")
+}
+
+// Pause implements `Editor`
+func (ss *SQLStore) Pause(scheduler, name string, paused bool) error {
+	_, err := ss.db.Exec(fmt.Sprintf("UPDATE %s SET %s = %s, %s = %s + 1 WHERE %s = %s",
+		ss.dialect.quoteTable(scheduler), ss.dialect.quote("paused"), ss.dialect.placeholder(1), ss.dialect.quote("version"), ss.dialect.quote("version"),
+		ss.dialect.quote("job_name"), ss.dialect.placeholder(2)), paused, name)
+	return err
+}
+
+// Disable implements `Disabler`
+func (ss *SQLStore) Disable(scheduler, name string, disabled bool) error {
+	_, err := ss.db.Exec(fmt.Sprintf("UPDATE %s SET %s = %s, %s = %s + 1 WHERE %s = %s",
+		ss.dialect.quoteTable(scheduler), ss.dialect.quote("disabled"), ss.dialect.placeholder(1), ss.dialect.quote("version"), ss.dialect.quote("version"),
+		ss.dialect.quote("job_name"), ss.dialect.placeholder(2)), disabled, name)
+	return err
+}
+
+// Remove implements `Editor`
+func (ss *SQLStore) Remove(scheduler, name string) error {
+	_, err := ss.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = %s",
+		ss.dialect.quoteTable(scheduler), ss.dialect.quote("job_name"), ss.dialect.placeholder(1)), name)
+	return err
+}
+
+// Audit implements `Auditor`. The audit log of each scheduler is kept in a table named after it with an "_audit" suffix
+func (ss *SQLStore) Audit(scheduler string, e AuditEntry) error {
+	if err := ss.migrateAudit(scheduler); err != nil {
+		return err
+	}
+	return ss.dialect.audit(ss.db, scheduler, e)
+}
+
+// History implements `Auditor`
+func (ss *SQLStore) History(scheduler, name string) ([]AuditEntry, error) {
+	if err := ss.migrateAudit(scheduler); err != nil {
+		return nil, err
+	}
+	return ss.dialect.history(ss.db, scheduler, name)
+}
+
+// migrateAudit creates the audit log of the scheduler if it does not exist and `AutoMigrate` is set
+func (ss *SQLStore) migrateAudit(scheduler string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.audited[scheduler] || !ss.AutoMigrate {
+		return nil
+	}
+	if err := ss.dialect.migrateAudit(ss.db, scheduler); err != nil {
+		return err
+	}
+	ss.audited[scheduler] = true
+	return nil
+}
+
+// Ping implements `Pinger`
+func (ss *SQLStore) Ping(ctx context.Context) error {
+	return ss.db.PingContext(ctx)
+}
+
+// Now implements `Clock`
+func (ss *SQLStore) Now() (time.Time, error) {
+	return ss.dialect.clock(ss.db)
+}
+
+// Finish implements `Finisher`
+func (ss *SQLStore) Finish(scheduler string, r *Record, duration time.Duration, err error) error {
+	q := ss.dialect.quote
+	sets := fmt.Sprintf("%s = %s + 1, %s = %s + %s, %s = %s, %s = %s + 1",
+		q("finish_count"), q("finish_count"), q("total_duration"), q("total_duration"), ss.dialect.placeholder(1),
+		q("job_duration"), ss.dialect.placeholder(2), q("version"), q("version"))
+	args := []interface{}{int64(duration), int64(duration)}
+	if err != nil {
+		args = append(args, err.Error())
+		sets += fmt.Sprintf(", %s = %s + 1, %s = %s", q("failure_count"), q("failure_count"), q("last_error"), ss.dialect.placeholder(len(args)))
+	} else {
+		args = append(args, time.Time{})
+		sets += fmt.Sprintf(", %s = %s", q("pending_until"), ss.dialect.placeholder(len(args)))
+	}
+	args = append(args, r.JobName)
+	_, uErr := ss.db.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s", ss.dialect.quoteTable(scheduler), sets, q("job_name"), ss.dialect.placeholder(len(args))), args...)
+	return uErr
+}
+
+// Heartbeat implements `Membership`. The instances of each scheduler are kept in a table named after it with an "_instances" suffix
+func (ss *SQLStore) Heartbeat(scheduler, instance string, expires time.Time) error {
+	table, err := ss.migrateMembers(scheduler)
+	if err != nil {
+		return err
+	}
+	return ss.dialect.heartbeat(ss.db, table, instance, expires)
+}
+
+// Members implements `Membership`
+func (ss *SQLStore) Members(scheduler string, now time.Time) ([]string, error) {
+	table, err := ss.migrateMembers(scheduler)
+	if err != nil {
+		return nil, err
+	}
+	return ss.dialect.members(ss.db, table, now)
+}
+
+// migrateMembers creates the table of the instances of the scheduler if it does not exist and `AutoMigrate` is set,
+// and returns its quoted name
+func (ss *SQLStore) migrateMembers(scheduler string) (string, error) {
+	d := ss.dialect
+	table := d.quoteTable(scheduler + "_instances")
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.members[scheduler] || !ss.AutoMigrate {
+		return table, nil
+	}
+	if _, err := ss.db.Exec(d.createMembers(scheduler)); err != nil {
+		return "", err
+	}
+	ss.members[scheduler] = true
+	return table, nil
+}
+
+// migrate creates the table of the scheduler if it does not exist yet and `AutoMigrate` is set
+func (ss *SQLStore) migrate(scheduler string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.migrated[scheduler] || !ss.AutoMigrate {
+		return nil
+	}
+	if _, err := ss.db.Exec(ss.dialect.createTable(scheduler, true, 0)); err != nil {
+		return err
+	}
+
+	// add the columns that a table created by an older version is missing
+	existing, err := ss.dialect.columns(ss.db, scheduler)
+	if err != nil {
+		return err
+	}
+	for field := range addedFields {
+		if existing[columnName(field)] {
+			continue
+		} else if _, err := ss.db.Exec(ss.dialect.addColumns(scheduler, field)); err != nil {
+			return err
+		}
+	}
+	if err := ss.dialect.migrateTimes(ss.db, scheduler); err != nil {
+		return err
+	}
+	ss.migrated[scheduler] = true
+	return nil
+}
+
+// selectForUpdate selects and locks the record of the job named `name`
+func (ss *SQLStore) selectForUpdate(tx *sql.Tx, scheduler, name string, r *Record) error {
+	return ss.selectRecord(tx, scheduler, name, r, ss.dialect.lock)
+}
+
+// selectRecord selects the record of the job named `name`, with `lock` appended to the query
+func (ss *SQLStore) selectRecord(q querier, scheduler, name string, r *Record, lock string) error {
+	columns, fields := recordColumns(r)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s%s",
+		ss.dialect.quoteAll(columns), ss.dialect.quoteTable(scheduler), ss.dialect.quote("job_name"), ss.dialect.placeholder(1), lock)
+	return q.QueryRow(query, name).Scan(fields...)
+}
+
+// selectAll selects every record of the scheduler, with `lock` appended to the query
+func (ss *SQLStore) selectAll(q querier, scheduler, lock string) ([]Record, error) {
+	columns, _ := recordColumns(&Record{})
+	rows, err := q.Query(fmt.Sprintf("SELECT %s FROM %s%s", ss.dialect.quoteAll(columns), ss.dialect.quoteTable(scheduler), lock))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []Record
+	for rows.Next() {
+		var r Record
+		_, fields := recordColumns(&r)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// maxPlaceholders is the number of placeholders a statement may have in every supported database, which is the limit of older sqlite versions
+const maxPlaceholders = 999
+
+// insert inserts `rs` with a single statement
+func (ss *SQLStore) insert(tx *sql.Tx, scheduler string, rs ...*Record) error {
+	var columns, rows []string
+	var args []interface{}
+	for _, r := range rs {
+		var fields []interface{}
+		columns, fields = recordColumns(r)
+		var placeholders []string
+		for range columns {
+			args = append(args, nil)
+			placeholders = append(placeholders, ss.dialect.placeholder(len(args)))
+		}
+		copy(args[len(args)-len(fields):], values(fields))
+		rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+	}
+	_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		ss.dialect.quoteTable(scheduler), ss.dialect.quoteAll(columns), strings.Join(rows, ", ")), args...)
+	return err
+}
+
+// update saves `r` over the stored record of the job.
+// When `optimistic` is true, it returns `ErrAlreadyExecuted` if the stored record is no longer the version `r` was claimed over
+func (ss *SQLStore) update(q querier, scheduler string, r *Record, optimistic bool) error {
+	columns, fields := recordColumns(r)
+	var sets []string
+	for i, c := range columns {
+		sets = append(sets, fmt.Sprintf("%s = %s", ss.dialect.quote(c), ss.dialect.placeholder(i+1)))
+	}
+	args := append(values(fields), r.JobName)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s",
+		ss.dialect.quoteTable(scheduler), strings.Join(sets, ", "), ss.dialect.quote("job_name"), ss.dialect.placeholder(len(args)))
+	if optimistic {
+		args = append(args, r.Version-1)
+		query += fmt.Sprintf(" AND %s = %s", ss.dialect.quote("version"), ss.dialect.placeholder(len(args)))
+	}
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return err
+	} else if !optimistic {
+		return nil
+	} else if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAlreadyExecuted
+	}
+	return nil
+}
+
+// querier is implemented by both `*sql.DB` and `*sql.Tx`
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sqlDialect holds the differences in sql between databases
+type sqlDialect struct {
+	quoteChar   string
+	numbered    bool
+	lock        string
+	skipLocked  string
+	lockTimeout string
+	getTimeout  string
+	timeoutUnit time.Duration
+	stringType  string
+	textType    string
+	bytesType   string
+	serialType  string
+	intType     string
+	bigintType  string
+	boolType    string
+	timeType    string
+	zeroTime    string
+	modifyType  string
+	primaryType string
+	now         string
+	lockJob     string
+	tryLockJob  string
+	unlockJob   string
+
+	// lockJobTimeout is set when `lockJob` takes the number of `timeoutUnit`s it waits for the lock as its second argument
+	lockJobTimeout bool
+}
+
+// sqlDialects are the dialects supported by `SQLStore` and `Schema`
+var sqlDialects = map[string]sqlDialect{
+	"mysql": {
+		quoteChar:   "`",
+		lock:        " FOR UPDATE",
+		skipLocked:  " FOR UPDATE SKIP LOCKED",
+		lockTimeout: "SET SESSION innodb_lock_wait_timeout = %d",
+		getTimeout:  "SELECT @@SESSION.innodb_lock_wait_timeout",
+		timeoutUnit: time.Second,
+		stringType:  "varchar(255)",
+		textType:    "text",
+		bytesType:   "blob",
+		serialType:  "bigint NOT NULL AUTO_INCREMENT",
+		intType:     "int",
+		bigintType:  "bigint",
+		boolType:    "boolean",
+		timeType:    "DATETIME(6) NULL",
+		zeroTime:    "'0001-01-01 00:00:00'",
+		modifyType:  "MODIFY COLUMN %s %s",
+		primaryType: "varchar(255)",
+		now:         "SELECT UNIX_TIMESTAMP(NOW(6))",
+		lockJob:     "SELECT GET_LOCK(CONCAT('schedule-', ?), ?)",
+		tryLockJob:  "SELECT GET_LOCK(CONCAT('schedule-', ?), 0)",
+		unlockJob:   "SELECT RELEASE_LOCK(CONCAT('schedule-', ?))",
+
+		lockJobTimeout: true,
+	},
+	"postgres": {
+		quoteChar:   `"`,
+		numbered:    true,
+		lock:        " FOR UPDATE",
+		skipLocked:  " FOR UPDATE SKIP LOCKED",
+		lockTimeout: "SET LOCAL lock_timeout = %d",
+		timeoutUnit: time.Millisecond,
+		stringType:  "text",
+		textType:    "text",
+		bytesType:   "bytea",
+		serialType:  "bigserial",
+		intType:     "integer",
+		bigintType:  "bigint",
+		boolType:    "boolean",
+		timeType:    "timestamp with time zone",
+		zeroTime:    "'0001-01-01 00:00:00+00'",
+		primaryType: "text",
+		now:         "SELECT EXTRACT(EPOCH FROM clock_timestamp())",
+		lockJob:     "SELECT 1 FROM pg_advisory_lock($1)",
+		tryLockJob:  "SELECT CASE WHEN pg_try_advisory_lock($1) THEN 1 ELSE 0 END",
+		unlockJob:   "SELECT pg_advisory_unlock($1)",
+	},
+	"sqlite3": {
+		quoteChar:   `"`,
+		stringType:  "varchar(255)",
+		textType:    "text",
+		bytesType:   "blob",
+		serialType:  "integer",
+		intType:     "integer",
+		bigintType:  "bigint",
+		boolType:    "bool",
+		timeType:    "datetime",
+		zeroTime:    "'0001-01-01 00:00:00+00:00'",
+		primaryType: "varchar(255)",
+		now:         "SELECT (julianday('now') - 2440587.5) * 86400.0",
+	},
+}
+
+// validTableName returns an error if gorm cannot use `name` as the name of a mysql table, which can be qualified by its database.
+// gorm does not escape the table names that it quotes and does not quote the ones that have a space
+func validTableName(name string) error {
+	parts := strings.Split(name, ".")
+	for _, part := range parts {
+		if len(parts) > 2 || len(part) == 0 || len(part) > 64 || strings.ContainsAny(part, "` \t\n") {
+			return fmt.Errorf("%q is not a valid table name, see `Config.TableName`", name)
+		}
+	}
+	return nil
+}
+
+// quote quotes an identifier
+func (d sqlDialect) quote(name string) string {
+	return d.quoteChar + strings.Replace(name, d.quoteChar, d.quoteChar+d.quoteChar, -1) + d.quoteChar
+}
+
+// quoteTable quotes the name of a table, which can be qualified by its schema, ie `jobs.scheduler_default`
+func (d sqlDialect) quoteTable(name string) string {
+	if i := strings.Index(name, "."); i >= 0 {
+		return d.quote(name[:i]) + "." + d.quote(name[i+1:])
+	}
+	return d.quote(name)
+}
+
+// quoteAll quotes a list of identifiers and joins them with commas
+func (d sqlDialect) quoteAll(names []string) string {
+	var quoted []string
+	for _, n := range names {
+		quoted = append(quoted, d.quote(n))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// placeholder returns the placeholder of the ith argument of a statement, starting at 1
+func (d sqlDialect) placeholder(i int) string {
+	if d.numbered {
+		return fmt.Sprintf("$%d", i)
+	}
+	return "?"
+}
+
+// createTable returns the DDL of the table of the scheduler named `name`.
+// The columns that were added by a migration after `version` are left out, unless it is zero
+func (d sqlDialect) createTable(name string, ifNotExists bool, version int) string {
+	var columns []string
+	t := reflect.TypeOf(Record{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || (version > 0 && addedFields[f.Name] > version) {
+			continue
+		}
+		columns = append(columns, fmt.Sprintf("%s %s", d.quote(columnName(f.Name)), d.columnType(f)))
+	}
+	var exists string
+	if ifNotExists {
+		exists = "IF NOT EXISTS "
+	}
+	return fmt.Sprintf("CREATE TABLE %s%s (\n\t%s,\n\tPRIMARY KEY (%s)\n);\n", exists, d.quoteTable(name), strings.Join(columns, ",\n\t"), d.quote("job_name"))
+}
+
+// columnType returns the type of the column of the field `f` of `Record`
+func (d sqlDialect) columnType(f reflect.StructField) string {
+	switch {
+	case f.Name == "JobName":
+		return d.primaryType
+	case f.Type == reflect.TypeOf(time.Time{}):
+		return d.timeType
+	case f.Type.Kind() == reflect.Bool:
+		return d.boolType
+	case f.Type.Kind() == reflect.String:
+		return d.stringType
+	case f.Type == reflect.TypeOf([]byte(nil)):
+		return d.bytesType
+	case f.Type.Kind() == reflect.Int64:
+		return d.bigintType
+	}
+	return d.intType
+}
+
+// addColumns returns the DDL that adds the columns of the fields of `Record` named `fields` to the table of the scheduler named `name`.
+// The rows that already exist get the zero value of the fields, which is NULL for the binary columns
+func (d sqlDialect) addColumns(name string, fields ...string) string {
+	var columns []string
+	for _, field := range fields {
+		f, _ := reflect.TypeOf(Record{}).FieldByName(field)
+		zero := "0"
+		switch {
+		case f.Type == reflect.TypeOf([]byte(nil)):
+			columns = append(columns, fmt.Sprintf("ADD COLUMN %s %s", d.quote(columnName(field)), d.columnType(f)))
+			continue
+		case f.Type == reflect.TypeOf(time.Time{}):
+			zero = d.zeroTime
+		case f.Type.Kind() == reflect.Bool:
+			zero = "false"
+		case f.Type.Kind() == reflect.String:
+			zero = "''"
+		}
+		columns = append(columns, fmt.Sprintf("ADD COLUMN %s %s DEFAULT %s", d.quote(columnName(field)), d.columnType(f), zero))
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s;\n", d.quoteTable(name), strings.Join(columns, ",\n\t"))
+}
+
+// preciseTimes returns the DDL that makes the time columns of the table of the scheduler named `name` keep the fractions of a second,
+// which the mysql tables of the older versions truncated. It returns nothing when the dialect always keeps them
+func (d sqlDialect) preciseTimes(name string) string {
+	if d.modifyType == "" {
+		return ""
+	}
+	var columns []string
+	t := reflect.TypeOf(Record{})
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" && f.Type == reflect.TypeOf(time.Time{}) {
+			columns = append(columns, fmt.Sprintf(d.modifyType, d.quote(columnName(f.Name)), d.timeType))
+		}
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s;\n", d.quoteTable(name), strings.Join(columns, ",\n\t"))
+}
+
+// imprecise reports whether the time columns of the table of the scheduler named `name` truncate the fractions of a second
+func (d sqlDialect) imprecise(db *sql.DB, name string) (bool, error) {
+	if d.modifyType == "" {
+		return false, nil
+	}
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 0", d.quote("next_run_at"), d.quoteTable(name)))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return false, err
+	}
+	_, scale, ok := types[0].DecimalSize()
+	return ok && scale < 6, nil
+}
+
+// migrateTimes makes the time columns of the table of the scheduler named `name` keep the fractions of a second if they truncate them
+func (d sqlDialect) migrateTimes(db *sql.DB, name string) error {
+	if imprecise, err := d.imprecise(db, name); err != nil || !imprecise {
+		return err
+	}
+	_, err := db.Exec(d.preciseTimes(name))
+	return err
+}
+
+// columns returns the names of the columns of the table of the scheduler named `name`
+func (d sqlDialect) columns(db *sql.DB, name string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", d.quoteTable(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, c := range columns {
+		existing[c] = true
+	}
+	return existing, nil
+}
+
+// createMembers returns the DDL of the table of the instances of the scheduler whose table is named `name`
+func (d sqlDialect) createMembers(name string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s, %s %s, PRIMARY KEY (%s));\n",
+		d.quoteTable(name+"_instances"), d.quote("instance"), d.primaryType, d.quote("expires_at"), d.timeType, d.quote("instance"))
+}
+
+// heartbeat records that `instance` is alive until `expires` in the table of instances whose quoted name is `table`
+func (d sqlDialect) heartbeat(db *sql.DB, table, instance string, expires time.Time) error {
+	res, err := db.Exec(fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s",
+		table, d.quote("expires_at"), d.placeholder(1), d.quote("instance"), d.placeholder(2)), expires.UTC(), instance)
+	if err != nil {
+		return err
+	} else if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (%s, %s)",
+		table, d.quote("instance"), d.quote("expires_at"), d.placeholder(1), d.placeholder(2)), instance, expires.UTC())
+	return err
+}
+
+// members selects the instances that are alive at `now` from the table of instances whose quoted name is `table`
+func (d sqlDialect) members(db *sql.DB, table string, now time.Time) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s > %s ORDER BY %s",
+		d.quote("instance"), table, d.quote("expires_at"), d.placeholder(1), d.quote("instance")), now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// auditColumns are the columns of the audit log of a scheduler in the order of the fields of `AuditEntry`
+var auditColumns = []string{"audited_at", "job_name", "action", "actor", "old_spec", "new_spec", "reason"}
+
+// auditReasonVersion is the version of the migration that added the reason column to the audit log
+const auditReasonVersion = 13
+
+// createAudit returns the DDL of the audit log of the scheduler whose table is named `name`. Its entries are ordered by their id.
+// The reason column is left out if `version` is before the migration that added it, unless it is zero
+func (d sqlDialect) createAudit(name string, version int) string {
+	types := []string{d.timeType, d.stringType, d.stringType, d.stringType, d.textType, d.textType, d.stringType}
+	columns := []string{fmt.Sprintf("%s %s", d.quote("id"), d.serialType)}
+	for i, c := range auditColumns {
+		if c == "reason" && version > 0 && version < auditReasonVersion {
+			continue
+		}
+		columns = append(columns, fmt.Sprintf("%s %s", d.quote(c), types[i]))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s,\n\tPRIMARY KEY (%s)\n);\n",
+		d.quoteTable(name+"_audit"), strings.Join(columns, ",\n\t"), d.quote("id"))
+}
+
+// addAuditReason returns the DDL that adds the reason column to the audit log of the scheduler whose table is named `name`
+func (d sqlDialect) addAuditReason(name string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s DEFAULT '';\n", d.quoteTable(name+"_audit"), d.quote("reason"), d.stringType)
+}
+
+// migrateAudit creates the audit log of the scheduler whose table is named `name` if it does not exist,
+// and adds the columns that an older version of it is missing
+func (d sqlDialect) migrateAudit(db *sql.DB, name string) error {
+	if _, err := db.Exec(d.createAudit(name, 0)); err != nil {
+		return err
+	}
+	existing, err := d.columns(db, name+"_audit")
+	if err != nil || existing["reason"] {
+		return err
+	}
+	_, err = db.Exec(d.addAuditReason(name))
+	return err
+}
+
+// audit appends `e` to the audit log of the scheduler whose table is named `scheduler`
+func (d sqlDialect) audit(db *sql.DB, scheduler string, e AuditEntry) error {
+	var placeholders []string
+	for i := range auditColumns {
+		placeholders = append(placeholders, d.placeholder(i+1))
+	}
+	_, err := db.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", d.quoteTable(scheduler+"_audit"), d.quoteAll(auditColumns), strings.Join(placeholders, ", ")),
+		e.Time.UTC(), e.JobName, string(e.Action), e.Actor, e.Before, e.After, e.Reason)
+	return err
+}
+
+// advisoryTimeout is how long `AdvisoryLocking` waits for the lock of a job when the lock timeout is not set
+const advisoryTimeout = 10 * time.Second
+
+// advisoryLock takes the advisory lock of the job named `name` in the table of `scheduler`, waiting up to `timeout`, or `advisoryTimeout`, for it.
+// The wait is passed to the lock query of the dialects that take it, ie mysql's `GET_LOCK`, and is the deadline of the query of the others.
+// If `skip` is set it does not wait at all. It returns `ErrAlreadyExecuted` if another instance kept the lock.
+// Advisory locks belong to a connection, so the lock holds a connection of its own until it is released by calling `unlock`
+func (d sqlDialect) advisoryLock(db *sql.DB, scheduler, name string, timeout time.Duration, skip bool) (unlock func(), err error) {
+	h := fnv.New64a()
+	h.Write([]byte(scheduler + "." + name))
+	key := int64(h.Sum64())
+	query := d.lockJob
+	if skip {
+		query = d.tryLockJob
+	}
+	if timeout <= 0 {
+		timeout = advisoryTimeout
+	}
+	args := []interface{}{key}
+	deadline := timeout
+	if !skip && d.lockJobTimeout {
+		// round up like `waitLocks`, and leave the database the time to give up on the lock itself before the deadline
+		units := (timeout + d.timeoutUnit - 1) / d.timeoutUnit
+		args = append(args, int64(units))
+		deadline = time.Duration(units)*d.timeoutUnit + time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, query, args...).Scan(&locked); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ErrAlreadyExecuted
+		}
+		return nil, err
+	} else if locked.Valid && locked.Int64 == 0 {
+		conn.Close()
+		return nil, ErrAlreadyExecuted
+	} else if !locked.Valid || locked.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("%s failed to take its advisory lock", name)
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), d.unlockJob, key); err != nil {
+			log.Printf("schedule: %s failed to release its advisory lock: %s", name, err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// waitLocks limits how long the locks of the transaction `tx` are waited for to `timeout`, unless the timeout is not set or the dialect cannot limit it.
+// It returns a function that must be called before the transaction ends: postgres limits the wait of the transaction only,
+// but mysql limits the wait of the session, so its previous limit is restored to keep it from leaking into the other queries of the connection
+func (d sqlDialect) waitLocks(tx querier, timeout time.Duration) (restore func() error, err error) {
+	restore = func() error { return nil }
+	if timeout <= 0 || len(d.lockTimeout) == 0 {
+		return restore, nil
+	}
+	if len(d.getTimeout) > 0 {
+		var previous int64
+		if err := tx.QueryRow(d.getTimeout).Scan(&previous); err != nil {
+			return nil, err
+		}
+		restore = func() error {
+			_, err := tx.Exec(fmt.Sprintf(d.lockTimeout, previous))
+			return err
+		}
+	}
+	// round up, so that a timeout shorter than the unit does not turn into no timeout
+	if _, err := tx.Exec(fmt.Sprintf(d.lockTimeout, (timeout+d.timeoutUnit-1)/d.timeoutUnit)); err != nil {
+		return nil, err
+	}
+	return restore, nil
+}
+
+// isLockTimeout reports whether `err` means that a lock was not released before the lock timeout,
+// which the mysql and postgres drivers only report with their messages
+func isLockTimeout(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "Lock wait timeout exceeded") || strings.Contains(err.Error(), "lock timeout"))
+}
+
+// clock selects the time of the database, which every dialect returns as the number of seconds since the unix epoch
+func (d sqlDialect) clock(db *sql.DB) (time.Time, error) {
+	var seconds float64
+	if err := db.QueryRow(d.now).Scan(&seconds); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// history selects the audit log of the job named `name` of the scheduler whose table is named `scheduler`
+func (d sqlDialect) history(db *sql.DB, scheduler, name string) ([]AuditEntry, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s ORDER BY %s",
+		d.quoteAll(auditColumns), d.quoteTable(scheduler+"_audit"), d.quote("job_name"), d.placeholder(1), d.quote("id")), name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.Time, &e.JobName, &e.Action, &e.Actor, &e.Before, &e.After, &e.Reason); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
+
+// recordColumns returns the column names of the persisted fields of `r` and pointers to those fields in the same order
+func recordColumns(r *Record) (columns []string, fields []interface{}) {
+	v := reflect.ValueOf(r).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		columns = append(columns, columnName(f.Name))
+		fields = append(fields, v.Field(i).Addr().Interface())
+	}
+	return columns, fields
+}
+
+// values dereferences the pointers returned by `recordColumns`
+func values(fields []interface{}) []interface{} {
+	var vs []interface{}
+	for _, f := range fields {
+		vs = append(vs, reflect.ValueOf(f).Elem().Interface())
+	}
+	return vs
+}
+
+// columnName converts the name of a field to the snake case name of its column, ie JobName to job_name
+func columnName(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
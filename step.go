@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	internalstep "github.com/marksalpeter/schedule/internal/step"
+)
+
+func init() {
+	internalstep.Step = func(s interface{}, to time.Time, executed func(name string, t time.Time)) error {
+		sched, ok := s.(Scheduler)
+		if !ok {
+			return fmt.Errorf("%T is not a scheduler, it cannot be stepped", s)
+		}
+		return step(sched, to, func(j Job, t time.Time) {
+			executed(j.Name(), t)
+		})
+	}
+}
+
+// step performs every execution of the jobs of `s` that comes due up to `to` at the time it is due, in order, instead of waiting
+// for the ticker, and calls `executed` after each of them. The funcs of the jobs are called with the stepped times.
+// It is what the simulate package steps a scheduler through simulated time with, see the internal step package, so `s` must not be running
+func step(s Scheduler, to time.Time, executed func(j Job, t time.Time)) error {
+	if m, ok := s.(Monitor); ok && m.IsRunning() {
+		return fmt.Errorf("%s is running, it cannot be stepped", s.Name())
+	}
+	stuck := map[*job]bool{}
+	for {
+		// find the job that comes due first
+		var next *job
+		var at time.Time
+		for _, a := range s.List() {
+			j, ok := a.(*job)
+			if !ok || stuck[j] || j.completed() {
+				continue
+			}
+			due := j.NextRunAt
+			if j.deferredUntil.After(due) {
+				due = j.deferredUntil
+			}
+			if !due.After(to) && (next == nil || due.Before(at)) {
+				next, at = j, due
+			}
+		}
+		if next == nil {
+			return nil
+		}
+
+		// the ticker is always a little late, so the schedule is moved on from just after the time the execution was due at.
+		// A job whose schedule does not move on will not run again before `to`, ie a `Once` job that missed its window
+		next.executing.Lock()
+		ran := next.execute(at)
+		if !next.deferredUntil.After(at) && !next.NextRunAt.After(at) {
+			next.caclulateNextRunAt(at.Add(time.Nanosecond))
+			stuck[next] = !next.NextRunAt.After(at)
+		}
+		next.executing.Unlock()
+		if ran {
+			executed(next, next.LastRunAt)
+		}
+	}
+}
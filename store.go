@@ -0,0 +1,318 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAlreadyExecuted is returned by `Store.Claim` when another instance already performed an execution
+var ErrAlreadyExecuted = errors.New("another instance already executed")
+
+// ErrPending is returned by `Store.Claim` when another instance is performing an execution of a `Task.AtLeastOnce` job that has not completed
+var ErrPending = errors.New("another instance has not completed its execution yet")
+
+// ErrNotCompleted is returned by `Store.Claim` when the lease of an execution of a `Task.AtLeastOnce` job expired before it was completed,
+// which is performed again before the execution that was claimed is claimed again
+var ErrNotCompleted = errors.New("an earlier execution was not completed")
+
+// ErrDefinitionChanged is returned by `Store.Claim` when another instance added the job with a different definition,
+// which `Record.Claim` copies into the record of the job
+var ErrDefinitionChanged = errors.New("another instance changed the definition of the job")
+
+// Store persists the `Record` of every job so that their executions can be synchronized across the instances of a scheduler
+type Store interface {
+	// Add saves the record of a job when it is added to the scheduler named `scheduler`.
+	// If the job is already stored, the stored state is merged into `r` with `Record.Merge` before it is saved
+	Add(scheduler string, r *Record) error
+
+	// Claim atomically checks `r` against the stored record with `Record.Claim` and saves it if the claim succeeds.
+	// If it returns an error, the job should not be executed
+	Claim(scheduler string, r *Record) error
+}
+
+// BulkAdder is implemented by the stores that can save many records at once, which `SpecAdder.ScheduleAll` uses
+type BulkAdder interface {
+	// AddAll saves the records of several jobs like `Store.Add`, merging the stored state into each of them
+	AddAll(scheduler string, rs []*Record) error
+}
+
+// Lister is implemented by the stores that can list every stored record of a scheduler, which is needed to discover jobs
+type Lister interface {
+	// List returns the stored records of the scheduler named `scheduler`
+	List(scheduler string) ([]Record, error)
+}
+
+// Editor is implemented by the stores that can edit the stored jobs of a scheduler, which is needed to pause and remove jobs on every instance
+type Editor interface {
+	// Pause sets the `Paused` field of the stored record of the job named `name`
+	Pause(scheduler, name string, paused bool) error
+
+	// Remove deletes the stored record of the job named `name`
+	Remove(scheduler, name string) error
+}
+
+// Disabler is implemented by the stores that can disable the stored jobs of a scheduler, which is needed to disable jobs on every instance
+type Disabler interface {
+	// Disable sets the `Disabled` field of the stored record of the job named `name`
+	Disable(scheduler, name string, disabled bool) error
+}
+
+// Finisher is implemented by the stores that share the counters of the executions of a job with every instance
+type Finisher interface {
+	// Finish atomically adds an execution that took `duration` and failed with `err`, if any, to the counters of the stored record of `r`.
+	// An execution that did not fail completes the pending execution of the record, see `Record.PendingUntil`
+	Finish(scheduler string, r *Record, duration time.Duration, err error) error
+}
+
+// Membership is implemented by the stores that keep track of the instances of a scheduler that are alive, which `Config.Sharding` requires
+type Membership interface {
+	// Heartbeat records that `instance` is alive until `expires`
+	Heartbeat(scheduler, instance string, expires time.Time) error
+
+	// Members returns the instances that are alive at `now` sorted by name
+	Members(scheduler string, now time.Time) ([]string, error)
+}
+
+// Auditor is implemented by the stores that keep an audit log of the changes to the schedules of the jobs, which `Historian.History` returns
+type Auditor interface {
+	// Audit appends `e` to the audit log of the scheduler named `scheduler`
+	Audit(scheduler string, e AuditEntry) error
+
+	// History returns the entries of the audit log of the job named `name` in the order they were appended
+	History(scheduler, name string) ([]AuditEntry, error)
+}
+
+// Pinger is implemented by the stores that can check that their database is reachable, which `Monitor.Healthy` uses
+type Pinger interface {
+	// Ping returns an error if the database cannot be reached before `ctx` is done
+	Ping(ctx context.Context) error
+}
+
+// Clock is implemented by the stores whose database can tell the time, which `Config.DatabaseClock` uses
+type Clock interface {
+	// Now returns the time of the database
+	Now() (time.Time, error)
+}
+
+// ClaimStrategy is how a database backed `Store` makes sure that only one instance claims an execution
+type ClaimStrategy int
+
+const (
+	// PessimisticLocking locks the row of the job with `SELECT ... FOR UPDATE` while the execution is claimed
+	PessimisticLocking ClaimStrategy = iota
+
+	// OptimisticLocking claims the execution with an `UPDATE ... WHERE version = ?` that fails if another instance saved the row first.
+	// It holds no locks, so it suits busy databases and backends without row locks
+	OptimisticLocking
+
+	// AdvisoryLocking locks the job with a mysql `GET_LOCK` or a postgres advisory lock while the execution is claimed instead of locking its row,
+	// which suits the managed databases and proxies that do not support `SELECT ... FOR UPDATE`, ie with statement based routing.
+	// The databases without advisory locks fall back to `PessimisticLocking`
+	AdvisoryLocking
+)
+
+// NoopStore is a `Store` that never persists or locks anything. Every instance executes every job
+type NoopStore struct{}
+
+// Add implements `Store`
+func (NoopStore) Add(scheduler string, r *Record) error {
+	return nil
+}
+
+// Claim implements `Store`
+func (NoopStore) Claim(scheduler string, r *Record) error {
+	return nil
+}
+
+// RecordingStore is an in memory `Store` that can be shared by several schedulers in the same process.
+// It records every claimed execution so that they can be inspected, which makes it useful for development and tests
+type RecordingStore struct {
+	mu         sync.Mutex
+	records    map[string]map[string]Record
+	executions map[string]map[string][]time.Time
+	members    map[string]map[string]time.Time
+	audit      map[string][]AuditEntry
+}
+
+// NewRecordingStore creates an empty `RecordingStore`
+func NewRecordingStore() *RecordingStore {
+	return &RecordingStore{
+		records:    map[string]map[string]Record{},
+		executions: map[string]map[string][]time.Time{},
+		members:    map[string]map[string]time.Time{},
+		audit:      map[string][]AuditEntry{},
+	}
+}
+
+// Add implements `Store`
+func (rs *RecordingStore) Add(scheduler string, r *Record) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.records[scheduler] == nil {
+		rs.records[scheduler] = map[string]Record{}
+		rs.executions[scheduler] = map[string][]time.Time{}
+	}
+	if stored, ok := rs.records[scheduler][r.JobName]; ok {
+		r.Merge(&stored)
+	}
+	rs.records[scheduler][r.JobName] = *r
+	return nil
+}
+
+// AddAll implements `BulkAdder`
+func (rs *RecordingStore) AddAll(scheduler string, records []*Record) error {
+	for _, r := range records {
+		if err := rs.Add(scheduler, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Claim implements `Store`
+func (rs *RecordingStore) Claim(scheduler string, r *Record) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	stored, ok := rs.records[scheduler][r.JobName]
+	if !ok {
+		return errors.New(r.JobName + " has not been added to the store")
+	} else if err := r.Claim(&stored); err != nil {
+		return err
+	}
+	rs.records[scheduler][r.JobName] = *r
+	rs.executions[scheduler][r.JobName] = append(rs.executions[scheduler][r.JobName], r.LastRunAt)
+	return nil
+}
+
+// Records returns the stored records of the scheduler named `scheduler` sorted by job name
+func (rs *RecordingStore) Records(scheduler string) []Record {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	var records []Record
+	for _, r := range rs.records[scheduler] {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].JobName < records[j].JobName
+	})
+	return records
+}
+
+// List implements `Lister`
+func (rs *RecordingStore) List(scheduler string) ([]Record, error) {
+	return rs.Records(scheduler), nil
+}
+
+// Pause implements `Editor`
+func (rs *RecordingStore) Pause(scheduler, name string, paused bool) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r, ok := rs.records[scheduler][name]
+	if !ok {
+		return errors.New(name + " has not been added to the store")
+	}
+	r.Paused = paused
+	r.Version++
+	rs.records[scheduler][name] = r
+	return nil
+}
+
+// Disable implements `Disabler`
+func (rs *RecordingStore) Disable(scheduler, name string, disabled bool) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r, ok := rs.records[scheduler][name]
+	if !ok {
+		return errors.New(name + " has not been added to the store")
+	}
+	r.Disabled = disabled
+	r.Version++
+	rs.records[scheduler][name] = r
+	return nil
+}
+
+// Remove implements `Editor`
+func (rs *RecordingStore) Remove(scheduler, name string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.records[scheduler], name)
+	return nil
+}
+
+// Finish implements `Finisher`
+func (rs *RecordingStore) Finish(scheduler string, r *Record, duration time.Duration, err error) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	stored, ok := rs.records[scheduler][r.JobName]
+	if !ok {
+		return errors.New(r.JobName + " has not been added to the store")
+	}
+	stored.FinishCount++
+	stored.TotalDuration += duration
+	stored.JobDuration = duration
+	if err != nil {
+		stored.FailureCount++
+		stored.LastError = err.Error()
+	} else {
+		stored.PendingUntil = time.Time{}
+	}
+	stored.Version++
+	rs.records[scheduler][r.JobName] = stored
+	return nil
+}
+
+// Executions returns the scheduled times of every execution of the job named `name` that was claimed through the store
+func (rs *RecordingStore) Executions(scheduler, name string) []time.Time {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]time.Time(nil), rs.executions[scheduler][name]...)
+}
+
+// Heartbeat implements `Membership`
+func (rs *RecordingStore) Heartbeat(scheduler, instance string, expires time.Time) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.members[scheduler] == nil {
+		rs.members[scheduler] = map[string]time.Time{}
+	}
+	rs.members[scheduler][instance] = expires
+	return nil
+}
+
+// Members implements `Membership`
+func (rs *RecordingStore) Members(scheduler string, now time.Time) ([]string, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	var members []string
+	for instance, expires := range rs.members[scheduler] {
+		if expires.After(now) {
+			members = append(members, instance)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// Audit implements `Auditor`
+func (rs *RecordingStore) Audit(scheduler string, e AuditEntry) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.audit[scheduler] = append(rs.audit[scheduler], e)
+	return nil
+}
+
+// History implements `Auditor`
+func (rs *RecordingStore) History(scheduler, name string) ([]AuditEntry, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	var history []AuditEntry
+	for _, e := range rs.audit[scheduler] {
+		if e.JobName == name {
+			history = append(history, e)
+		}
+	}
+	return history, nil
+}
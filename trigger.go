@@ -0,0 +1,105 @@
+package schedule
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// TriggerSource fires a job on external events, ie the arrival of a message, a file appearing or a webhook.
+// It is set with `Task.TriggeredBy`
+type TriggerSource interface {
+	// Triggers returns a channel that receives the time of every event that should run the job.
+	// The source should stop sending and close the channel when `ctx` is done, which happens when the scheduler stops
+	Triggers(ctx context.Context) <-chan time.Time
+}
+
+// TriggerFunc is an adapter to allow the use of ordinary functions as a `TriggerSource`
+type TriggerFunc func(ctx context.Context) <-chan time.Time
+
+// Triggers calls f(ctx)
+func (f TriggerFunc) Triggers(ctx context.Context) <-chan time.Time {
+	return f(ctx)
+}
+
+// HTTPTrigger is a `TriggerSource` that fires every time it receives a POST request, ie from a webhook
+type HTTPTrigger struct {
+	c chan time.Time
+}
+
+// NewHTTPTrigger creates an `HTTPTrigger`. Mount it on a `http.ServeMux` to receive the requests
+func NewHTTPTrigger() *HTTPTrigger {
+	return &HTTPTrigger{c: make(chan time.Time)}
+}
+
+// ServeHTTP fires the trigger. It responds once the scheduler has received the event,
+// or with 503 Service Unavailable if the request is canceled first, ie because the scheduler is stopped
+func (h *HTTPTrigger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	select {
+	case h.c <- time.Now():
+		w.WriteHeader(http.StatusAccepted)
+	case <-r.Context().Done():
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+}
+
+// Triggers implements `TriggerSource`
+func (h *HTTPTrigger) Triggers(ctx context.Context) <-chan time.Time {
+	return h.c
+}
+
+// trigger is an event of the `TriggerSource` of a job
+type trigger struct {
+	job  *job
+	time time.Time
+}
+
+// listen forwards the events of the `TriggerSource` of `j` to the scheduler until it is stopped.
+// It must be called with the lock of the scheduler held
+func (s *scheduler) listen(j *job) {
+	if j.source == nil {
+		return
+	}
+	if j.removed == nil {
+		j.removed = make(chan struct{})
+	}
+	quit, triggers, removed := s.quit, s.triggers, j.removed
+	ctx, cancel := context.WithCancel(context.Background())
+	c := j.source.Triggers(ctx)
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case t, ok := <-c:
+				if !ok {
+					return
+				}
+				select {
+				case triggers <- trigger{j, t}:
+				case <-quit:
+					return
+				case <-removed:
+					return
+				}
+			case <-quit:
+				return
+			case <-removed:
+				return
+			}
+		}
+	}()
+}
+
+// forget stops listening to the `TriggerSource` of `j` after it was removed from the scheduler.
+// It must be called with the lock of the scheduler held
+func (s *scheduler) forget(j *job) {
+	if j.removed != nil {
+		close(j.removed)
+		j.removed = nil
+	}
+}
@@ -0,0 +1,35 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Typed is a task whose payload is a `T`, so that the payload attached to a job and the one handed to the task are type safe
+// instead of being unmarshaled by every task, ie
+//
+//	send := schedule.Typed[Invoice](func(ctx context.Context, j schedule.Job, invoice Invoice) error { ... })
+//	send.Do(s.Add("invoice-42").Every(1).Months().On(1).At(9, 0, 0).Starting(now), Invoice{CustomerID: 42})
+//
+// The task fails when it returns an error, like a func that panics. Its context is the one of the job, see `Job.Context`
+type Typed[T any] func(ctx context.Context, j Job, payload T) error
+
+// Do attaches `payload` to the job built by `t` with `Task.WithPayload` and adds the job with the task
+func (fn Typed[T]) Do(t Task, payload T) error {
+	return t.WithPayload(payload).Do(fn.Func())
+}
+
+// Func returns the task as a func that unmarshals the payload of its job into a `T`,
+// ie to bind it to the discovered jobs with `Config.Tasks` or `Register`
+func (fn Typed[T]) Func() TaskFunc {
+	return func(j Job, t time.Time) {
+		var payload T
+		if err := j.Payload(&payload); err != nil {
+			panic(fmt.Errorf("the payload of %s is not a %T: %s", j.Name(), payload, err))
+		}
+		if err := fn(j.Context(), j, payload); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/marksalpeter/schedule/v2
+
+go 1.23
+
+require (
+	github.com/marksalpeter/schedule v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
+	github.com/go-sql-driver/mysql v1.5.0 // indirect
+	github.com/jinzhu/gorm v1.9.16 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// version 2 is built on version 1, which is developed in the same repository
+replace github.com/marksalpeter/schedule => ../
@@ -0,0 +1,133 @@
+// Package schedule is version 2 of the api of schedule, which is imported as "github.com/marksalpeter/schedule/v2".
+//
+// It lands the changes that version 1 cannot make without breaking its users: the builder returns its errors as soon as the job is added,
+// the func of a job is handed a `Run` and a `context.Context` that is cancelled when the scheduler stops waiting for it, and it fails by returning an error.
+// Version 2 is built on the scheduler of version 1 and stores its jobs the same way, so both versions can share the same table
+// while the jobs of an application are moved over one at a time. Version 1 keeps compiling at its import path
+package schedule
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/marksalpeter/schedule"
+)
+
+// The types that version 2 does not change are the ones of version 1
+type (
+	// Config configures a `Scheduler`, see `New`
+	Config = v1.Config
+
+	// Job is a job that was added to a `Scheduler`
+	Job = v1.Job
+
+	// Amount is the first step of building the schedule of a job
+	Amount = v1.Amount
+
+	// Task is the last step of building the schedule of a job
+	Task = v1.Task
+
+	// Store persists the jobs of the schedulers and synchronizes their instances
+	Store = v1.Store
+
+	// Record is the state of a job that is persisted by a `Store`
+	Record = v1.Record
+
+	// Event is sent to `Scheduler.Events` when something happens to a job
+	Event = v1.Event
+)
+
+// Run is an execution of a job, which is handed to its `Func`
+type Run struct {
+	// Job is the job that is executing
+	Job Job
+
+	// ScheduledAt is the time the execution was scheduled at
+	ScheduledAt time.Time
+
+	// Key is the key of the execution, which every instance, retry and replay of the execution has, see `Job.ExecutionKey`
+	Key string
+
+	// Replay is true when the execution is re-executed by `Scheduler.Replay` or `Scheduler.Backfill`
+	Replay bool
+}
+
+// Payload unmarshals the payload of the job of the run into `v`, see `Task.WithPayload`
+func (r Run) Payload(v interface{}) error {
+	return r.Job.Payload(v)
+}
+
+// Func is executed by a job. The execution fails when it returns an error.
+// `ctx` is cancelled when the scheduler is stopped with `Scheduler.StopContext` and stops waiting for the execution
+type Func func(ctx context.Context, run Run) error
+
+// Schedule builds the schedule of a job, ie
+//
+//	func(a schedule.Amount) schedule.Task { return a.Every(1).Days().At(9, 0, 0) }
+type Schedule func(a Amount) Task
+
+// Scheduler executes a set of jobs at the times their schedules are due.
+// It is the `Scheduler` of version 1 whose jobs execute a `Func` and are added with their schedule in one call
+type Scheduler struct {
+	scheduler
+}
+
+// scheduler is the `Scheduler` of version 1 with every optional interface that the schedulers created by `v1.New` implement
+type scheduler interface {
+	v1.Scheduler
+	v1.Replacer
+	v1.Remover
+	v1.SpecAdder
+	v1.Crontabber
+	v1.TenantManager
+	v1.Toggler
+	v1.Drainer
+	v1.Monitor
+	v1.Replayer
+	v1.Historian
+}
+
+// New creates a new `Scheduler`, see `Config`
+func New(cfg *Config) (*Scheduler, error) {
+	s, err := v1.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{s.(scheduler)}, nil
+}
+
+// MustNew is like `New` but panics if the scheduler cannot be created
+func MustNew(cfg *Config) *Scheduler {
+	s, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Add adds the job named `name` that executes `fn` on the schedule built by `schedule`.
+// It returns an error if the schedule is invalid or the job was already added
+func (s *Scheduler) Add(name string, schedule Schedule, fn Func) error {
+	return schedule(s.scheduler.Add(name)).Do(fn.task())
+}
+
+// AddOrReplace is like `Add`, but it replaces the definition of the job named `name` if it was already added, ie when a configuration is redeployed
+func (s *Scheduler) AddOrReplace(name string, schedule Schedule, fn Func) error {
+	return schedule(s.scheduler.AddOrReplace(name)).Do(fn.task())
+}
+
+// Register binds `fn` to the jobs named `name` in every scheduler, so that a job stored in the database
+// can be re-bound to its func by name after a restart or when it is discovered from another instance
+func Register(name string, fn Func) {
+	v1.Register(name, fn.task())
+}
+
+// task adapts `fn` to the func of a job of version 1, which fails by panicking
+func (fn Func) task() v1.TaskFunc {
+	return func(j v1.Job, t time.Time) {
+		run := Run{Job: j, ScheduledAt: j.LastRun(), Key: j.ExecutionKey(), Replay: j.IsReplay()}
+		if err := fn(j.Context(), run); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package schedule_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "v2-test", Store: v1.NoopStore{}})
+	runs := make(chan schedule.Run, 1)
+	daily := func(a schedule.Amount) schedule.Task { return a.Every(1).Days().At(9, 0, 0) }
+	assert.NoError(t, s.Add("daily", daily, func(ctx context.Context, run schedule.Run) error {
+		runs <- run
+		return nil
+	}))
+
+	// the builder returns its errors when the job is added
+	assert.Error(t, s.Add("daily", daily, func(context.Context, schedule.Run) error { return nil }), "the job was already added")
+	assert.Error(t, s.Add("invalid", func(a schedule.Amount) schedule.Task { return a.Every(1).Days().At(25, 0, 0) },
+		func(context.Context, schedule.Run) error { return nil }), "the hour does not exist")
+
+	// the func is handed the run
+	at := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	assert.NoError(t, s.Replay("daily", at))
+	run := <-runs
+	assert.Equal(t, "daily", run.Job.Name())
+	assert.True(t, at.Equal(run.ScheduledAt))
+	assert.Equal(t, v1.ExecutionKey("daily", at), run.Key)
+	assert.True(t, run.Replay)
+}
+
+func TestAddOrReplace(t *testing.T) {
+	s := schedule.MustNew(&schedule.Config{Name: "v2-test", Store: v1.NoopStore{}})
+	noop := func(context.Context, schedule.Run) error { return nil }
+	assert.NoError(t, s.AddOrReplace("job", func(a schedule.Amount) schedule.Task { return a.Every(1).Days().At(9, 0, 0) }, noop))
+	assert.NoError(t, s.AddOrReplace("job", func(a schedule.Amount) schedule.Task { return a.Every(1).Days().At(10, 0, 0) }, noop))
+	if jobs := s.List(); assert.Len(t, jobs, 1) {
+		assert.Equal(t, 10, jobs[0].NextRun().In(jobs[0].Location()).Hour(), "the definition was replaced")
+	}
+}
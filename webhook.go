@@ -0,0 +1,76 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook is a `Notifier` that POSTs every notification as json to a URL, ie to an incident management service
+type Webhook struct {
+	// URL is the address the notifications are posted to
+	URL string
+
+	// Client is the client used to post the notifications. It defaults to `http.DefaultClient`
+	Client *http.Client
+
+	// Retry determines how many times a notification is attempted. Any error or non 2xx response is retried
+	Retry RetryPolicy
+}
+
+// Notify implements `Notifier`
+func (w *Webhook) Notify(n Notification) error {
+	return postJSON(w.Client, w.URL, n, w.Retry)
+}
+
+// Slack is a `Notifier` that posts every notification to a slack incoming webhook
+type Slack struct {
+	// URL is the address of the incoming webhook
+	URL string
+
+	// Client is the client used to post the notifications. It defaults to `http.DefaultClient`
+	Client *http.Client
+
+	// Retry determines how many times a notification is attempted
+	Retry RetryPolicy
+}
+
+// Notify implements `Notifier`
+func (s *Slack) Notify(n Notification) error {
+	return postJSON(s.Client, s.URL, map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", n.Subject, n.Message),
+	}, s.Retry)
+}
+
+// postJSON posts `v` as json to `url`, retrying any error or non 2xx response according to `retry`
+func postJSON(client *http.Client, url string, v interface{}, retry RetryPolicy) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for attempt := 1; ; attempt++ {
+		err = post(client, url, body)
+		if err == nil || attempt >= retry.Attempts {
+			return err
+		}
+		time.Sleep(retry.delay(attempt))
+	}
+}
+
+// post posts `body` to `url` once
+func post(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+	return nil
+}
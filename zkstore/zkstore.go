@@ -0,0 +1,186 @@
+// Package zkstore implements a `schedule.Store` with ZooKeeper, for teams that already run an ensemble.
+// An execution is claimed by creating an ephemeral znode, so the claim is released if the instance that holds it dies
+package zkstore
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/marksalpeter/schedule"
+)
+
+// maxAttempts is the number of times `Store.Add` is attempted when another instance writes the same job concurrently
+const maxAttempts = 3
+
+// Conn is the part of the ZooKeeper api used by `Store`. `*zk.Conn` satisfies this interface
+type Conn interface {
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Delete(path string, version int32) error
+	Get(path string) ([]byte, *zk.Stat, error)
+	Set(path string, data []byte, version int32) (*zk.Stat, error)
+	Children(path string) ([]string, *zk.Stat, error)
+}
+
+// Store is a `schedule.Store` that keeps the records of the jobs of every scheduler in persistent znodes under a root path
+type Store struct {
+	conn Conn
+	root string
+	acl  []zk.ACL
+}
+
+// New creates a `Store` that keeps its znodes under `root`, ie "/schedule". The znodes are created with `acl`,
+// or `zk.WorldACL(zk.PermAll)` if it is empty
+func New(conn Conn, root string, acl ...zk.ACL) *Store {
+	if len(acl) == 0 {
+		acl = zk.WorldACL(zk.PermAll)
+	}
+	return &Store{conn: conn, root: path.Join("/", root), acl: acl}
+}
+
+// Add implements `schedule.Store`
+func (s *Store) Add(scheduler string, r *schedule.Record) error {
+	for attempt := 1; ; attempt++ {
+		stored, version, err := s.get(scheduler, r.JobName)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			err = s.create(scheduler, r)
+			if err != zk.ErrNodeExists || attempt >= maxAttempts {
+				return err
+			}
+			continue
+		}
+		r.Merge(stored)
+		err = s.set(scheduler, r, version)
+		if err != zk.ErrBadVersion || attempt >= maxAttempts {
+			return err
+		}
+	}
+}
+
+// Claim implements `schedule.Store`
+func (s *Store) Claim(scheduler string, r *schedule.Record) error {
+	// claim the execution with an ephemeral znode
+	claim := s.path(scheduler, "claims", r.JobName)
+	if err := s.parents(claim); err != nil {
+		return err
+	} else if _, err := s.conn.Create(claim, nil, zk.FlagEphemeral, s.acl); err == zk.ErrNodeExists {
+		return schedule.ErrAlreadyExecuted
+	} else if err != nil {
+		return err
+	}
+	defer func() {
+		// the claim is released with the session if it can not be deleted
+		if err := s.conn.Delete(claim, -1); err != nil && err != zk.ErrNoNode {
+			log.Printf("schedule: failed to release the claim of %s: %s", r.JobName, err)
+		}
+	}()
+
+	// update the record
+	stored, version, err := s.get(scheduler, r.JobName)
+	if err != nil {
+		return err
+	} else if stored == nil {
+		return errors.New(r.JobName + " has not been added to the store")
+	} else if err := r.Claim(stored); err != nil {
+		return err
+	}
+
+	// another instance saved the record first
+	if err := s.set(scheduler, r, version); err == zk.ErrBadVersion {
+		return schedule.ErrAlreadyExecuted
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// List implements `schedule.Lister`
+func (s *Store) List(scheduler string) ([]schedule.Record, error) {
+	names, _, err := s.conn.Children(s.path(scheduler, "jobs"))
+	if err == zk.ErrNoNode {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var records []schedule.Record
+	for _, n := range names {
+		name, err := url.PathUnescape(n)
+		if err != nil {
+			return nil, err
+		}
+		r, _, err := s.get(scheduler, name)
+		if err != nil {
+			return nil, err
+		} else if r != nil {
+			records = append(records, *r)
+		}
+	}
+	return records, nil
+}
+
+// get returns the stored record of the job named `name` and the version of its znode, or nil if it is not stored
+func (s *Store) get(scheduler, name string) (*schedule.Record, int32, error) {
+	data, stat, err := s.conn.Get(s.path(scheduler, "jobs", name))
+	if err == zk.ErrNoNode {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	var r schedule.Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, 0, err
+	}
+	return &r, stat.Version, nil
+}
+
+// create stores `r` for the first time, or fails with `zk.ErrNodeExists` if another instance added the job first
+func (s *Store) create(scheduler string, r *schedule.Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	p := s.path(scheduler, "jobs", r.JobName)
+	if err := s.parents(p); err != nil {
+		return err
+	}
+	_, err = s.conn.Create(p, data, 0, s.acl)
+	return err
+}
+
+// set writes `r` if its znode is still at `version`, or fails with `zk.ErrBadVersion` if another instance wrote it first
+func (s *Store) set(scheduler string, r *schedule.Record, version int32) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Set(s.path(scheduler, "jobs", r.JobName), data, version)
+	return err
+}
+
+// parents creates the missing parents of the znode at `p`
+func (s *Store) parents(p string) error {
+	var parent string
+	for _, dir := range strings.Split(strings.Trim(path.Dir(p), "/"), "/") {
+		parent += "/" + dir
+		if _, err := s.conn.Create(parent, nil, 0, s.acl); err != nil && err != zk.ErrNodeExists {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the path of a znode of `scheduler`. The name of the job, if any, is escaped so that it is a single znode
+func (s *Store) path(scheduler, dir string, name ...string) string {
+	p := path.Join(s.root, url.PathEscape(scheduler), dir)
+	for _, n := range name {
+		p = path.Join(p, url.PathEscape(n))
+	}
+	return p
+}
@@ -0,0 +1,165 @@
+package zkstore_test
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/marksalpeter/schedule"
+	"github.com/marksalpeter/schedule/zkstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathEscaping(t *testing.T) {
+	conn := newConn()
+	store := zkstore.New(conn, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("zk/test", &schedule.Record{JobName: "daily/report", IntervalType: schedule.Once}))
+	assert.NoError(store.Add("zk/test", &schedule.Record{JobName: "daily", IntervalType: schedule.Once}))
+	assert.Contains(conn.nodes, "/schedule/zk%2Ftest/jobs/daily%2Freport", "the slashes of the names do not create znodes")
+	assert.Contains(conn.nodes, "/schedule/zk%2Ftest/jobs/daily")
+
+	records, err := store.List("zk/test")
+	assert.NoError(err)
+	var names []string
+	for _, r := range records {
+		names = append(names, r.JobName)
+	}
+	assert.ElementsMatch([]string{"daily/report", "daily"}, names, "the names are unescaped")
+}
+
+func TestAddConflict(t *testing.T) {
+	conn := newConn()
+	store := zkstore.New(conn, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+
+	// another instance writes the job between the read and the write of the first attempt
+	conn.conflicts = 1
+	assert.NoError(store.Add("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), "the add is retried")
+
+	// another instance keeps writing the job
+	conn.conflicts = 10
+	assert.Equal(zk.ErrBadVersion, store.Add("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}),
+		"the add gives up after the last attempt")
+}
+
+func TestAddCreateConflict(t *testing.T) {
+	conn := newConn()
+	store := zkstore.New(conn, "schedule")
+
+	// another instance adds the job between the read and the create of the first attempt
+	conn.created = &schedule.Record{JobName: "job", IntervalType: schedule.Once}
+	assert.NoError(t, store.Add("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}), "the stored record is merged instead")
+}
+
+func TestClaimConflict(t *testing.T) {
+	conn := newConn()
+	store := zkstore.New(conn, "schedule")
+	assert := assert.New(t)
+	assert.NoError(store.Add("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+
+	// another instance claimed the execution and updated the record after the claim was released
+	conn.conflicts = 1
+	assert.Equal(schedule.ErrAlreadyExecuted, store.Claim("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+	assert.NotContains(conn.nodes, "/schedule/zk-test/claims/job", "the claim is released")
+
+	// another instance holds the claim
+	_, err := conn.Create("/schedule/zk-test/claims/job", nil, zk.FlagEphemeral, nil)
+	assert.NoError(err)
+	assert.Equal(schedule.ErrAlreadyExecuted, store.Claim("zk-test", &schedule.Record{JobName: "job", IntervalType: schedule.Once}))
+}
+
+// conn is a fake of a ZooKeeper connection that keeps the znodes in memory
+type conn struct {
+	mu        sync.Mutex
+	nodes     map[string]*node
+	conflicts int
+	created   *schedule.Record
+}
+
+// node is a znode of the fake connection
+type node struct {
+	data    []byte
+	version int32
+}
+
+func newConn() *conn {
+	return &conn{nodes: map[string]*node{"/": {}}}
+}
+
+func (c *conn) Create(p string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.created != nil && strings.Contains(p, "/jobs/") {
+		// another instance creates the znode first
+		stored, _ := json.Marshal(c.created)
+		c.created = nil
+		c.nodes[p] = &node{data: stored}
+	}
+	if _, ok := c.nodes[p]; ok {
+		return "", zk.ErrNodeExists
+	} else if _, ok := c.nodes[path.Dir(p)]; !ok {
+		return "", zk.ErrNoNode
+	}
+	c.nodes[p] = &node{data: data}
+	return p, nil
+}
+
+func (c *conn) Delete(p string, version int32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[p]; !ok {
+		return zk.ErrNoNode
+	}
+	delete(c.nodes, p)
+	return nil
+}
+
+func (c *conn) Get(p string) ([]byte, *zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return n.data, &zk.Stat{Version: n.version}, nil
+}
+
+func (c *conn) Set(p string, data []byte, version int32) (*zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+	if c.conflicts > 0 {
+		// another instance writes the znode first
+		c.conflicts--
+		n.version++
+	}
+	if version != -1 && version != n.version {
+		return nil, zk.ErrBadVersion
+	}
+	n.data = data
+	n.version++
+	return &zk.Stat{Version: n.version}, nil
+}
+
+func (c *conn) Children(p string) ([]string, *zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[p]; !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	var children []string
+	for k := range c.nodes {
+		if k != p && path.Dir(k) == p {
+			children = append(children, path.Base(k))
+		}
+	}
+	return children, &zk.Stat{}, nil
+}